@@ -0,0 +1,139 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+// CertificatesConfig points the files of the server certificate and of the client CA bundle
+type CertificatesConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the PEM bundle the client certificates are verified against, empty when they are not verified
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+}
+
+// Certificates keeps the server certificate and the client CA bundle read from the files, Run re-reads them,
+// so that the renewed certificates are used by the new connections without restarting the servers
+type Certificates struct {
+	config CertificatesConfig
+
+	mu        sync.RWMutex
+	pem       [][]byte
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// NewCertificates reads the certificates, the error is returned when any of them can't be loaded
+func NewCertificates(config CertificatesConfig) (*Certificates, error) {
+	c := &Certificates{config: config}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload reads the files again, the certificates are replaced only when all of them are valid.
+// It returns false when the files didn't change.
+func (c *Certificates) Reload() (bool, error) {
+	files := []string{c.config.CertFile, c.config.KeyFile}
+	if c.config.ClientCAFile != "" {
+		files = append(files, c.config.ClientCAFile)
+	}
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		contents[i] = content
+	}
+
+	c.mu.RLock()
+	unchanged := slices.EqualFunc(c.pem, contents, bytes.Equal)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return false, fmt.Errorf("invalid certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if c.config.ClientCAFile != "" {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents[2]) {
+			return false, errors.New("invalid client CA bundle: no certificates found")
+		}
+	}
+
+	c.mu.Lock()
+	c.pem, c.cert, c.clientCAs = contents, &cert, clientCAs
+	c.mu.Unlock()
+
+	return true, nil
+}
+
+// Run reloads the certificates every interval, until the context is done. When the files are invalid,
+// e.g. caught in the middle of the renewal, the error is logged and the previous certificates are kept.
+func (c *Certificates) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := c.Reload()
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to reload the certificates", zap.Error(err))
+		} else if reloaded {
+			logging.FromContext(ctx).Info("certificates reloaded", zap.String("cert_file", c.config.CertFile))
+		}
+	}
+}
+
+// TLSConfig returns the configuration of the servers, every new connection gets the current certificates.
+// Both HTTP/2 and HTTP/1.1 are offered with ALPN.
+func (c *Certificates) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: c.config.ClientAuth,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			return c.cert, nil
+		},
+	}
+	// the client CAs can't be looked up per connection the way the certificate is, so the whole configuration is
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		ret := config.Clone()
+		ret.GetConfigForClient = nil
+		ret.ClientCAs = c.clientCAs
+		return ret, nil
+	}
+
+	return config
+}
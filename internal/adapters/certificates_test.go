@@ -0,0 +1,185 @@
+package adapters
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCertificate creates a certificate signed by the parent, a self-signed CA when the parent is nil
+func newTestCertificate(t *testing.T, commonName string, parent *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCertificate{cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c testCertificate) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c testCertificate) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	require.NoError(t, err)
+	return cert
+}
+
+// writeCertificates writes the server certificate and the client CA into the directory
+func writeCertificates(t *testing.T, dir string, server, clientCA testCertificate) CertificatesConfig {
+	config := CertificatesConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	require.NoError(t, os.WriteFile(config.CertFile, server.pem, 0o600))
+	require.NoError(t, os.WriteFile(config.KeyFile, server.keyPEM(t), 0o600))
+	require.NoError(t, os.WriteFile(config.ClientCAFile, clientCA.pem, 0o600))
+
+	return config
+}
+
+// handshake connects to a TLS listener using the configuration and returns the certificate the server presented
+func handshake(t *testing.T, serverConfig *tls.Config, clientCert *tls.Certificate, roots *x509.CertPool) (*x509.Certificate, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	clientConfig := &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+	if clientCert != nil {
+		clientConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// the client certificate is verified by the server after the client considers the handshake done,
+	// the rejection is seen when reading - the server closes the connection right after the handshake otherwise
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestCertificates_mutual_TLS(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	server := newTestCertificate(t, "server", &ca)
+	client := newTestCertificate(t, "client", &ca)
+	otherCA := newTestCertificate(t, "other-ca", nil)
+	untrusted := newTestCertificate(t, "untrusted", &otherCA)
+
+	certificates, err := NewCertificates(writeCertificates(t, t.TempDir(), server, ca))
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	clientCert := client.tlsCertificate(t)
+	got, err := handshake(t, certificates.TLSConfig(), &clientCert, roots)
+	require.NoError(t, err)
+	assert.Equal(t, "server", got.Subject.CommonName)
+
+	_, err = handshake(t, certificates.TLSConfig(), nil, roots)
+	assert.Error(t, err, "the client certificate is required")
+
+	untrustedCert := untrusted.tlsCertificate(t)
+	_, err = handshake(t, certificates.TLSConfig(), &untrustedCert, roots)
+	assert.Error(t, err, "the client certificate must be signed by the client CA")
+}
+
+func TestCertificates_Reload(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	dir := t.TempDir()
+	config := writeCertificates(t, dir, newTestCertificate(t, "server", &ca), ca)
+	certificates, err := NewCertificates(config)
+	require.NoError(t, err)
+	tlsConfig := certificates.TLSConfig()
+
+	reloaded, err := certificates.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "the files didn't change")
+
+	writeCertificates(t, dir, newTestCertificate(t, "renewed", &ca), ca)
+	reloaded, err = certificates.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	cert, err := tlsConfig.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "renewed", cert.Leaf.Subject.CommonName, "the configuration handed to the server picks up the renewal")
+
+	require.NoError(t, os.WriteFile(config.CertFile, []byte("half-written"), 0o600))
+	_, err = certificates.Reload()
+	assert.Error(t, err)
+	cert, err = tlsConfig.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "renewed", cert.Leaf.Subject.CommonName, "the previous certificate is kept")
+}
+
+func TestNewCertificates_invalid_files(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	config := writeCertificates(t, t.TempDir(), newTestCertificate(t, "server", &ca), ca)
+	require.NoError(t, os.WriteFile(config.ClientCAFile, []byte("not a certificate"), 0o600))
+
+	_, err := NewCertificates(config)
+	assert.ErrorContains(t, err, "client CA")
+
+	config.CertFile = filepath.Join(t.TempDir(), "missing.crt")
+	_, err = NewCertificates(config)
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"encoding/json"
 	"log"
 	"os"
 	"users-app/domain"
@@ -30,11 +31,34 @@ func NewEventLogger(filename string) *EventLogger {
 	}
 }
 
+// NewConsoleEventLogger creates an EventLogger writing the events to the standard output instead of a file,
+// it's used in the development mode
+func NewConsoleEventLogger() *EventLogger {
+	return &EventLogger{logger: log.New(os.Stdout, "event: ", log.LstdFlags)}
+}
+
+// loggedEvent is the line written to the event log, the payload has no credentials (see domain.Event.Payload)
+type loggedEvent struct {
+	Type     domain.EventMsg        `json:"type"`
+	TenantID domain.TenantID        `json:"tenant_id"`
+	Payload  map[string]interface{} `json:"payload"`
+}
+
 // LogEvent logs an event to the file
 func (e *EventLogger) LogEvent(event domain.Event) {
-	e.logger.Printf("%#v", event)
+	line, err := json.Marshal(loggedEvent{Type: event.Msg, TenantID: event.TenantID, Payload: event.Payload()})
+	if err != nil {
+		e.logger.Printf("failed to serialize the %s event: %v", event.Msg, err)
+		return
+	}
+
+	e.logger.Print(string(line))
 }
 
 func (e *EventLogger) Close() error {
+	if e.file == nil {
+		return nil
+	}
+
 	return e.file.Close()
 }
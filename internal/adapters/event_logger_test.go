@@ -0,0 +1,41 @@
+package adapters
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type addUserCommandStub struct {
+	Email    string `json:"email" redact:"pii"`
+	Password string `json:"-" redact:"secret"`
+}
+
+func (c addUserCommandStub) EncodeEvent() (domain.Event, error) {
+	return domain.Event{Msg: domain.UserAdded, Command: c}, nil
+}
+
+func TestEventLogger_LogEvent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "events.log")
+	logger := NewEventLogger(filename)
+
+	event, _ := addUserCommandStub{Email: "john@doe.com", Password: "p4ssw0rd"}.EncodeEvent()
+	event.TenantID = "arcade"
+	logger.LogEvent(event)
+	require.NoError(t, logger.Close())
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "p4ssw0rd")
+
+	line := string(content)
+	var logged loggedEvent
+	require.NoError(t, json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &logged))
+	assert.Equal(t, loggedEvent{Type: domain.UserAdded, TenantID: "arcade", Payload: map[string]interface{}{"email": "john@doe.com"}}, logged)
+}
@@ -0,0 +1,128 @@
+package adapters
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+	"users-app/domain"
+)
+
+// EventStream is an in-process domain.Publisher, which numbers published events, keeps a bounded history
+// of them and fans them out to the subscribers (e.g. Server-Sent Events connections).
+//
+// Publishing never blocks on subscribers. Every subscription has its own buffer, and a subscriber
+// which doesn't keep up with the publisher gets dropped - its events channel gets closed.
+// The subscriber can then reconnect and resume from the last event it has seen, as long as the event
+// is still kept in the history. The IDs are numbered within the epoch of the stream, which is new for every
+// process, so the IDs seen before a restart (or by another instance) are told apart and the subscriber gets reset.
+type EventStream struct {
+	mu          sync.Mutex
+	epoch       string
+	lastID      uint64
+	history     []domain.SequencedEvent
+	historySize int
+	bufferSize  int
+	subscribers map[*subscription]struct{}
+}
+
+// NewEventStream creates an EventStream that remembers up to historySize last events
+// and buffers up to bufferSize events per subscriber
+func NewEventStream(historySize, bufferSize int) *EventStream {
+	return &EventStream{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*subscription]struct{}),
+	}
+}
+
+// PublishEvent assigns the next ID to the event, stores it in the history and delivers it to all subscribers
+func (s *EventStream) PublishEvent(_ context.Context, event domain.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	sequenced := domain.SequencedEvent{Epoch: s.epoch, ID: s.lastID, Event: event}
+
+	s.history = append(s.history, sequenced)
+	if len(s.history) > s.historySize {
+		s.history = s.history[len(s.history)-s.historySize:]
+	}
+
+	for sub := range s.subscribers {
+		select {
+		case sub.events <- sequenced:
+		default:
+			// the subscriber is too slow, it gets dropped instead of stalling the publisher
+			s.unsubscribe(sub)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe implements domain.EventSubscriber
+func (s *EventStream) Subscribe(after *domain.EventPosition) domain.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := &subscription{
+		stream: s,
+		events: make(chan domain.SequencedEvent, s.bufferSize),
+	}
+
+	if after != nil {
+		if s.retains(*after) {
+			for _, event := range s.history {
+				if event.ID > after.ID {
+					sub.backlog = append(sub.backlog, event)
+				}
+			}
+		} else {
+			sub.reset = &domain.EventPosition{Epoch: s.epoch, ID: s.lastID}
+		}
+	}
+
+	s.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// retains tells whether all the events following the position are kept in the history, it needs to be called
+// with the lock held
+func (s *EventStream) retains(position domain.EventPosition) bool {
+	if position.Epoch != s.epoch || position.ID > s.lastID {
+		return false
+	}
+
+	return position.ID == s.lastID || (len(s.history) > 0 && s.history[0].ID <= position.ID+1)
+}
+
+// unsubscribe needs to be called with the lock held
+func (s *EventStream) unsubscribe(sub *subscription) {
+	if _, ok := s.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(s.subscribers, sub)
+	close(sub.events)
+}
+
+type subscription struct {
+	stream  *EventStream
+	backlog []domain.SequencedEvent
+	reset   *domain.EventPosition
+	events  chan domain.SequencedEvent
+}
+
+func (s *subscription) Backlog() []domain.SequencedEvent     { return s.backlog }
+func (s *subscription) Reset() *domain.EventPosition         { return s.reset }
+func (s *subscription) Events() <-chan domain.SequencedEvent { return s.events }
+
+func (s *subscription) Close() {
+	s.stream.mu.Lock()
+	defer s.stream.mu.Unlock()
+
+	s.stream.unsubscribe(s)
+}
@@ -0,0 +1,110 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func publishN(t *testing.T, s *EventStream, n int) {
+	for i := 0; i < n; i++ {
+		assert.NoError(t, s.PublishEvent(context.Background(), domain.Event{Msg: domain.UserAdded}))
+	}
+}
+
+func eventIDs(events []domain.SequencedEvent) []uint64 {
+	ids := make([]uint64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+
+	return ids
+}
+
+func TestEventStream_Subscribe_backlog(t *testing.T) {
+	tests := []struct {
+		name        string
+		historySize int
+		published   int
+		lastEventID *uint64
+		otherEpoch  bool
+
+		expectedBacklog []uint64
+		expectedReset   *uint64
+	}{
+		{name: "new_subscriber_gets_no_backlog", historySize: 10, published: 3},
+		{name: "resumes_after_last_event_id", historySize: 10, published: 5, lastEventID: ptr(3), expectedBacklog: []uint64{4, 5}},
+		{name: "up_to_date_subscriber_gets_no_backlog", historySize: 10, published: 5, lastEventID: ptr(5)},
+		{name: "resumes_from_the_start", historySize: 10, published: 2, lastEventID: ptr(0), expectedBacklog: []uint64{1, 2}},
+		{name: "missed_history_resets", historySize: 2, published: 5, lastEventID: ptr(1), expectedReset: ptr(5)},
+		{name: "unknown_id_resets", historySize: 10, published: 3, lastEventID: ptr(5000), expectedReset: ptr(3)},
+		{name: "other_epoch_resets", historySize: 10, published: 5, lastEventID: ptr(3), otherEpoch: true, expectedReset: ptr(5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewEventStream(tt.historySize, 10)
+			publishN(t, s, tt.published)
+
+			var after *domain.EventPosition
+			if tt.lastEventID != nil {
+				after = &domain.EventPosition{Epoch: s.epoch, ID: *tt.lastEventID}
+				if tt.otherEpoch {
+					after.Epoch = "restarted"
+				}
+			}
+			sub := s.Subscribe(after)
+			defer sub.Close()
+
+			var got []uint64
+			if backlog := sub.Backlog(); len(backlog) > 0 {
+				got = eventIDs(backlog)
+			}
+			assert.Equal(t, tt.expectedBacklog, got)
+			if tt.expectedReset == nil {
+				assert.Nil(t, sub.Reset())
+			} else {
+				assert.Equal(t, &domain.EventPosition{Epoch: s.epoch, ID: *tt.expectedReset}, sub.Reset())
+			}
+		})
+	}
+}
+
+func ptr(id uint64) *uint64 {
+	return &id
+}
+
+func TestEventStream_PublishEvent_delivers_to_subscribers(t *testing.T) {
+	s := NewEventStream(10, 10)
+	sub1, sub2 := s.Subscribe(nil), s.Subscribe(nil)
+	defer sub1.Close()
+	defer sub2.Close()
+
+	publishN(t, s, 2)
+
+	for _, sub := range []domain.Subscription{sub1, sub2} {
+		assert.Equal(t, uint64(1), (<-sub.Events()).ID)
+		assert.Equal(t, uint64(2), (<-sub.Events()).ID)
+	}
+}
+
+func TestEventStream_PublishEvent_drops_slow_subscriber(t *testing.T) {
+	s := NewEventStream(10, 1)
+	slow, fast := s.Subscribe(nil), s.Subscribe(nil)
+	defer fast.Close()
+
+	publishN(t, s, 1)
+	assert.Equal(t, uint64(1), (<-fast.Events()).ID)
+
+	// the slow subscriber's buffer is full, the publisher must not block on it
+	publishN(t, s, 1)
+	assert.Equal(t, uint64(2), (<-fast.Events()).ID)
+
+	assert.Equal(t, uint64(1), (<-slow.Events()).ID)
+	_, open := <-slow.Events()
+	assert.False(t, open, "slow subscriber should be dropped")
+
+	// closing a dropped subscription is a no-op
+	slow.Close()
+}
@@ -0,0 +1,117 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"time"
+	"users-app/domain"
+
+	"github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/postgresql"
+)
+
+// idempotencyRepository stores the idempotency keys in postgres
+type idempotencyRepository struct {
+	db           db.Session
+	writeTimeout time.Duration
+}
+
+func NewIdempotencyRepository(repositoryConfig RepoConfig) idempotencyRepository {
+	return idempotencyRepository{db: openSession(repositoryConfig), writeTimeout: repositoryConfig.WriteTimeout}
+}
+
+// Close closes the database session
+func (r idempotencyRepository) Close() error {
+	return r.db.Close()
+}
+
+type IdempotencyRecordDTO struct {
+	Operation     string           `db:"operation"`
+	Key           string           `db:"key"`
+	Fingerprint   string           `db:"fingerprint"`
+	Completed     bool             `db:"completed"`
+	Response      postgresql.JSONB `db:"response"`
+	CreatedAt     time.Time        `db:"created_at"`
+	ExpiresAt     time.Time        `db:"expires_at"`
+	ReservedUntil time.Time        `db:"reserved_until"`
+}
+
+// reserveQuery inserts the record, or replaces the one which is reservable (see domain.IdempotencyRecord.Reservable),
+// relying on the primary key (operation, key), so that only one of the concurrent requests with the same key gets
+// the reservation. The record is returned only when it's reserved.
+const reserveQuery = `
+INSERT INTO idempotency_keys (operation, key, fingerprint, completed, response, created_at, expires_at, reserved_until)
+VALUES (?, ?, ?, FALSE, '{}', ?, ?, ?)
+ON CONFLICT (operation, key) DO UPDATE SET
+	fingerprint = EXCLUDED.fingerprint, completed = FALSE, response = '{}', created_at = EXCLUDED.created_at,
+	expires_at = EXCLUDED.expires_at, reserved_until = EXCLUDED.reserved_until
+WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+	OR (NOT idempotency_keys.completed AND idempotency_keys.reserved_until < EXCLUDED.created_at)
+RETURNING operation`
+
+// reserveAttempts limits the retries of Reserve, when the existing record is released before it's read
+const reserveAttempts = 3
+
+func (r idempotencyRepository) Reserve(ctx context.Context, record domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	sess, cancel := sessionWithTimeout(ctx, r.db, r.writeTimeout)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		var reserved []IdempotencyRecordDTO
+		err := sess.SQL().Iterator(reserveQuery, record.Operation, record.Key, record.Fingerprint,
+			record.CreatedAt, record.ExpiresAt, record.ReservedUntil).All(&reserved)
+		if err != nil {
+			return domain.IdempotencyRecord{}, false, err
+		}
+		if len(reserved) > 0 {
+			return record, true, nil
+		}
+
+		var existing IdempotencyRecordDTO
+		existing.Response.Data = &UserDTO{}
+		err = sess.Collection("idempotency_keys").Find(db.Cond{"operation": record.Operation, "key": record.Key}).One(&existing)
+		if errors.Is(err, db.ErrNoMoreRows) && attempt < reserveAttempts {
+			// the record was released in the meantime, the key can be reserved again
+			continue
+		}
+		if err != nil {
+			return domain.IdempotencyRecord{}, false, err
+		}
+
+		return domain.IdempotencyRecord{
+			Operation:     existing.Operation,
+			Key:           existing.Key,
+			Fingerprint:   existing.Fingerprint,
+			Completed:     existing.Completed,
+			User:          toDomain(*existing.Response.Data.(*UserDTO)),
+			CreatedAt:     existing.CreatedAt,
+			ExpiresAt:     existing.ExpiresAt,
+			ReservedUntil: existing.ReservedUntil,
+		}, false, nil
+	}
+}
+
+// Complete stores the user created by the original request, without the password hash
+func (r idempotencyRepository) Complete(ctx context.Context, record domain.IdempotencyRecord, user domain.User) error {
+	sess, cancel := sessionWithTimeout(ctx, r.db, r.writeTimeout)
+	defer cancel()
+
+	user.PasswordHash = ""
+	return reservation(sess, record).Update(map[string]interface{}{
+		"completed": true,
+		"response":  postgresql.JSONB{Data: fromDomain(user)},
+	})
+}
+
+func (r idempotencyRepository) Release(ctx context.Context, record domain.IdempotencyRecord) error {
+	sess, cancel := sessionWithTimeout(ctx, r.db, r.writeTimeout)
+	defer cancel()
+
+	return reservation(sess, record).Delete()
+}
+
+// reservation finds the record of the reservation, it's not found once the key is reserved again
+func reservation(sess db.Session, record domain.IdempotencyRecord) db.Result {
+	return sess.Collection("idempotency_keys").
+		Find(db.Cond{"operation": record.Operation, "key": record.Key, "created_at": record.CreatedAt})
+}
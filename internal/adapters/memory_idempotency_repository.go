@@ -0,0 +1,75 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+	"users-app/domain"
+)
+
+type idempotencyKey struct {
+	operation string
+	key       string
+}
+
+// MemoryIdempotencyRepository is a domain.IdempotencyRepository keeping the records in memory,
+// it's safe for concurrent use
+type MemoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[idempotencyKey]domain.IdempotencyRecord
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{records: make(map[idempotencyKey]domain.IdempotencyRecord)}
+}
+
+// Reserve stores the record, unless a record with the same operation and key, which is not reservable, already exists
+func (r *MemoryIdempotencyRepository) Reserve(_ context.Context, record domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := idempotencyKey{record.Operation, record.Key}
+	// expired keys are removed lazily, when the key gets used again
+	if existing, ok := r.records[key]; ok && !existing.Reservable(record.CreatedAt) {
+		return existing, false, nil
+	}
+	r.records[key] = record
+
+	return record, true, nil
+}
+
+// Complete stores the user created by the original request, without the password hash
+func (r *MemoryIdempotencyRepository) Complete(_ context.Context, reserved domain.IdempotencyRecord, user domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.reserved(reserved)
+	if !ok {
+		return nil
+	}
+	user.PasswordHash = ""
+	record.Completed = true
+	record.User = user
+	r.records[idempotencyKey{record.Operation, record.Key}] = record
+
+	return nil
+}
+
+func (r *MemoryIdempotencyRepository) Release(_ context.Context, reserved domain.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.reserved(reserved); ok {
+		delete(r.records, idempotencyKey{reserved.Operation, reserved.Key})
+	}
+	return nil
+}
+
+// reserved returns the stored record of the reservation, it's not found once the key is reserved again
+func (r *MemoryIdempotencyRepository) reserved(reserved domain.IdempotencyRecord) (domain.IdempotencyRecord, bool) {
+	record, ok := r.records[idempotencyKey{reserved.Operation, reserved.Key}]
+	if !ok || !record.CreatedAt.Equal(reserved.CreatedAt) {
+		return domain.IdempotencyRecord{}, false
+	}
+
+	return record, true
+}
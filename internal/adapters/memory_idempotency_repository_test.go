@@ -0,0 +1,68 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIdempotencyRepository(t *testing.T) {
+	repo := NewMemoryIdempotencyRepository()
+	ctx := context.Background()
+	now := time.Now()
+	record := domain.IdempotencyRecord{
+		Operation: "AddUser", Key: "key", Fingerprint: "a", CreatedAt: now, ExpiresAt: now.Add(time.Hour), ReservedUntil: now.Add(time.Minute),
+	}
+
+	_, reserved, err := repo.Reserve(ctx, record)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	user := domain.User{ID: domain.NewUserID(), PasswordHash: "hash"}
+	require.NoError(t, repo.Complete(ctx, record, user))
+
+	retry := record
+	retry.CreatedAt = now.Add(2 * time.Minute)
+	existing, reserved, err := repo.Reserve(ctx, retry)
+	require.NoError(t, err)
+	assert.False(t, reserved, "the completed key is taken until it expires")
+	assert.True(t, existing.Completed)
+	assert.Equal(t, user.ID, existing.User.ID)
+	assert.Empty(t, existing.User.PasswordHash)
+
+	expired := domain.IdempotencyRecord{Operation: "AddUser", Key: "key", CreatedAt: now.Add(2 * time.Hour)}
+	_, reserved, _ = repo.Reserve(ctx, expired)
+	assert.True(t, reserved, "expired keys can be reused")
+
+	require.NoError(t, repo.Release(ctx, expired))
+	_, reserved, _ = repo.Reserve(ctx, record)
+	assert.True(t, reserved, "released keys can be reused")
+}
+
+func TestMemoryIdempotencyRepository_Reserve_takes_over_the_ended_reservation(t *testing.T) {
+	repo := NewMemoryIdempotencyRepository()
+	ctx := context.Background()
+	now := time.Now()
+	crashed := domain.IdempotencyRecord{Operation: "AddUser", Key: "key", CreatedAt: now, ExpiresAt: now.Add(time.Hour), ReservedUntil: now.Add(time.Minute)}
+	_, reserved, err := repo.Reserve(ctx, crashed)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	retry := crashed
+	retry.CreatedAt, retry.ReservedUntil = now.Add(30*time.Second), now.Add(90*time.Second)
+	_, reserved, _ = repo.Reserve(ctx, retry)
+	assert.False(t, reserved, "the request is still in progress")
+
+	retry.CreatedAt, retry.ReservedUntil = now.Add(2*time.Minute), now.Add(3*time.Minute)
+	_, reserved, _ = repo.Reserve(ctx, retry)
+	assert.True(t, reserved, "the reservation of the crashed request has ended")
+
+	require.NoError(t, repo.Release(ctx, crashed))
+	existing, reserved, _ := repo.Reserve(ctx, crashed)
+	assert.False(t, reserved, "the late release of the crashed request leaves the new reservation")
+	assert.Equal(t, retry.CreatedAt, existing.CreatedAt)
+}
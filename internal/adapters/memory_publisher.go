@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"users-app/domain"
+)
+
+// EventHandler handles the events delivered by MemoryPublisher
+type EventHandler func(context.Context, domain.Event) error
+
+// MemoryPublisher is an in-process domain.Publisher, safe for concurrent use.
+// Every event is delivered synchronously to all the subscribed handlers and kept, so that it can be checked
+// with Events, e.g. in the tests.
+type MemoryPublisher struct {
+	mu       sync.RWMutex
+	handlers []EventHandler
+	events   []domain.Event
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Subscribe registers the handler, it gets all the events published from now on
+func (p *MemoryPublisher) Subscribe(handler EventHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers = append(p.handlers, handler)
+}
+
+// PublishEvent keeps the event and delivers it to all the handlers, the errors of the handlers are returned together
+func (p *MemoryPublisher) PublishEvent(ctx context.Context, event domain.Event) error {
+	p.mu.Lock()
+	p.events = append(p.events, event)
+	handlers := append([]EventHandler{}, p.handlers...)
+	p.mu.Unlock()
+
+	// the handlers are called without the lock, so that they can publish events on their own
+	var errs []error
+	for _, handler := range handlers {
+		errs = append(errs, handler(ctx, event))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Events returns all the events published so far, in the order they were published
+func (p *MemoryPublisher) Events() []domain.Event {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]domain.Event{}, p.events...)
+}
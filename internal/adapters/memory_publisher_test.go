@@ -0,0 +1,33 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPublisher_PublishEvent(t *testing.T) {
+	publisher := NewMemoryPublisher()
+	errFailed := errors.New("failed")
+
+	var handled []domain.EventMsg
+	publisher.Subscribe(func(_ context.Context, event domain.Event) error {
+		handled = append(handled, event.Msg)
+		return nil
+	})
+	publisher.Subscribe(func(_ context.Context, event domain.Event) error {
+		if event.Msg == domain.UserDeleted {
+			return errFailed
+		}
+		return nil
+	})
+
+	assert.NoError(t, publisher.PublishEvent(context.Background(), domain.Event{Msg: domain.UserAdded}))
+	assert.ErrorIs(t, publisher.PublishEvent(context.Background(), domain.Event{Msg: domain.UserDeleted}), errFailed)
+
+	assert.Equal(t, []domain.EventMsg{domain.UserAdded, domain.UserDeleted}, handled, "all the handlers get every event")
+	assert.Equal(t, []domain.Event{{Msg: domain.UserAdded}, {Msg: domain.UserDeleted}}, publisher.Events())
+}
@@ -0,0 +1,220 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"users-app/domain"
+)
+
+// MemoryRepository is a domain.Repository keeping the users in memory, it's safe for concurrent use.
+// It behaves the same way as the Postgres repository (see repositorytest.Run), so it can replace it
+// in the tests and in the development mode. Users are returned in the order they were added.
+// The users of all the tenants are kept together, the operations skip the users of the other tenants.
+type MemoryRepository struct {
+	mu    sync.RWMutex
+	users []domain.User
+}
+
+func NewMemoryRepository(users ...domain.User) *MemoryRepository {
+	return &MemoryRepository{users: append([]domain.User{}, users...)}
+}
+
+// AddUser adds a new user to the repository
+// In case of a duplicate id, domain.ErrUserAlreadyExists is returned
+func (r *MemoryRepository) AddUser(ctx context.Context, user domain.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the ids are unique across the tenants, the same as the primary key of the users table
+	for _, existing := range r.users {
+		if existing.ID == user.ID {
+			return domain.ErrUserAlreadyExists
+		}
+	}
+	r.users = append(r.users, user)
+
+	return nil
+}
+
+// ModifyUser updates the given fields of the user and returns the modified user
+// In case the user doesn't exist, domain.ErrUserNotFound is returned
+func (r *MemoryRepository) ModifyUser(ctx context.Context, id domain.UserID, fields domain.Fields) (domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.User{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.find(domain.TenantFromContext(ctx), id)
+	if i < 0 {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+
+	user := r.users[i]
+	for field, value := range fields {
+		ptr := userField(&user, field)
+		if ptr == nil {
+			return domain.User{}, fmt.Errorf("failed to update user: unknown field %q", field)
+		}
+		*ptr = value
+	}
+	r.users[i] = user
+
+	return user, nil
+}
+
+// RemoveUser removes the user, removing a user which doesn't exist is not an error
+func (r *MemoryRepository) RemoveUser(ctx context.Context, id domain.UserID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.find(domain.TenantFromContext(ctx), id); i >= 0 {
+		r.users = append(r.users[:i], r.users[i+1:]...)
+	}
+
+	return nil
+}
+
+// Users returns a list of users that match the given filter
+// and are paginated according to the given pagination, the zero pagination means no limit
+func (r *MemoryRepository) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := domain.TenantFromContext(ctx)
+	ret := make([]domain.User, 0)
+	for _, user := range r.users {
+		if user.TenantID == tenant && matches(filter, user) {
+			ret = append(ret, user)
+		}
+	}
+
+	return paginate(ret, pagination), nil
+}
+
+// CountryCounts counts the users of every country, ordered by the country
+func (r *MemoryRepository) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := domain.TenantFromContext(ctx)
+	counts := map[string]int{}
+	for _, user := range r.users {
+		if user.TenantID == tenant {
+			counts[user.Country]++
+		}
+	}
+
+	ret := make([]domain.CountryCount, 0, len(counts))
+	for country, users := range counts {
+		ret = append(ret, domain.CountryCount{Country: country, Users: users})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Country < ret[j].Country })
+
+	return ret, nil
+}
+
+// paginate returns the page of the items, the zero pagination means no limit
+func paginate[T any](items []T, pagination domain.Pagination) []T {
+	if pagination.Offset >= len(items) {
+		return items[:0]
+	}
+	items = items[pagination.Offset:]
+
+	if pagination.Limit() > 0 && pagination.Limit() < len(items) {
+		items = items[:pagination.Limit()]
+	}
+
+	return items
+}
+
+// find returns the index of the user of the tenant, or -1 if there is no such user
+func (r *MemoryRepository) find(tenant domain.TenantID, id domain.UserID) int {
+	for i, user := range r.users {
+		if user.ID == id && user.TenantID == tenant {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// userField returns the pointer to the field of the user, named the same way as the database column,
+// or nil if there is no such field
+func userField(user *domain.User, field domain.Field) *string {
+	switch field {
+	case "first_name":
+		return &user.FirstName
+	case "last_name":
+		return &user.LastName
+	case "nickname":
+		return &user.Nickname
+	case "email":
+		return &user.Email
+	case "country":
+		return &user.Country
+	default:
+		return nil
+	}
+}
+
+// matches reports whether the user matches all the filters which are set
+func matches(filter domain.Filter, user domain.User) bool {
+	conditions := []struct {
+		filter *string
+		value  string
+	}{
+		{filter.FirstName(), user.FirstName},
+		{filter.LastName(), user.LastName},
+		{filter.Nickname(), user.Nickname},
+		{filter.Country(), user.Country},
+	}
+
+	for _, condition := range conditions {
+		if condition.filter != nil && *condition.filter != condition.value {
+			return false
+		}
+	}
+	if filter.Email() != nil && !strings.EqualFold(*filter.Email(), user.Email) {
+		return false
+	}
+
+	return filter.Search() == nil || matchesSearch(*filter.Search(), user)
+}
+
+// matchesSearch tells if every word of the search is one of the words of the names or the email of the user,
+// ignoring the case. Unlike the full-text search of Postgres, it doesn't support the web search syntax.
+func matchesSearch(search string, user domain.User) bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(strings.ToLower(user.FirstName + " " + user.LastName + " " + user.Nickname + " " + user.Email)) {
+		words[word] = true
+	}
+
+	for _, word := range strings.Fields(strings.ToLower(search)) {
+		if !words[word] {
+			return false
+		}
+	}
+
+	return true
+}
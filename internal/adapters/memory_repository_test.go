@@ -0,0 +1,27 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"users-app/adapters/repositorytest"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T, users []domain.User) domain.Repository {
+		return NewMemoryRepository(users...)
+	})
+}
+
+func TestMemoryRepository_ModifyUser_unknown_field(t *testing.T) {
+	user := domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "John"}
+	repo := NewMemoryRepository(user)
+
+	_, err := repo.ModifyUser(context.Background(), user.ID, domain.Fields{"first_name": "Alex", "password_hash": "hash"})
+	assert.ErrorContains(t, err, "unknown field")
+
+	users, _ := repo.Users(context.Background(), domain.Filter{}, domain.Pagination{})
+	assert.Equal(t, []domain.User{user}, users)
+}
@@ -0,0 +1,150 @@
+package adapters
+
+import (
+	"sort"
+	"sync"
+	"users-app/domain"
+)
+
+// MemoryWebhookRepository is a domain.WebhookRepository keeping the subscriptions and deliveries in memory,
+// it's safe for concurrent use
+type MemoryWebhookRepository struct {
+	mu            sync.RWMutex
+	subscriptions map[domain.WebhookID]domain.WebhookSubscription
+	deliveries    map[domain.WebhookID][]domain.WebhookDelivery
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		subscriptions: make(map[domain.WebhookID]domain.WebhookSubscription),
+		deliveries:    make(map[domain.WebhookID][]domain.WebhookDelivery),
+	}
+}
+
+func (r *MemoryWebhookRepository) AddSubscription(subscription domain.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+// UpdateSubscription overwrites the stored subscription with the given one
+func (r *MemoryWebhookRepository) UpdateSubscription(subscription domain.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.find(subscription.TenantID, subscription.ID); !ok {
+		return domain.ErrWebhookNotFound
+	}
+	r.subscriptions[subscription.ID] = subscription
+
+	return nil
+}
+
+// RemoveSubscription removes the subscription together with its deliveries log
+func (r *MemoryWebhookRepository) RemoveSubscription(tenant domain.TenantID, id domain.WebhookID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.find(tenant, id); ok {
+		delete(r.subscriptions, id)
+		delete(r.deliveries, id)
+	}
+
+	return nil
+}
+
+func (r *MemoryWebhookRepository) Subscription(tenant domain.TenantID, id domain.WebhookID) (domain.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscription, ok := r.find(tenant, id)
+	if !ok {
+		return domain.WebhookSubscription{}, domain.ErrWebhookNotFound
+	}
+
+	return subscription, nil
+}
+
+// Subscriptions returns the subscriptions in the order they were created
+func (r *MemoryWebhookRepository) Subscriptions(tenant domain.TenantID, pagination domain.Pagination) ([]domain.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := make([]domain.WebhookSubscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		if subscription.TenantID == tenant {
+			ret = append(ret, subscription)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].CreatedAt.Before(ret[j].CreatedAt) })
+
+	return paginate(ret, pagination), nil
+}
+
+func (r *MemoryWebhookRepository) EnabledSubscriptions(tenant domain.TenantID) ([]domain.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ret []domain.WebhookSubscription
+	for _, subscription := range r.subscriptions {
+		if subscription.TenantID == tenant && subscription.Enabled {
+			ret = append(ret, subscription)
+		}
+	}
+
+	return ret, nil
+}
+
+// RegisterDeliveryResult updates the failures counter under the lock, so that it's atomic
+func (r *MemoryWebhookRepository) RegisterDeliveryResult(
+	tenant domain.TenantID, id domain.WebhookID, succeeded bool, maxConsecutiveFailures int,
+) (domain.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscription, ok := r.find(tenant, id)
+	if !ok {
+		return domain.WebhookSubscription{}, domain.ErrWebhookNotFound
+	}
+	subscription.RegisterDeliveryResult(succeeded, maxConsecutiveFailures)
+	r.subscriptions[id] = subscription
+
+	return subscription, nil
+}
+
+func (r *MemoryWebhookRepository) AddDelivery(delivery domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries[delivery.SubscriptionID] = append(r.deliveries[delivery.SubscriptionID], delivery)
+	return nil
+}
+
+// Deliveries returns the deliveries log of a subscription, the most recent deliveries first
+func (r *MemoryWebhookRepository) Deliveries(tenant domain.TenantID, id domain.WebhookID, pagination domain.Pagination) ([]domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.find(tenant, id); !ok {
+		return []domain.WebhookDelivery{}, nil
+	}
+	deliveries := r.deliveries[id]
+	ret := make([]domain.WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		ret[len(deliveries)-1-i] = delivery
+	}
+
+	return paginate(ret, pagination), nil
+}
+
+// find returns the subscription of the tenant, the ones of the other tenants are not found
+func (r *MemoryWebhookRepository) find(tenant domain.TenantID, id domain.WebhookID) (domain.WebhookSubscription, bool) {
+	subscription, ok := r.subscriptions[id]
+	if !ok || subscription.TenantID != tenant {
+		return domain.WebhookSubscription{}, false
+	}
+
+	return subscription, true
+}
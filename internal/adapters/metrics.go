@@ -0,0 +1,128 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"users-app/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// repositoryMetrics measures the duration of every repository operation, by operation and result
+type repositoryMetrics struct {
+	wrapped  domain.Repository
+	duration *prometheus.HistogramVec
+}
+
+func NewRepositoryMetricsWrapper(registerer prometheus.Registerer, wrapped domain.Repository) domain.Repository {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "users_repository_query_duration_seconds",
+		Help:    "Duration of the repository operations, by operation and result.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "result"})
+	registerer.MustRegister(duration)
+
+	return repositoryMetrics{wrapped, duration}
+}
+
+func (r repositoryMetrics) AddUser(ctx context.Context, user domain.User) error {
+	start := time.Now()
+	err := r.wrapped.AddUser(ctx, user)
+	r.observe("AddUser", start, err)
+
+	return err
+}
+
+func (r repositoryMetrics) ModifyUser(ctx context.Context, id domain.UserID, fields domain.Fields) (domain.User, error) {
+	start := time.Now()
+	user, err := r.wrapped.ModifyUser(ctx, id, fields)
+	r.observe("ModifyUser", start, err)
+
+	return user, err
+}
+
+func (r repositoryMetrics) RemoveUser(ctx context.Context, id domain.UserID) error {
+	start := time.Now()
+	err := r.wrapped.RemoveUser(ctx, id)
+	r.observe("RemoveUser", start, err)
+
+	return err
+}
+
+func (r repositoryMetrics) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	start := time.Now()
+	users, err := r.wrapped.Users(ctx, filter, pagination)
+	r.observe("Users", start, err)
+
+	return users, err
+}
+
+func (r repositoryMetrics) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	start := time.Now()
+	counts, err := r.wrapped.CountryCounts(ctx)
+	r.observe("CountryCounts", start, err)
+
+	return counts, err
+}
+
+func (r repositoryMetrics) observe(operation string, start time.Time, err error) {
+	r.duration.WithLabelValues(operation, result(err)).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDBStats exposes the connection pool statistics of the database (open, in use, idle connections, waits etc.)
+func RegisterDBStats(registerer prometheus.Registerer, db *sql.DB) {
+	registerer.MustRegister(collectors.NewDBStatsCollector(db, "users"))
+}
+
+// PublisherMetrics counts the published events, by publisher, event and result,
+// and tracks the number of events being published at the moment
+type PublisherMetrics struct {
+	total    *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+}
+
+func NewPublisherMetrics(registerer prometheus.Registerer) PublisherMetrics {
+	total := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "users_events_published_total",
+		Help: "Number of published events, by publisher, event and result.",
+	}, []string{"publisher", "event", "result"})
+	inFlight := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "users_events_publish_in_flight",
+		Help: "Number of events being published at the moment, by publisher.",
+	}, []string{"publisher"})
+	registerer.MustRegister(total, inFlight)
+
+	return PublisherMetrics{total, inFlight}
+}
+
+// Wrap returns a publisher, which reports the metrics of the wrapped one under the given name
+func (m PublisherMetrics) Wrap(name string, wrapped domain.Publisher) domain.Publisher {
+	return metricsPublisher{name, wrapped, m}
+}
+
+type metricsPublisher struct {
+	name    string
+	wrapped domain.Publisher
+	metrics PublisherMetrics
+}
+
+func (p metricsPublisher) PublishEvent(ctx context.Context, event domain.Event) error {
+	inFlight := p.metrics.inFlight.WithLabelValues(p.name)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	err := p.wrapped.PublishEvent(ctx, event)
+	p.metrics.total.WithLabelValues(p.name, string(event.Msg), result(err)).Inc()
+
+	return err
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
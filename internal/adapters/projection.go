@@ -0,0 +1,591 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"users-app/domain"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/upper/db/v4"
+	"go.uber.org/zap"
+)
+
+// usersProjection is the name of the checkpoint of the users projections
+const usersProjection = "users"
+
+type ProjectionConfig struct {
+	// PollInterval is the interval of checking for the new events
+	PollInterval time.Duration
+	// BatchSize is the maximum number of the events applied, or pruned, in one transaction
+	BatchSize int
+	// EventsRetention is the time the applied events are kept for before they can be pruned, 0 keeps all the events
+	EventsRetention time.Duration
+	// PruneInterval is the interval of pruning the events, see Prune
+	PruneInterval time.Duration
+}
+
+// Projection builds the projections of the users from user_events: users_view and users_country_counts, which are
+// read by ReadModel. Every batch of the events is applied in a transaction together with the update of the
+// checkpoint (the position of the last applied event), so every event is applied exactly once. The instances take
+// turns: the one which finds the checkpoint locked skips the batch, another one is applying it.
+//
+// The events are ordered by the transactions which appended them (tx_id) and then by id. The ids are not enough,
+// the transactions can commit in a different order than they got them. An event is read only once its transaction
+// is older than every transaction still running (pg_snapshot_xmin), so no event can be committed behind the
+// checkpoint later, and the writes don't need to be serialized.
+//
+// The applied events are published once their batch is committed, with the users after the changes, so that the
+// readers of the projections can tell when they see the changes.
+//
+// The events no longer needed are pruned, see Prune.
+type Projection struct {
+	db      db.Session
+	config  ProjectionConfig
+	applied prometheus.Counter
+	pruned  prometheus.Counter
+	// publisher gets the applied events, so that their changes can be followed, e.g. by the query cache
+	publisher domain.Publisher
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+// NewProjection opens a new session, it stops the application in case the database is not reachable
+func NewProjection(registerer prometheus.Registerer, repositoryConfig RepoConfig, config ProjectionConfig) *Projection {
+	sess := openSession(repositoryConfig)
+	_, err := sess.SQL().Exec("INSERT INTO projection_checkpoints (name) VALUES (?) ON CONFLICT DO NOTHING", usersProjection)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	applied := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "users_projection_events_applied_total",
+		Help: "Number of the events applied to the users projections.",
+	})
+	pruned := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "users_projection_events_pruned_total",
+		Help: "Number of the events pruned once they were applied to the users projections.",
+	})
+	registerer.MustRegister(applied, pruned, projectionCollector{db: sess})
+
+	return &Projection{db: sess, config: config, applied: applied, pruned: pruned}
+}
+
+// Start applies the new events every PollInterval in the background, until Close. The applied events are published
+// to the publisher, which can be nil. The events are pruned every PruneInterval, unless EventsRetention is 0.
+func (p *Projection) Start(publisher domain.Publisher) {
+	ctx, stop := context.WithCancel(context.Background())
+	p.publisher, p.stop, p.done = publisher, stop, make(chan struct{})
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.config.PollInterval)
+		defer ticker.Stop()
+		var prune <-chan time.Time
+		if p.config.EventsRetention > 0 {
+			pruneTicker := time.NewTicker(p.config.PruneInterval)
+			defer pruneTicker.Stop()
+			prune = pruneTicker.C
+		}
+		for {
+			if _, err := p.CatchUp(ctx); err != nil && ctx.Err() == nil {
+				zap.L().Error("failed to update the users projections", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-prune:
+				if _, err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+					zap.L().Error("failed to prune the events of the users projections", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the updates and closes the session
+func (p *Projection) Close() error {
+	if p.stop != nil {
+		p.stop()
+		<-p.done
+	}
+
+	return p.db.Close()
+}
+
+// CatchUp applies the events which were not applied yet and returns their number. Every batch of the events is
+// published once it's committed, when there is a publisher.
+func (p *Projection) CatchUp(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		events, err := p.catchUpBatch(ctx)
+		total += len(events)
+		p.publish(ctx, events)
+		if err != nil || len(events) < p.config.BatchSize {
+			return total, err
+		}
+	}
+}
+
+// publish publishes the applied events, the failures are only logged as the events are applied already
+func (p *Projection) publish(ctx context.Context, events []domain.Event) {
+	if p.publisher == nil {
+		return
+	}
+	for _, event := range events {
+		if err := p.publisher.PublishEvent(ctx, event); err != nil {
+			zap.L().Warn("failed to publish the event of the users projections", zap.String("event", string(event.Msg)),
+				zap.Error(err))
+		}
+	}
+}
+
+// eventPosition is the position of an event in user_events, the checkpoints are kept as positions
+type eventPosition struct {
+	txID uint64
+	id   int64
+}
+
+func (p *Projection) catchUpBatch(ctx context.Context) ([]domain.Event, error) {
+	var events []domain.Event
+	err := p.db.WithContext(ctx).Tx(func(tx db.Session) error {
+		checkpoint, err := lockCheckpoint(tx, "FOR UPDATE SKIP LOCKED")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var last eventPosition
+		if events, last, err = p.apply(tx, liveTables, checkpoint); err != nil || len(events) == 0 {
+			return err
+		}
+		return saveCheckpoint(tx, last)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.applied.Add(float64(len(events)))
+
+	return events, nil
+}
+
+// projectionTables are the tables of the projections applyEvent updates
+type projectionTables struct {
+	users         string
+	countryCounts string
+}
+
+var (
+	// liveTables are the projections read by the queries
+	liveTables = projectionTables{users: "users_view", countryCounts: "users_country_counts"}
+	// rebuildTables are the projections built by Rebuild, until they replace the contents of the live ones
+	rebuildTables = projectionTables{users: "users_view_rebuild", countryCounts: "users_country_counts_rebuild"}
+)
+
+// Rebuild builds the projections from all the events again and returns the number of the events. The events are
+// applied to the rebuild tables in batches, every one in its own transaction, while the instances keep applying
+// the new events to the projections. Once the rebuild tables catch up, they replace the contents of the projections
+// in a single transaction, so the queries keep reading the old projections until then. Only one rebuild can run
+// at a time, and the events are not pruned until it's done.
+func (p *Projection) Rebuild(ctx context.Context) (int, error) {
+	unlock, err := lockRebuild(ctx, p.db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock the rebuild: %w", err)
+	}
+	defer unlock()
+
+	sess := p.db.WithContext(ctx)
+	if err := createRebuildTables(sess); err != nil {
+		return 0, fmt.Errorf("failed to create the rebuild tables: %w", err)
+	}
+	defer func() {
+		if err := dropRebuildTables(p.db); err != nil {
+			zap.L().Warn("failed to drop the rebuild tables", zap.Error(err))
+		}
+	}()
+
+	total := 0
+	var position eventPosition
+	for {
+		var events []domain.Event
+		err := sess.Tx(func(tx db.Session) error {
+			var err error
+			events, position, err = p.apply(tx, rebuildTables, position)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		total += len(events)
+		if len(events) < p.config.BatchSize {
+			break
+		}
+	}
+
+	err = sess.Tx(func(tx db.Session) error {
+		// waits for the batch being applied by an instance, the next ones are skipped until the rebuild is done
+		if _, err := lockCheckpoint(tx, "FOR UPDATE"); err != nil {
+			return err
+		}
+		for {
+			events, last, err := p.apply(tx, rebuildTables, position)
+			if err != nil {
+				return err
+			}
+			total, position = total+len(events), last
+			if len(events) < p.config.BatchSize {
+				break
+			}
+		}
+
+		if err := replaceProjections(tx); err != nil {
+			return err
+		}
+		return saveCheckpoint(tx, position)
+	})
+	if err != nil {
+		return 0, err
+	}
+	p.applied.Add(float64(total))
+
+	return total, nil
+}
+
+// rebuildLock is the key of the advisory lock held by Rebuild, Prune takes it shared
+const rebuildLock = 7_573_657_273
+
+// lockRebuild takes the rebuild lock on a connection of its own, it waits for the running prunes. The returned
+// function releases it.
+func lockRebuild(ctx context.Context, sess db.Session) (func(), error) {
+	conn, err := sess.Driver().(*sql.DB).Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", rebuildLock); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return func() {
+		// the lock is released together with the connection otherwise, when the session is closed
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", rebuildLock); err != nil {
+			zap.L().Warn("failed to release the rebuild lock", zap.Error(err))
+		}
+		conn.Close()
+	}, nil
+}
+
+// pruneEventsQuery deletes a batch of the events behind the slowest checkpoint, older than the retention, which are
+// superseded by a later event of their user or belong to a deleted user. The event of the checkpoint is kept, so
+// user_events is never emptied once it has had events.
+const pruneEventsQuery = `
+DELETE FROM user_events WHERE id IN (
+	SELECT e.id FROM user_events e
+	JOIN (SELECT last_tx_id, last_event_id FROM projection_checkpoints ORDER BY last_tx_id, last_event_id LIMIT 1) c
+		ON (e.tx_id, e.id) < (c.last_tx_id, c.last_event_id)
+	WHERE e.created_at < LOCALTIMESTAMP - ? * interval '1 second'
+		AND (e.type = ? OR EXISTS (SELECT 1 FROM user_events l WHERE l.user_id = e.user_id AND (l.tx_id, l.id) > (e.tx_id, e.id)))
+	LIMIT ?
+	FOR UPDATE OF e SKIP LOCKED)`
+
+// Prune removes the applied events which are no longer needed and returns their number: the events of the users
+// followed by later events, as every event carries the whole user, and the events of the deleted users. So a rebuild
+// from the remaining events builds the same projections. Only the events applied to every projection and older than
+// EventsRetention are pruned. The prunes are skipped while a rebuild runs, it would miss the deletions pruned behind
+// its position.
+func (p *Projection) Prune(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		pruned, err := p.pruneBatch(ctx)
+		total += pruned
+		if err != nil || pruned < p.config.BatchSize {
+			p.pruned.Add(float64(total))
+			return total, err
+		}
+	}
+}
+
+func (p *Projection) pruneBatch(ctx context.Context) (int, error) {
+	pruned := 0
+	err := p.db.WithContext(ctx).Tx(func(tx db.Session) error {
+		row, err := tx.SQL().QueryRow("SELECT pg_try_advisory_xact_lock_shared(?)", rebuildLock)
+		if err != nil {
+			return err
+		}
+		var locked bool
+		if err := row.Scan(&locked); err != nil || !locked {
+			return err
+		}
+
+		res, err := tx.SQL().Exec(pruneEventsQuery, p.config.EventsRetention.Seconds(), domain.UserDeleted, p.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		pruned = int(rows)
+		return err
+	})
+
+	return pruned, err
+}
+
+// createRebuildTables creates the empty rebuild tables, they are unlogged as they are dropped after the rebuild anyway
+func createRebuildTables(sess db.Session) error {
+	if err := dropRebuildTables(sess); err != nil {
+		return err
+	}
+	_, err := sess.SQL().Exec("CREATE UNLOGGED TABLE " + rebuildTables.users + " (LIKE " + liveTables.users + ", PRIMARY KEY (id))")
+	if err != nil {
+		return err
+	}
+	_, err = sess.SQL().Exec("CREATE UNLOGGED TABLE " + rebuildTables.countryCounts +
+		" (LIKE " + liveTables.countryCounts + ", PRIMARY KEY (tenant_id, country))")
+
+	return err
+}
+
+func dropRebuildTables(sess db.Session) error {
+	_, err := sess.SQL().Exec("DROP TABLE IF EXISTS " + rebuildTables.users + ", " + rebuildTables.countryCounts)
+	return err
+}
+
+// replaceProjections replaces the contents of the projections with the rebuild tables, the search columns
+// of users_view are generated again
+func replaceProjections(tx db.Session) error {
+	statements := []string{
+		"DELETE FROM " + liveTables.users,
+		"INSERT INTO " + liveTables.users + " (" + usersViewColumns + ") SELECT " + usersViewColumns + " FROM " + rebuildTables.users,
+		"DELETE FROM " + liveTables.countryCounts,
+		"INSERT INTO " + liveTables.countryCounts + " (tenant_id, country, users) SELECT tenant_id, country, users FROM " +
+			rebuildTables.countryCounts,
+	}
+	for _, statement := range statements {
+		if _, err := tx.SQL().Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lockCheckpoint(tx db.Session, lock string) (eventPosition, error) {
+	row, err := tx.SQL().QueryRow("SELECT last_tx_id::text, last_event_id FROM projection_checkpoints WHERE name = ? "+lock,
+		usersProjection)
+	if err != nil {
+		return eventPosition{}, err
+	}
+
+	var checkpoint eventPosition
+	return checkpoint, row.Scan(&checkpoint.txID, &checkpoint.id)
+}
+
+// userEventRow is a row of user_events, as it's read by the projection
+type userEventRow struct {
+	ID       int64     `db:"id"`
+	TxID     uint64    `db:"tx_id"`
+	Type     string    `db:"type"`
+	TenantID string    `db:"tenant_id"`
+	UserID   uuid.UUID `db:"user_id"`
+	UserData string    `db:"user_data"`
+}
+
+// userEventsAfterQuery reads the events following the position, of the transactions older than all the running ones
+const userEventsAfterQuery = `
+SELECT id, tx_id::text AS tx_id, type, tenant_id, user_id, user_data FROM user_events
+WHERE (tx_id, id) > (?::text::xid8, ?) AND tx_id < pg_snapshot_xmin(pg_current_snapshot())
+ORDER BY tx_id, id
+LIMIT ?`
+
+// apply applies a batch of the events following the position to the tables, it returns the applied events, with
+// the users after the changes, and the position of the last one. The checkpoint is left to the caller.
+func (p *Projection) apply(tx db.Session, tables projectionTables, position eventPosition) ([]domain.Event, eventPosition, error) {
+	var rows []userEventRow
+	err := tx.SQL().Iterator(userEventsAfterQuery, strconv.FormatUint(position.txID, 10), position.id, p.config.BatchSize).
+		All(&rows)
+	if err != nil || len(rows) == 0 {
+		return nil, position, err
+	}
+
+	events := make([]domain.Event, len(rows))
+	for i, row := range rows {
+		user, err := applyEvent(tx, tables, row)
+		if err != nil {
+			return nil, position, fmt.Errorf("failed to apply the event %d: %w", row.ID, err)
+		}
+		events[i] = domain.Event{
+			Msg: domain.EventMsg(row.Type), TenantID: domain.TenantID(row.TenantID), UserID: row.UserID, User: user,
+		}
+	}
+
+	return events, eventPosition{txID: rows[len(rows)-1].TxID, id: rows[len(rows)-1].ID}, nil
+}
+
+func saveCheckpoint(tx db.Session, checkpoint eventPosition) error {
+	_, err := tx.SQL().Exec(`UPDATE projection_checkpoints SET last_tx_id = ?::text::xid8, last_event_id = ?, updated_at = now()
+		WHERE name = ?`, strconv.FormatUint(checkpoint.txID, 10), checkpoint.id, usersProjection)
+
+	return err
+}
+
+// usersViewColumns are the columns of users_view populated from the events, the search columns are generated
+const usersViewColumns = "id, tenant_id, first_name, last_name, nickname, email, country, created_at, updated_at"
+
+// applyEvent updates the users and country counts tables and returns the user after the change, nil when there is
+// none. The rows of the users are populated from the user carried by the event, whose fields are named after the
+// columns.
+func applyEvent(tx db.Session, tables projectionTables, event userEventRow) (*domain.User, error) {
+	var data struct {
+		Country string `json:"country"`
+	}
+	if err := json.Unmarshal([]byte(event.UserData), &data); err != nil {
+		return nil, err
+	}
+
+	switch domain.EventMsg(event.Type) {
+	case domain.UserAdded:
+		return addUser(tx, tables, event, data.Country)
+	case domain.UserModified:
+		country, err := viewCountry(tx, tables, event)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the earlier events of the user are pruned, the event carries the whole user
+			return addUser(tx, tables, event, data.Country)
+		}
+		if err != nil {
+			return nil, err
+		}
+		user, err := changedUser(tx, `UPDATE `+tables.users+` v SET first_name = u.first_name, last_name = u.last_name,
+			nickname = u.nickname, email = u.email, country = u.country, updated_at = u.updated_at
+			FROM jsonb_populate_record(NULL::users_view, ?::jsonb) u WHERE v.id = u.id RETURNING v.*`, event.UserData)
+		if err != nil || country == data.Country {
+			return user, err
+		}
+		if err := countUsers(tx, tables, event.TenantID, country, -1); err != nil {
+			return nil, err
+		}
+		return user, countUsers(tx, tables, event.TenantID, data.Country, 1)
+	case domain.UserDeleted:
+		country, err := viewCountry(tx, tables, event)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.SQL().Exec("DELETE FROM "+tables.users+" WHERE id = ?", event.UserID); err != nil {
+			return nil, err
+		}
+		return nil, countUsers(tx, tables, event.TenantID, country, -1)
+	default:
+		return nil, fmt.Errorf("unknown event type %q", event.Type)
+	}
+}
+
+// addUser inserts the user of the event, unless it's there already
+func addUser(tx db.Session, tables projectionTables, event userEventRow, country string) (*domain.User, error) {
+	user, err := changedUser(tx, `INSERT INTO `+tables.users+` (`+usersViewColumns+`)
+		SELECT `+usersViewColumns+` FROM jsonb_populate_record(NULL::users_view, ?::jsonb) ON CONFLICT (id) DO NOTHING
+		RETURNING `+usersViewColumns, event.UserData)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	return user, countUsers(tx, tables, event.TenantID, country, 1)
+}
+
+// changedUser runs the statement returning the changed row of the users table, the user is nil when there is none
+func changedUser(tx db.Session, statement string, args ...interface{}) (*domain.User, error) {
+	var rows []UserDTO
+	if err := tx.SQL().Iterator(statement, args...).All(&rows); err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	user := toDomain(rows[0])
+	return &user, nil
+}
+
+// viewCountry returns the country of the user of the event kept in the users table
+func viewCountry(tx db.Session, tables projectionTables, event userEventRow) (string, error) {
+	row, err := tx.SQL().QueryRow("SELECT country FROM "+tables.users+" WHERE id = ? AND tenant_id = ?", event.UserID, event.TenantID)
+	if err != nil {
+		return "", err
+	}
+
+	var country string
+	return country, row.Scan(&country)
+}
+
+// countUsers adds the delta to the number of the users of the country, the countries without users are removed
+func countUsers(tx db.Session, tables projectionTables, tenant, country string, delta int) error {
+	_, err := tx.SQL().Exec(`INSERT INTO `+tables.countryCounts+` AS c (tenant_id, country, users) VALUES (?, ?, ?)
+		ON CONFLICT (tenant_id, country) DO UPDATE SET users = c.users + EXCLUDED.users`,
+		tenant, country, delta)
+	if err != nil {
+		return err
+	}
+	_, err = tx.SQL().Exec("DELETE FROM "+tables.countryCounts+" WHERE tenant_id = ? AND country = ? AND users <= 0", tenant, country)
+
+	return err
+}
+
+// projectionCollectTimeout limits the queries of a scrape
+const projectionCollectTimeout = 5 * time.Second
+
+var (
+	projectionLagDesc = prometheus.NewDesc("users_projection_lag_events",
+		"Number of the events not applied to the users projections yet.", nil, nil)
+	countryUsersDesc = prometheus.NewDesc("users_country_users",
+		"Number of the users, by tenant and country, as kept by the users projections.", []string{"tenant", "country"}, nil)
+)
+
+// projectionCollector exposes the state of the projections, it's read from the database at every scrape
+type projectionCollector struct {
+	db db.Session
+}
+
+func (c projectionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- projectionLagDesc
+	ch <- countryUsersDesc
+}
+
+func (c projectionCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), projectionCollectTimeout)
+	defer cancel()
+	sqlDB := c.db.Driver().(*sql.DB)
+
+	var lag float64
+	err := sqlDB.QueryRowContext(ctx, `SELECT count(*) FROM user_events e JOIN projection_checkpoints c ON c.name = $1
+		WHERE (e.tx_id, e.id) > (c.last_tx_id, c.last_event_id)`, usersProjection).Scan(&lag)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(projectionLagDesc, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(projectionLagDesc, prometheus.GaugeValue, lag)
+	}
+
+	rows, err := sqlDB.QueryContext(ctx, "SELECT tenant_id, country, users FROM users_country_counts")
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(countryUsersDesc, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tenant, country string
+		var users float64
+		if err := rows.Scan(&tenant, &country, &users); err != nil {
+			ch <- prometheus.NewInvalidMetric(countryUsersDesc, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(countryUsersDesc, prometheus.GaugeValue, users, tenant, country)
+	}
+	if err := rows.Err(); err != nil {
+		ch <- prometheus.NewInvalidMetric(countryUsersDesc, err)
+	}
+}
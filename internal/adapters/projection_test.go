@@ -0,0 +1,127 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+	"users-app/domain"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Projection(t *testing.T) {
+	repo := setupRepo(t, nil)
+	registry := prometheus.NewRegistry()
+	projection := NewProjection(registry, integrationTestsRepoConfig(t), ProjectionConfig{PollInterval: time.Hour, BatchSize: 2})
+	t.Cleanup(func() { projection.Close() })
+	published := NewMemoryPublisher()
+	projection.publisher = published
+	readModel := NewReadModel(repo)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	newUser := func(email, country string, created time.Time) domain.User {
+		return domain.User{
+			ID: uuid.New(), TenantID: domain.DefaultTenant, FirstName: "John", LastName: "Doe", Nickname: "jd",
+			PasswordHash: "hash", Email: email, Country: country, CreatedAt: created, UpdatedAt: created,
+		}
+	}
+	john := newUser("john@example.com", "UK", now)
+	jane := newUser("jane@example.com", "UK", now.Add(time.Second))
+	jack := newUser("jack@example.com", "PL", now.Add(2*time.Second))
+	for _, user := range []domain.User{john, jane, jack} {
+		require.NoError(t, repo.AddUser(ctx, user))
+	}
+	_, err := repo.ModifyUser(ctx, jane.ID, domain.Fields{"country": "PL"})
+	require.NoError(t, err)
+	require.NoError(t, repo.RemoveUser(ctx, jack.ID))
+	require.NoError(t, repo.RemoveUser(ctx, uuid.New()), "the deletion of a missing user has no event")
+
+	emails := func() []string {
+		users, err := readModel.Users(ctx, domain.Filter{}, domain.DefaultPagination)
+		require.NoError(t, err)
+		var emails []string
+		for _, user := range users {
+			assert.Empty(t, user.PasswordHash)
+			emails = append(emails, user.Email)
+		}
+		return emails
+	}
+	countryUsers := func() map[string]int {
+		rows, err := repo.DB().Query("SELECT country, users FROM users_country_counts WHERE tenant_id = $1", domain.DefaultTenant)
+		require.NoError(t, err)
+		defer rows.Close()
+		counts := map[string]int{}
+		for rows.Next() {
+			var country string
+			var users int
+			require.NoError(t, rows.Scan(&country, &users))
+			counts[country] = users
+		}
+		return counts
+	}
+
+	assert.Empty(t, emails(), "the events are not applied yet")
+	assert.Equal(t, 5.0, testutil.ToFloat64(projectionCollector{db: projection.db}), "the lag is the only metric without the users")
+
+	applied, err := projection.CatchUp(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, applied)
+	assert.Equal(t, []string{"john@example.com", "jane@example.com"}, emails())
+	assert.Equal(t, map[string]int{"UK": 1, "PL": 1}, countryUsers())
+
+	events := published.Events()
+	require.Len(t, events, 5, "the applied events are published")
+	assert.Equal(t, domain.UserModified, events[3].Msg)
+	assert.Equal(t, jane.ID, events[3].UserID)
+	require.NotNil(t, events[3].User)
+	assert.Equal(t, jane.Email, events[3].User.Email)
+	assert.Equal(t, "PL", events[3].User.Country)
+	assert.Equal(t, domain.UserDeleted, events[4].Msg)
+	assert.Nil(t, events[4].User, "the deleted users are not carried")
+
+	users, err := readModel.Users(ctx, domain.NewFilter("", "", "", "", "PL"), domain.DefaultPagination)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, jane.ID, users[0].ID)
+	assert.WithinDuration(t, jane.CreatedAt, users[0].CreatedAt, 0)
+
+	applied, err = projection.CatchUp(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, applied, "the events are applied once")
+	assert.Len(t, published.Events(), 5)
+
+	_, err = repo.DB().Exec("DELETE FROM users_view")
+	require.NoError(t, err)
+	applied, err = projection.Rebuild(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, applied)
+	assert.Equal(t, []string{"john@example.com", "jane@example.com"}, emails())
+	assert.Equal(t, map[string]int{"UK": 1, "PL": 1}, countryUsers())
+	assert.Equal(t, 10.0, testutil.ToFloat64(projection.applied))
+
+	counts, err := readModel.CountryCounts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.CountryCount{{Country: "PL", Users: 1}, {Country: "UK", Users: 1}}, counts)
+	users, err = readModel.Users(ctx, domain.NewFilterEmail("JANE@example.com"), domain.DefaultPagination)
+	require.NoError(t, err)
+	require.Len(t, users, 1, "the email is matched case-insensitively")
+	assert.Equal(t, jane.ID, users[0].ID)
+	users, err = readModel.Users(ctx, domain.Filter{}.WithSearch("doe -jane@example.com"), domain.DefaultPagination)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, john.ID, users[0].ID)
+
+	pruned, err := projection.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned, "the additions of jane, modified later, and of jack, deleted later, are pruned")
+	applied, err = projection.Rebuild(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, applied)
+	assert.Equal(t, []string{"john@example.com", "jane@example.com"}, emails(), "the pruned events are not needed")
+	assert.Equal(t, map[string]int{"UK": 1, "PL": 1}, countryUsers())
+}
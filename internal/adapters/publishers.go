@@ -0,0 +1,27 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"users-app/domain"
+)
+
+// multiPublisher publishes every event to all the wrapped publishers
+type multiPublisher []domain.Publisher
+
+// NewMultiPublisher creates a domain.Publisher which fans out events to all the given publishers.
+// Failure of one publisher doesn't prevent delivery to the others, all errors are returned joined.
+func NewMultiPublisher(publishers ...domain.Publisher) domain.Publisher {
+	return multiPublisher(publishers)
+}
+
+func (m multiPublisher) PublishEvent(ctx context.Context, event domain.Event) error {
+	var errs []error
+	for _, p := range m {
+		if err := p.PublishEvent(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
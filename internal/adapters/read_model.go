@@ -0,0 +1,27 @@
+package adapters
+
+import (
+	"context"
+	"users-app/domain"
+)
+
+// readModel queries users_view and users_country_counts, the projections of the users built by Projection, the same
+// way the repository queries the users table - on the replicas, when there are any. The filters of the emails and
+// the full-text search use the generated search columns of users_view.
+type readModel struct {
+	repo repository
+}
+
+func NewReadModel(repo repository) domain.UsersReadModel {
+	return readModel{repo}
+}
+
+// Users returns the users that match the given filter, in the order they were created, so that the pages are stable
+func (r readModel) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	return r.repo.users(ctx, usersViewTable, filter, pagination, "created_at", "id")
+}
+
+// CountryCounts returns the numbers of the users of the countries kept by the projection, ordered by the country
+func (r readModel) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	return r.repo.countryCounts(ctx, "SELECT country, users FROM users_country_counts WHERE tenant_id = ? ORDER BY country")
+}
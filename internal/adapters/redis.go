@@ -2,18 +2,25 @@ package adapters
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 	"users-app/domain"
 
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // publisher is a struct used to manage connections to Redis and publish events
 // to the specified Redis channel.
 type publisher struct {
-	client  *redis.Client
-	channel string
+	client           *redis.Client
+	channel          string
+	channelPerTenant bool
 }
 
 type RedisConfig struct {
@@ -22,6 +29,8 @@ type RedisConfig struct {
 	Password      string
 	DB            int
 	EventsChannel string
+	// EventsChannelPerTenant publishes the events of every tenant to its own channel, named EventsChannel:<tenant id>
+	EventsChannelPerTenant bool
 }
 
 func NewPublisher(redisConfig RedisConfig) publisher {
@@ -36,15 +45,58 @@ func NewPublisher(redisConfig RedisConfig) publisher {
 		log.Fatal(err)
 	}
 
-	return publisher{client: client}
+	return publisher{client: client, channel: redisConfig.EventsChannel, channelPerTenant: redisConfig.EventsChannelPerTenant}
 }
 
-// PublishEvent publishes an event to the Redis channel. Currently, the event is serialized using
-// the fmt.Sprintf function, but it is recommended to use proper event marshalling, such as protobuf or JSON.
+// Close closes the Redis client
+func (p publisher) Close() error {
+	return p.client.Close()
+}
+
+// Ping checks if Redis is reachable, it's used by the health checks
+func (p publisher) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx).Err()
+}
+
+// eventEnvelope is the message published to the Redis channel.
+// TraceContext carries the W3C trace context (traceparent, tracestate), so that the consumers can continue the trace.
+type eventEnvelope struct {
+	Type         domain.EventMsg        `json:"type"`
+	TenantID     domain.TenantID        `json:"tenant_id"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+	Payload      map[string]interface{} `json:"payload"`
+	TraceContext map[string]string      `json:"trace_context,omitempty"`
+}
+
+// PublishEvent publishes an event to the Redis channel, serialized as JSON eventEnvelope
 func (p publisher) PublishEvent(ctx context.Context, event domain.Event) error {
+	channel := p.channel
+	if p.channelPerTenant {
+		channel += ":" + string(event.TenantID)
+	}
+
+	ctx, span := tracer().Start(ctx, "redis publish "+string(event.Msg),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("redis"),
+			semconv.MessagingDestinationName(channel),
+		),
+	)
+	defer span.End()
+
+	envelope := eventEnvelope{
+		Type:         event.Msg,
+		TenantID:     event.TenantID,
+		OccurredAt:   time.Now().UTC(),
+		Payload:      event.Payload(),
+		TraceContext: map[string]string{},
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(envelope.TraceContext))
 
-	// todo - add proper event marshalling - protobuf, json, etc
-	msg := fmt.Sprintf("%#v", event)
+	msg, err := json.Marshal(envelope)
+	if err != nil {
+		return recordError(span, fmt.Errorf("failed to marshal event: %w", err))
+	}
 
-	return p.client.Publish(ctx, p.channel, msg).Err()
+	return recordError(span, p.client.Publish(ctx, channel, msg).Err())
 }
@@ -0,0 +1,71 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisCachePrefix keeps the keys of the cache apart from the rest of the keys in the database
+const redisCachePrefix = "cache:users:"
+
+// RedisCache is the service.UsersCache kept in Redis, so that the entries are shared by all the instances
+type RedisCache struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedisCache doesn't check the connection, the queries go to the repository while Redis is unavailable.
+// The calls don't wait longer than the timeout, nor are they retried, so that they don't slow the queries down.
+func NewRedisCache(redisConfig RedisConfig, timeout time.Duration) RedisCache {
+	client := redis.NewClient(&redis.Options{
+		Addr:        redisConfig.Host + ":" + redisConfig.Port,
+		Password:    redisConfig.Password,
+		DB:          redisConfig.DB,
+		MaxRetries:  -1,
+		DialTimeout: timeout,
+	})
+
+	return RedisCache{client: client, timeout: timeout}
+}
+
+// Close closes the Redis client
+func (c RedisCache) Close() error {
+	return c.client.Close()
+}
+
+func (c RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	value, err := c.client.Get(ctx, redisCachePrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (c RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	return c.client.Set(ctx, redisCachePrefix+key, value, ttl).Err()
+}
+
+func (c RedisCache) Delete(ctx context.Context, keys ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisCachePrefix + key
+	}
+
+	return c.client.Del(ctx, prefixed...).Err()
+}
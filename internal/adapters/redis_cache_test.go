@@ -0,0 +1,57 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisCache(t *testing.T) (RedisCache, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+
+	cache := NewRedisCache(RedisConfig{Host: server.Host(), Port: server.Port()}, time.Second)
+	t.Cleanup(func() { cache.Close() })
+	return cache, server
+}
+
+func TestRedisCache(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+	ctx := context.Background()
+
+	_, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("value"), time.Minute))
+	require.NoError(t, cache.Set(ctx, "b", []byte("value"), time.Minute))
+	value, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+	assert.True(t, server.Exists("cache:users:a"))
+
+	require.NoError(t, cache.Delete(ctx, "a", "b", "c"))
+	assert.False(t, server.Exists("cache:users:a"))
+	assert.False(t, server.Exists("cache:users:b"))
+
+	require.NoError(t, cache.Set(ctx, "generation", []byte("value"), 0))
+	server.FastForward(time.Hour)
+	assert.True(t, server.Exists("cache:users:generation"), "the entry without a ttl doesn't expire")
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("value"), time.Minute))
+	server.FastForward(time.Minute)
+	assert.False(t, server.Exists("cache:users:a"), "the entry expires after the ttl")
+}
+
+func TestRedisCache_unavailable(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+	server.Close()
+
+	_, _, err := cache.Get(context.Background(), "a")
+
+	assert.Error(t, err)
+}
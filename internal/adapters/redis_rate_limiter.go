@@ -0,0 +1,87 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"users-app/ratelimit"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucket takes a token from the bucket KEYS[1], ARGV[1] is the rate (tokens per second) and ARGV[2] the burst.
+// It's run as a single script, so that the concurrent requests of the instances can't take the same token.
+// The time is taken from Redis, the clocks of the instances don't need to agree. The bucket expires when it would be
+// full again, the missing bucket is the same as the full one.
+var tokenBucket = redis.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "at")
+local tokens = tonumber(bucket[1]) or burst
+local at = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - at) * rate)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = (1 - tokens) / rate
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "at", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000))
+return {allowed, tostring(retry_after)}
+`)
+
+// RedisRateLimiter keeps the token buckets in Redis, so that the limits are shared by all the instances
+type RedisRateLimiter struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedisRateLimiter doesn't check the connection, the requests are limited by a fallback while Redis is unavailable.
+// The calls don't wait longer than the timeout, nor are they retried, so that they don't slow the requests down.
+func NewRedisRateLimiter(redisConfig RedisConfig, timeout time.Duration) RedisRateLimiter {
+	client := redis.NewClient(&redis.Options{
+		Addr:        redisConfig.Host + ":" + redisConfig.Port,
+		Password:    redisConfig.Password,
+		DB:          redisConfig.DB,
+		MaxRetries:  -1,
+		DialTimeout: timeout,
+	})
+
+	return RedisRateLimiter{client: client, timeout: timeout}
+}
+
+// Close closes the Redis client
+func (l RedisRateLimiter) Close() error {
+	return l.client.Close()
+}
+
+func (l RedisRateLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	res, err := tokenBucket.Run(ctx, l.client, []string{"ratelimit:" + key}, limit.Rate, limit.Burst).Slice()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if len(res) != 2 {
+		return ratelimit.Result{}, fmt.Errorf("unexpected token bucket result: %v", res)
+	}
+
+	allowed, _ := res[0].(int64)
+	retryAfter, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("unexpected token bucket result: %w", err)
+	}
+
+	return ratelimit.Result{Allowed: allowed == 1, RetryAfter: time.Duration(retryAfter * float64(time.Second))}, nil
+}
@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+	"users-app/ratelimit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisRateLimiter(t *testing.T) (RedisRateLimiter, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Unix(1700000000, 0))
+
+	limiter := NewRedisRateLimiter(RedisConfig{Host: server.Host(), Port: server.Port()}, time.Second)
+	t.Cleanup(func() { limiter.Close() })
+	return limiter, server
+}
+
+func TestRedisRateLimiter_Allow(t *testing.T) {
+	limiter, server := newTestRedisRateLimiter(t)
+	limit := ratelimit.Limit{Rate: 2, Burst: 3}
+	allow := func(key string) ratelimit.Result {
+		result, err := limiter.Allow(context.Background(), key, limit)
+		require.NoError(t, err)
+		return result
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, allow("a").Allowed, "the burst is allowed at once")
+	}
+	result := allow("a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.True(t, allow("b").Allowed, "every key has its own bucket")
+
+	server.SetTime(time.Unix(1700000000, int64(500*time.Millisecond)))
+	assert.True(t, allow("a").Allowed, "a token is added every 1/rate")
+	assert.False(t, allow("a").Allowed)
+
+	assert.True(t, server.Exists("ratelimit:a"))
+	server.FastForward(1500 * time.Millisecond)
+	assert.False(t, server.Exists("ratelimit:a"), "the bucket expires once it would be full")
+}
+
+func TestRedisRateLimiter_unavailable(t *testing.T) {
+	limiter, server := newTestRedisRateLimiter(t)
+	server.Close()
+
+	_, err := limiter.Allow(context.Background(), "a", ratelimit.Limit{Rate: 1, Burst: 1})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,221 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"users-app/domain"
+
+	"github.com/upper/db/v4"
+	"go.uber.org/zap"
+)
+
+// ReplicasConfig configures the read replicas of the users queries
+type ReplicasConfig struct {
+	Hosts []string
+	// Database, User and Password default to the ones of the primary
+	Database string
+	User     string
+	Password string
+
+	// MaxLag is the lag behind the primary, above which the replica is not queried
+	MaxLag time.Duration
+	// CheckInterval is the interval of checking the lag of the replicas
+	CheckInterval time.Duration
+}
+
+// replicaStateQuery returns the position of the log replayed by the replica, its lag in seconds and whether it's
+// streaming the log from the primary. The replica which has replayed everything it has received doesn't lag, no matter
+// how long ago the primary was written last, as long as it's still streaming - the one which has lost the primary
+// has replayed everything as well, but it's not known how much it's missing. The status of the WAL receiver is
+// visible only to the members of pg_read_all_stats, for the other users the running receiver is taken as streaming.
+// The primary itself can be used as a replica as well, e.g. in the tests.
+const replicaStateQuery = `
+SELECT
+	CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END::text,
+	CASE WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8,
+	NOT pg_is_in_recovery() OR EXISTS (
+		SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'
+	)`
+
+// replicas routes the queries to the read replicas. The replicas are checked every CheckInterval, the ones which
+// can't be reached, or lag behind the primary by more than MaxLag, are not queried until they catch up. The queries
+// made with a consistency token go only to the replicas which had replayed its write at the last check.
+// When no replica can serve a query, it goes to the primary.
+type replicas struct {
+	all    []*replica
+	maxLag time.Duration
+	// checkTimeout limits the duration of a check, so that the unreachable replica doesn't block the others
+	checkTimeout time.Duration
+	next         *atomic.Uint64
+	stop         context.CancelFunc
+	done         chan struct{}
+}
+
+type replica struct {
+	host string
+	db   db.Session
+
+	mu    sync.RWMutex
+	state replicaState
+}
+
+type replicaState struct {
+	available bool
+	// lsn is the position of the log replayed by the replica
+	lsn uint64
+}
+
+// openReplicas opens the sessions of the replicas, it stops the application in case one is not reachable,
+// the same as the primary. The replicas are checked before it returns, and then in the background until close.
+func openReplicas(primary RepoConfig) *replicas {
+	config := primary.Replicas
+	r := &replicas{maxLag: config.MaxLag, checkTimeout: config.CheckInterval, next: &atomic.Uint64{}, done: make(chan struct{})}
+	for _, host := range config.Hosts {
+		sess := openSession(RepoConfig{
+			Host:             host,
+			Database:         valueOr(config.Database, primary.Database),
+			User:             valueOr(config.User, primary.User),
+			Password:         valueOr(config.Password, primary.Password),
+			StatementTimeout: primary.StatementTimeout,
+		})
+		r.all = append(r.all, &replica{host: host, db: sess})
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.check(ctx)
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(config.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.check(ctx)
+			}
+		}
+	}()
+
+	return r
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+// close stops the checks and closes the sessions of the replicas
+func (r *replicas) close() error {
+	r.stop()
+	<-r.done
+
+	var errs []error
+	for _, replica := range r.all {
+		if err := replica.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("replica %s: %w", replica.host, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *replicas) check(ctx context.Context) {
+	for _, replica := range r.all {
+		replica.check(ctx, r.maxLag, r.checkTimeout)
+	}
+}
+
+// pick returns the next replica which can serve the query, ok is false when there's none
+func (r *replicas) pick(token domain.ConsistencyToken) (*replica, bool) {
+	var required uint64
+	if token != "" {
+		lsn, err := parseLSN(string(token))
+		if err != nil {
+			return nil, false
+		}
+		required = lsn
+	}
+
+	start := r.next.Add(1)
+	for i := range r.all {
+		replica := r.all[(start+uint64(i))%uint64(len(r.all))]
+		if state := replica.getState(); state.available && state.lsn >= required {
+			return replica, true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *replica) getState() replicaState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// check updates the state of the replica, the changes of its availability are logged
+func (r *replica) check(ctx context.Context, maxLag, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lsn string
+	var lagSeconds float64
+	var streaming bool
+	err := r.db.Driver().(*sql.DB).QueryRowContext(ctx, replicaStateQuery).Scan(&lsn, &lagSeconds, &streaming)
+	var state replicaState
+	if err == nil {
+		state.lsn, err = parseLSN(lsn)
+	}
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	state.available = err == nil && streaming && lag <= maxLag
+
+	r.mu.Lock()
+	wasAvailable := r.state.available
+	r.state = state
+	r.mu.Unlock()
+
+	logger := zap.L().With(zap.String("replica", r.host))
+	switch {
+	case wasAvailable && err != nil:
+		logger.Warn("the replica is not reachable, its queries go to the primary", zap.Error(err))
+	case wasAvailable && !streaming:
+		logger.Warn("the replica doesn't stream from the primary, its queries go to the primary")
+	case wasAvailable && !state.available:
+		logger.Warn("the replica lags behind the primary, its queries go to the primary", zap.Duration("lag", lag))
+	case !wasAvailable && state.available:
+		logger.Info("the replica is queried", zap.Duration("lag", lag))
+	}
+}
+
+// parseLSN parses the position in the Postgres log, written as two hexadecimal halves, e.g. 16/B374D848
+func parseLSN(lsn string) (uint64, error) {
+	high, low, ok := strings.Cut(lsn, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid lsn %q", lsn)
+	}
+	h, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", lsn, err)
+	}
+	l, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", lsn, err)
+	}
+
+	return h<<32 | l, nil
+}
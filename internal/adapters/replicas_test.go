@@ -0,0 +1,83 @@
+package adapters
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+	"users-app/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSN(t *testing.T) {
+	tests := []struct {
+		lsn     string
+		want    uint64
+		wantErr bool
+	}{
+		{lsn: "0/0", want: 0},
+		{lsn: "16/B374D848", want: 0x16B374D848},
+		{lsn: "FFFFFFFF/FFFFFFFF", want: 0xFFFFFFFFFFFFFFFF},
+		{lsn: "16B374D848", wantErr: true},
+		{lsn: "16/G", wantErr: true},
+		{lsn: "100000000/0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lsn, func(t *testing.T) {
+			got, err := parseLSN(tt.lsn)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReplicas_pick(t *testing.T) {
+	r := &replicas{next: &atomic.Uint64{}, all: []*replica{
+		{host: "lagging", state: replicaState{available: false, lsn: 0x10}},
+		{host: "behind", state: replicaState{available: true, lsn: 0x20}},
+		{host: "up-to-date", state: replicaState{available: true, lsn: 0x30}},
+	}}
+	picked := func(token domain.ConsistencyToken) []string {
+		var hosts []string
+		for i := 0; i < 4; i++ {
+			if replica, ok := r.pick(token); ok {
+				hosts = append(hosts, replica.host)
+			}
+		}
+		return hosts
+	}
+
+	hosts := picked("")
+	assert.Len(t, hosts, 4)
+	assert.Subset(t, hosts, []string{"behind", "up-to-date"}, "the available replicas take turns")
+	assert.NotContains(t, hosts, "lagging")
+	assert.Equal(t, []string{"up-to-date", "up-to-date", "up-to-date", "up-to-date"}, picked("0/30"), "only the replicas which replayed the write")
+	assert.Empty(t, picked("0/31"), "the primary serves the writes no replica has replayed")
+	assert.Empty(t, picked("invalid"))
+}
+
+func Test_repository_replicas(t *testing.T) {
+	config := integrationTestsRepoConfig(t)
+	// the primary is its own replica, it never lags
+	config.Replicas = ReplicasConfig{Hosts: []string{config.Host}, MaxLag: time.Second, CheckInterval: time.Second}
+	repo := NewRepository(config)
+	t.Cleanup(func() { repo.Close() })
+	repo.flush()
+
+	ctx, tracker := domain.TrackWrites(context.Background())
+	user := domain.User{ID: uuid.New(), TenantID: domain.DefaultTenant, Email: "replica@example.com"}
+	require.NoError(t, repo.AddUser(ctx, user))
+	token := tracker.Token()
+	require.NotEmpty(t, token, "the writes give the consistency token")
+
+	users, err := repo.Users(domain.WithConsistencyToken(context.Background(), token), domain.Filter{}, domain.DefaultPagination)
+	require.NoError(t, err)
+	assert.Len(t, users, 1, "the write is seen with the token")
+}
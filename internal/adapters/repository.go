@@ -1,16 +1,33 @@
 package adapters
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 	"users-app/domain"
+	"users-app/logging"
+
+	"github.com/jackc/pgconn"
+	"go.uber.org/zap"
 
 	"github.com/upper/db/v4"
 	"github.com/upper/db/v4/adapter/postgresql"
 )
 
+// repository keeps the users in Postgres. The changes of the users are appended to user_events in their
+// transactions (see appendUserEvent), the projections of the users are built from them, see Projection.
 type repository struct {
-	db db.Session
+	db           db.Session
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	// replicas serve the queries, nil when there are none
+	replicas *replicas
+	// trackWrites gives the consistency tokens of the writes even when there are no replicas
+	trackWrites bool
 }
 
 type RepoConfig struct {
@@ -18,17 +35,53 @@ type RepoConfig struct {
 	Database string
 	User     string
 	Password string
+
+	// ReadTimeout limits the duration of the queries (Users), 0 means no limit other than the caller's deadline
+	ReadTimeout time.Duration
+	// WriteTimeout limits the duration of the operations modifying the users and the idempotency keys, 0 means no limit
+	// other than the caller's deadline
+	WriteTimeout time.Duration
+	// StatementTimeout is set as the statement_timeout of every database session,
+	// so that Postgres aborts the statements running for too long even if the application doesn't cancel them.
+	// 0 keeps the database default.
+	StatementTimeout time.Duration
+
+	// Replicas are the read replicas of the users queries, the other repositories don't use them
+	Replicas ReplicasConfig
+	// TrackWrites gives the consistency tokens of the writes even when there are no replicas, e.g. so that
+	// the queries reading their writes can skip the projection of the users
+	TrackWrites bool
 }
 
 func NewRepository(
 	repositoryConfig RepoConfig,
 ) repository {
+	repo := repository{
+		db:           openSession(repositoryConfig),
+		readTimeout:  repositoryConfig.ReadTimeout,
+		writeTimeout: repositoryConfig.WriteTimeout,
+		trackWrites:  repositoryConfig.TrackWrites,
+	}
+	if len(repositoryConfig.Replicas.Hosts) > 0 {
+		repo.replicas = openReplicas(repositoryConfig)
+	}
+
+	return repo
+}
+
+// openSession opens a new postgres session, it stops the application in case the database is not reachable
+func openSession(repositoryConfig RepoConfig) db.Session {
 	settings := postgresql.ConnectionURL{
 		Host:     repositoryConfig.Host,
 		Database: repositoryConfig.Database,
 		User:     repositoryConfig.User,
 		Password: repositoryConfig.Password,
 	}
+	if repositoryConfig.StatementTimeout > 0 {
+		settings.Options = map[string]string{
+			"statement_timeout": strconv.FormatInt(repositoryConfig.StatementTimeout.Milliseconds(), 10),
+		}
+	}
 
 	sess, err := postgresql.Open(settings)
 	if err != nil {
@@ -40,59 +93,201 @@ func NewRepository(
 		log.Fatal(err)
 	}
 
-	return repository{db: sess}
+	return sess
+}
+
+// Close closes the database sessions
+func (r repository) Close() error {
+	var errs []error
+	if r.replicas != nil {
+		errs = append(errs, r.replicas.close())
+	}
+
+	return errors.Join(append(errs, r.db.Close())...)
+}
+
+// Ping checks if the database is reachable, it's used by the health checks
+func (r repository) Ping(ctx context.Context) error {
+	return r.DB().PingContext(ctx)
+}
+
+// DB returns the underlying connection pool, e.g. to expose its statistics
+func (r repository) DB() *sql.DB {
+	return r.db.Driver().(*sql.DB)
+}
+
+// session returns the database session bound to the context, limited by the timeout.
+// The caller's deadline is kept if it's shorter. The returned cancel func must be called when the operation is done.
+func sessionWithTimeout(ctx context.Context, sess db.Session, timeout time.Duration) (db.Session, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	return sess.WithContext(ctx), cancel
+}
+
+// reader returns the session of the queries: a replica which can serve them, or the primary
+func (r repository) reader(ctx context.Context) db.Session {
+	if r.replicas == nil || domain.PrimaryReads(ctx) {
+		return r.db
+	}
+	if replica, ok := r.replicas.pick(domain.ConsistencyTokenFromContext(ctx)); ok {
+		return replica.db
+	}
+
+	return r.db
+}
+
+// recordWrite gives the consistency token of the write to the tracker of the context, when there are replicas
+// or the writes are tracked anyway.
+// The current position of the log is past the write, as it's taken once the write is committed.
+func (r repository) recordWrite(ctx context.Context) {
+	tracker, ok := domain.WriteTrackerFromContext(ctx)
+	if (r.replicas == nil && !r.trackWrites) || !ok {
+		return
+	}
+
+	var lsn string
+	if err := r.DB().QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&lsn); err != nil {
+		logging.FromContext(ctx).Warn("failed to get the consistency token", zap.Error(err))
+		return
+	}
+	tracker.Record(domain.ConsistencyToken(lsn))
 }
 
 // AddUser adds a new user to the repository
-// user needs to have a unique id
+// user needs to have a unique id, across all the tenants
 // In case of a duplicate id, an error is returned
-func (r repository) AddUser(user domain.User) error {
-	exists, err := r.db.Collection("users").Find(db.Cond{"id": user.ID}).Exists()
+func (r repository) AddUser(ctx context.Context, user domain.User) error {
+	sess, cancel := sessionWithTimeout(ctx, r.db, r.writeTimeout)
+	defer cancel()
+
+	err := sess.Tx(func(tx db.Session) error {
+		exists, err := tx.Collection("users").Find(db.Cond{"id": user.ID}).Exists()
+		if err != nil {
+			return err
+		}
+		if exists {
+			return domain.ErrUserAlreadyExists
+		}
+
+		if _, err := tx.Collection("users").Insert(fromDomain(user)); err != nil {
+			return err
+		}
+
+		return appendUserEvent(tx, domain.UserAdded, user.TenantID, user.ID, &user)
+	})
 	if err != nil {
-		return err
-	}
-	if exists {
-		return domain.ErrUserAlreadyExists
+		return mapError(err)
 	}
+	r.recordWrite(ctx)
 
-	return r.insert(user)
+	return nil
 }
 
-// ModifyUser modifies a user with the given id
+// ModifyUser modifies a user with the given id and returns the modified user
 // user needs to exist before calling this method
 // updates only specified fields
-func (r repository) ModifyUser(id domain.UserID, fields domain.Fields) error {
-	res := r.db.Collection("users").Find(db.Cond{"id": id})
-	exists, err := res.Exists()
+func (r repository) ModifyUser(ctx context.Context, id domain.UserID, fields domain.Fields) (domain.User, error) {
+	sess, cancel := sessionWithTimeout(ctx, r.db, r.writeTimeout)
+	defer cancel()
+
+	var ret UserDTO
+	err := sess.Tx(func(tx db.Session) error {
+		res := tx.Collection("users").Find(db.Cond{"tenant_id": domain.TenantFromContext(ctx), "id": id})
+		exists, err := res.Exists()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return domain.ErrUserNotFound
+		}
+
+		if len(fields) > 0 {
+			err := res.Update(fields)
+			if err != nil {
+				return fmt.Errorf("failed to update user: %w", err)
+			}
+		}
+
+		if err := res.One(&ret); err != nil {
+			return err
+		}
+		user := toDomain(ret)
+
+		return appendUserEvent(tx, domain.UserModified, user.TenantID, id, &user)
+	})
 	if err != nil {
-		return err
-	}
-	if !exists {
-		return domain.ErrUserNotFound
+		return domain.User{}, mapError(err)
 	}
+	r.recordWrite(ctx)
 
-	if len(fields) > 0 {
-		err := res.Update(fields)
+	return toDomain(ret), nil
+}
+
+func (r repository) RemoveUser(ctx context.Context, id domain.UserID) error {
+	sess, cancel := sessionWithTimeout(ctx, r.db, r.writeTimeout)
+	defer cancel()
+
+	tenant := domain.TenantFromContext(ctx)
+	err := sess.Tx(func(tx db.Session) error {
+		res, err := tx.SQL().DeleteFrom("users").Where(db.Cond{"tenant_id": tenant, "id": id}).Exec()
 		if err != nil {
-			return fmt.Errorf("failed to update user: %w", err)
+			return err
+		}
+		if deleted, err := res.RowsAffected(); err != nil || deleted == 0 {
+			return err
 		}
 
-		return nil
+		return appendUserEvent(tx, domain.UserDeleted, tenant, id, nil)
+	})
+	if err != nil {
+		return mapError(err)
 	}
+	r.recordWrite(ctx)
 
 	return nil
 }
 
-func (r repository) RemoveUser(id domain.UserID) error {
-	res := r.db.Collection("users").Find(db.Cond{"id": id})
-	return res.Delete()
-}
-
 // Users returns a list of users that match the given filter
 // and are paginated according to the given pagination
-func (r repository) Users(filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
-	query := r.db.Collection("users").Find()
-	query = addFilters(filter, query)
+func (r repository) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	return r.users(ctx, usersTable, filter, pagination)
+}
+
+// CountryCounts counts the users of every country, ordered by the country
+func (r repository) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	return r.countryCounts(ctx, "SELECT country, count(*) AS users FROM users WHERE tenant_id = ? GROUP BY country ORDER BY country")
+}
+
+// queriedTable is a table of the users read by the queries, with the expressions of the lowercased email and of
+// the full-text search vector - computed for the users table, the generated columns of its projection
+type queriedTable struct {
+	name       string
+	emailLower string
+	search     string
+}
+
+var (
+	usersTable = queriedTable{
+		name:       "users",
+		emailLower: "lower(email)",
+		search:     "to_tsvector('simple', first_name || ' ' || last_name || ' ' || nickname || ' ' || email)",
+	}
+	usersViewTable = queriedTable{name: "users_view", emailLower: "email_lower", search: "search"}
+)
+
+// users queries the table of the users, or of their projection, the results are ordered by the columns of orderBy
+func (r repository) users(ctx context.Context, table queriedTable, filter domain.Filter, pagination domain.Pagination, orderBy ...interface{}) ([]domain.User, error) {
+	sess, cancel := sessionWithTimeout(ctx, r.reader(ctx), r.readTimeout)
+	defer cancel()
+
+	query := sess.Collection(table.name).Find(db.Cond{"tenant_id": domain.TenantFromContext(ctx)})
+	query = addFilters(filter, table, query)
+	if len(orderBy) > 0 {
+		query = query.OrderBy(orderBy...)
+	}
 
 	// pagination
 	query = query.Limit(pagination.Limit())
@@ -102,21 +297,58 @@ func (r repository) Users(filter domain.Filter, pagination domain.Pagination) ([
 	var ret []UserDTO
 	err := query.All(&ret)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return toDomainUsers(ret), nil
 }
 
+// countryCountDTO is a row of the country counts
+type countryCountDTO struct {
+	Country string `db:"country"`
+	Users   int    `db:"users"`
+}
+
+// countryCounts runs the query of the country counts of the tenant, which is its only argument
+func (r repository) countryCounts(ctx context.Context, query string) ([]domain.CountryCount, error) {
+	sess, cancel := sessionWithTimeout(ctx, r.reader(ctx), r.readTimeout)
+	defer cancel()
+
+	var rows []countryCountDTO
+	if err := sess.SQL().Iterator(query, domain.TenantFromContext(ctx)).All(&rows); err != nil {
+		return nil, mapError(err)
+	}
+
+	ret := make([]domain.CountryCount, len(rows))
+	for i, row := range rows {
+		ret[i] = domain.CountryCount{Country: row.Country, Users: row.Users}
+	}
+
+	return ret, nil
+}
+
+// queryCanceled is the Postgres error code of the statements aborted because of statement_timeout
+const queryCanceled = "57014"
+
+// mapError makes the timeouts recognizable with errors.Is(err, context.DeadlineExceeded),
+// no matter if the context deadline was exceeded or Postgres aborted the statement because of statement_timeout
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == queryCanceled {
+		return fmt.Errorf("%w: %s", context.DeadlineExceeded, pgErr.Message)
+	}
+
+	return err
+}
+
 // addFilters adds filters to the query
 // it will only add filters that are not nil
-func addFilters(filter domain.Filter, q db.Result) db.Result {
+func addFilters(filter domain.Filter, table queriedTable, q db.Result) db.Result {
 	query := q
 	filterMap := map[string]*string{
 		"first_name": filter.FirstName(),
 		"last_name":  filter.LastName(),
 		"nickname":   filter.Nickname(),
-		"email":      filter.Email(),
 		"country":    filter.Country(),
 	}
 
@@ -125,6 +357,12 @@ func addFilters(filter domain.Filter, q db.Result) db.Result {
 			query = query.And(db.Cond{fmt.Sprintf("%s", field): *value})
 		}
 	}
+	if email := filter.Email(); email != nil {
+		query = query.And(db.Raw(table.emailLower+" = lower(?)", *email))
+	}
+	if search := filter.Search(); search != nil {
+		query = query.And(db.Raw(table.search+" @@ websearch_to_tsquery('simple', ?)", *search))
+	}
 
 	return query
 }
@@ -139,14 +377,6 @@ func (r repository) insert(u domain.User) error {
 // implemented just for integration tests
 // do not use it during normal runtime
 func (r repository) flush() {
-	r.db.Collection("users").Truncate()
-}
-
-// implemented just for integration tests
-// returns all users from the database
-// do not use it during normal runtime
-func (r repository) allUsers() ([]domain.User, error) {
-	var users []UserDTO
-	err := r.db.Collection("users").Find().All(&users)
-	return toDomainUsers(users), err
+	r.db.SQL().Exec("TRUNCATE users, user_events, users_view, users_country_counts RESTART IDENTITY")
+	r.db.SQL().Exec("UPDATE projection_checkpoints SET last_tx_id = '0', last_event_id = 0")
 }
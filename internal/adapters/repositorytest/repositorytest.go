@@ -0,0 +1,470 @@
+// Package repositorytest is the conformance suite of domain.Repository.
+//
+// Every implementation runs the same behavioural cases with Run, so that they can't drift apart:
+//
+//	func TestRepository(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T, users []domain.User) domain.Repository {
+//			return NewMemoryRepository(users...)
+//		})
+//	}
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"users-app/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NewRepository returns the repository under test, holding only the given users (in the given order).
+// It's called for every case, so it needs to clean up the data left by the previous cases.
+type NewRepository func(t *testing.T, users []domain.User) domain.Repository
+
+// fixtures
+var (
+	uuid1 = uuid.MustParse("5f5d5ef5-5eb5-5cb5-b5d5-5f5d5ef5eb5c")
+	uuid2 = uuid.MustParse("7a13e2ff-2c47-4f16-9c35-8e24abddc0ea")
+	uuid3 = uuid.MustParse("c95b7c8a-9e64-4e22-81df-a2e8fcb30c81")
+
+	user1 = domain.User{
+		ID: uuid1, TenantID: domain.DefaultTenant, FirstName: "John", LastName: "Doe",
+		Nickname: "johndoe", Email: "john.doe@email.com", Country: "US",
+	}
+	user2 = domain.User{
+		ID: uuid2, TenantID: domain.DefaultTenant, FirstName: "Jane", LastName: "Doe",
+		Nickname: "janedoe", Email: "jane.doe@emai.com", Country: "US",
+	}
+	user3 = domain.User{
+		ID: uuid3, TenantID: domain.DefaultTenant, FirstName: "John", LastName: "Stones",
+		Nickname: "jackstones", Email: "jack.stones@email.com", Country: "UK",
+	}
+)
+
+// Run runs all the cases against the repositories created by newRepository
+func Run(t *testing.T, newRepository NewRepository) {
+	t.Run("AddUser", func(t *testing.T) { testAddUser(t, newRepository) })
+	t.Run("Users", func(t *testing.T) { testUsers(t, newRepository) })
+	t.Run("CountryCounts", func(t *testing.T) { testCountryCounts(t, newRepository) })
+	t.Run("RemoveUser", func(t *testing.T) { testRemoveUser(t, newRepository) })
+	t.Run("ModifyUser", func(t *testing.T) { testModifyUser(t, newRepository) })
+	t.Run("tenants", func(t *testing.T) { testTenants(t, newRepository) })
+	t.Run("canceled_context", func(t *testing.T) { testCanceledContext(t, newRepository) })
+	t.Run("concurrent_use", func(t *testing.T) { testConcurrentUse(t, newRepository) })
+}
+
+// allUsers returns all the users of the default tenant kept by the repository
+func allUsers(t *testing.T, repo domain.Repository) []domain.User {
+	users, err := repo.Users(context.Background(), domain.Filter{}, domain.Pagination{})
+	require.NoError(t, err)
+
+	return users
+}
+
+func testAddUser(t *testing.T, newRepository NewRepository) {
+	tests := []struct {
+		name string
+		user domain.User
+
+		existingUsers []domain.User
+
+		expectedErr   error
+		expectedUsers []domain.User
+	}{
+		{
+			name:          "user_gets_added_to_empty_repository",
+			user:          domain.User{ID: uuid1, TenantID: domain.DefaultTenant},
+			expectedUsers: []domain.User{{ID: uuid1, TenantID: domain.DefaultTenant}},
+		},
+		{
+			name:          "user_gets_added_after_existing_users",
+			existingUsers: []domain.User{user1},
+			user:          user2,
+			expectedUsers: []domain.User{user1, user2},
+		},
+		{
+			name:          "user_does_not_get_added_if_already_exists",
+			existingUsers: []domain.User{{ID: uuid1, TenantID: domain.DefaultTenant}},
+			user:          domain.User{ID: uuid1, TenantID: domain.DefaultTenant, FirstName: "John"},
+			expectedErr:   domain.ErrUserAlreadyExists,
+			expectedUsers: []domain.User{{ID: uuid1, TenantID: domain.DefaultTenant}},
+		},
+		{
+			name:          "user_does_not_get_added_if_id_exists_in_other_tenant",
+			existingUsers: []domain.User{{ID: uuid1, TenantID: "other"}},
+			user:          domain.User{ID: uuid1, TenantID: domain.DefaultTenant},
+			expectedErr:   domain.ErrUserAlreadyExists,
+			expectedUsers: []domain.User{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t, tt.existingUsers)
+
+			err := repo.AddUser(context.Background(), tt.user)
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expectedUsers, allUsers(t, repo))
+		})
+	}
+}
+
+func testUsers(t *testing.T, newRepository NewRepository) {
+	tests := []struct {
+		name       string
+		filter     domain.Filter
+		pagination domain.Pagination
+
+		existingUsers []domain.User
+
+		expected []domain.User
+	}{
+		{
+			name:          "users_get_returned",
+			existingUsers: []domain.User{user1, user2},
+			expected:      []domain.User{user1, user2},
+		},
+		{
+			name:     "empty_repository_returns_no_users",
+			expected: []domain.User{},
+		},
+		{
+			name:          "results_are_paginated_if_pagination_is_set",
+			existingUsers: []domain.User{user1, user2, user3},
+			pagination:    domain.NewPagination(2, 0),
+			expected:      []domain.User{user1, user2},
+		},
+		{
+			name:          "offset_skips_users",
+			existingUsers: []domain.User{user1, user2, user3},
+			pagination:    domain.NewPagination(1, 1),
+			expected:      []domain.User{user2},
+		},
+		{
+			name:          "offset_is_applied_after_filters",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.NewFilter("John", "", "", "", ""),
+			pagination:    domain.NewPagination(10, 1),
+			expected:      []domain.User{user3},
+		},
+		{
+			name:          "offset_past_the_end_returns_no_users",
+			existingUsers: []domain.User{user1, user2},
+			pagination:    domain.NewPagination(10, 5),
+			expected:      []domain.User{},
+		},
+		{
+			name:          "first_name_filter_returns_existing_user",
+			existingUsers: []domain.User{user1, user2},
+			filter:        domain.NewFilter("John", "", "", "", ""),
+			expected:      []domain.User{user1},
+		},
+		{
+			name:          "last_name_filter_returns_existing_users",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.NewFilter("", "Doe", "", "", ""),
+			expected:      []domain.User{user1, user2},
+		},
+		{
+			name:          "nickname_filter_returns_existing_user",
+			existingUsers: []domain.User{user1, user2},
+			filter:        domain.NewFilter("", "", "johndoe", "", ""),
+			expected:      []domain.User{user1},
+		},
+		{
+			name:          "email_filter_returns_existing_user",
+			existingUsers: []domain.User{user1, user2},
+			filter:        domain.NewFilterEmail(user1.Email),
+			expected:      []domain.User{user1},
+		},
+		{
+			name:          "email_filter_ignores_the_case",
+			existingUsers: []domain.User{user1, user2},
+			filter:        domain.NewFilterEmail("John.Doe@EMAIL.com"),
+			expected:      []domain.User{user1},
+		},
+		{
+			name:          "country_filter_returns_existing_user",
+			existingUsers: []domain.User{user1, user2},
+			filter:        domain.NewFilter("", "", "", "", "US"),
+			expected:      []domain.User{user1, user2},
+		},
+		{
+			name:          "multiple_filters_return_correct_results",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.NewFilter("John", "Stones", "", "", ""),
+			expected:      []domain.User{user3},
+		},
+		{
+			name:          "filters_match_whole_values",
+			existingUsers: []domain.User{user1, user2},
+			filter:        domain.NewFilter("Jo", "", "", "", ""),
+			expected:      []domain.User{},
+		},
+		{
+			name:          "search_matches_every_word",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.Filter{}.WithSearch("doe john"),
+			expected:      []domain.User{user1},
+		},
+		{
+			name:          "search_ignores_the_case",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.Filter{}.WithSearch("DOE"),
+			expected:      []domain.User{user1, user2},
+		},
+		{
+			name:          "search_matches_the_email",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.Filter{}.WithSearch(user3.Email),
+			expected:      []domain.User{user3},
+		},
+		{
+			name:          "search_matches_whole_words",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.Filter{}.WithSearch("jo"),
+			expected:      []domain.User{},
+		},
+		{
+			name:          "search_is_combined_with_filters",
+			existingUsers: []domain.User{user1, user2, user3},
+			filter:        domain.NewFilter("", "", "", "", "UK").WithSearch("john"),
+			expected:      []domain.User{user3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t, tt.existingUsers)
+
+			ret, err := repo.Users(context.Background(), tt.filter, tt.pagination)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ret)
+		})
+	}
+}
+
+func testCountryCounts(t *testing.T, newRepository NewRepository) {
+	otherTenantUser := domain.User{ID: uuid.New(), TenantID: "other", Email: "john.doe@email.com", Country: "PL"}
+
+	tests := []struct {
+		name          string
+		existingUsers []domain.User
+
+		expected []domain.CountryCount
+	}{
+		{
+			name:          "users_are_counted_by_country",
+			existingUsers: []domain.User{user1, user2, user3, otherTenantUser},
+			expected:      []domain.CountryCount{{Country: "UK", Users: 1}, {Country: "US", Users: 2}},
+		},
+		{
+			name:     "empty_repository_has_no_countries",
+			expected: []domain.CountryCount{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t, tt.existingUsers)
+
+			counts, err := repo.CountryCounts(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, counts)
+		})
+	}
+}
+
+func testRemoveUser(t *testing.T, newRepository NewRepository) {
+	tests := []struct {
+		name string
+		id   domain.UserID
+
+		existingUsers []domain.User
+
+		expectedUsers []domain.User
+	}{
+		{name: "one_in_repo", id: uuid1, existingUsers: []domain.User{user1}, expectedUsers: []domain.User{}},
+		{name: "many_in_repo", id: uuid1, existingUsers: []domain.User{user1, user2, user3}, expectedUsers: []domain.User{user2, user3}},
+		{name: "user_does_not_exist", id: uuid1, expectedUsers: []domain.User{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t, tt.existingUsers)
+
+			err := repo.RemoveUser(context.Background(), tt.id)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedUsers, allUsers(t, repo))
+		})
+	}
+}
+
+func testModifyUser(t *testing.T, newRepository NewRepository) {
+	modified := func(modify func(*domain.User)) domain.User {
+		user := user1
+		modify(&user)
+		return user
+	}
+
+	tests := []struct {
+		name   string
+		id     domain.UserID
+		fields domain.Fields
+
+		existingUsers []domain.User
+
+		expectedErr   error
+		expectedUsers []domain.User
+	}{
+		{
+			name:          "user_does_not_exist",
+			id:            uuid1,
+			fields:        domain.Fields{"first_name": "Alex"},
+			expectedErr:   domain.ErrUserNotFound,
+			expectedUsers: []domain.User{},
+		},
+		{
+			name:          "user_exists_first_name_gets_modified",
+			id:            uuid1,
+			fields:        domain.Fields{"first_name": "Alex"},
+			existingUsers: []domain.User{user1},
+			expectedUsers: []domain.User{modified(func(u *domain.User) { u.FirstName = "Alex" })},
+		},
+		{
+			name:          "user_exists_last_name_gets_modified",
+			id:            uuid1,
+			fields:        domain.Fields{"last_name": "Smith"},
+			existingUsers: []domain.User{user1, user2},
+			expectedUsers: []domain.User{modified(func(u *domain.User) { u.LastName = "Smith" }), user2},
+		},
+		{
+			name:          "user_exists_nickname_gets_modified",
+			id:            uuid1,
+			fields:        domain.Fields{"nickname": "alex"},
+			existingUsers: []domain.User{user1, user2},
+			expectedUsers: []domain.User{modified(func(u *domain.User) { u.Nickname = "alex" }), user2},
+		},
+		{
+			name:          "user_exists_email_gets_modified",
+			id:            uuid1,
+			fields:        domain.Fields{"email": "new@email.com"},
+			existingUsers: []domain.User{user1, user2},
+			expectedUsers: []domain.User{modified(func(u *domain.User) { u.Email = "new@email.com" }), user2},
+		},
+		{
+			name:          "user_exists_country_gets_modified",
+			id:            uuid1,
+			fields:        domain.Fields{"country": "UK"},
+			existingUsers: []domain.User{user1, user2},
+			expectedUsers: []domain.User{modified(func(u *domain.User) { u.Country = "UK" }), user2},
+		},
+		{
+			name: "many_fields_get_modified",
+			id:   uuid1,
+			fields: domain.Fields{
+				"first_name": "Alex",
+				"country":    "UK",
+			},
+			existingUsers: []domain.User{user1},
+			expectedUsers: []domain.User{modified(func(u *domain.User) { u.FirstName, u.Country = "Alex", "UK" })},
+		},
+		{
+			name:          "no_fields_leave_user_unchanged",
+			id:            uuid1,
+			fields:        domain.Fields{},
+			existingUsers: []domain.User{user1},
+			expectedUsers: []domain.User{user1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t, tt.existingUsers)
+
+			user, err := repo.ModifyUser(context.Background(), tt.id, tt.fields)
+			assert.Equal(t, tt.expectedErr, err)
+			if tt.expectedErr == nil {
+				assert.Equal(t, tt.expectedUsers[0], user, "the modified user is returned")
+			}
+			// the order of the modified users is not defined
+			assert.ElementsMatch(t, tt.expectedUsers, allUsers(t, repo))
+		})
+	}
+}
+
+func testTenants(t *testing.T, newRepository NewRepository) {
+	other := domain.WithTenant(context.Background(), "other")
+	// the same email as user1, it's unique only within the tenant
+	otherUser := domain.User{ID: uuid.New(), TenantID: "other", FirstName: "Alex", Email: user1.Email}
+	repo := newRepository(t, []domain.User{user1, otherUser})
+
+	users, err := repo.Users(other, domain.NewFilterEmail(user1.Email), domain.DefaultPagination)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.User{otherUser}, users, "only the users of the tenant are returned")
+
+	_, err = repo.ModifyUser(other, uuid1, domain.Fields{"first_name": "Alex"})
+	assert.Equal(t, domain.ErrUserNotFound, err, "the users of the other tenants can't be modified")
+	assert.NoError(t, repo.RemoveUser(other, uuid1))
+	assert.Equal(t, []domain.User{user1}, allUsers(t, repo), "the users of the other tenants can't be removed")
+
+	added := domain.User{ID: uuid2, TenantID: "other", FirstName: "Jane"}
+	require.NoError(t, repo.AddUser(other, added))
+	users, err = repo.Users(other, domain.Filter{}, domain.Pagination{})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.User{otherUser, added}, users)
+	assert.Equal(t, []domain.User{user1}, allUsers(t, repo))
+}
+
+func testCanceledContext(t *testing.T, newRepository NewRepository) {
+	repo := newRepository(t, []domain.User{user1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Users(ctx, domain.Filter{}, domain.DefaultPagination)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, repo.AddUser(ctx, user2), context.Canceled)
+	_, err = repo.ModifyUser(ctx, uuid1, domain.Fields{"first_name": "Alex"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.RemoveUser(ctx, uuid1), context.Canceled)
+
+	assert.Equal(t, []domain.User{user1}, allUsers(t, repo), "canceled operations don't change the repository")
+}
+
+func testConcurrentUse(t *testing.T, newRepository NewRepository) {
+	const count = 20
+	repo := newRepository(t, nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			user := domain.User{ID: uuid.New(), TenantID: domain.DefaultTenant, FirstName: fmt.Sprintf("user-%d", i)}
+			errs <- repo.AddUser(context.Background(), user)
+			_, err := repo.ModifyUser(context.Background(), user.ID, domain.Fields{"country": "UK"})
+			errs <- err
+			_, err = repo.Users(context.Background(), domain.NewFilter("", "", "", "", "UK"), domain.DefaultPagination)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var all []error
+	for err := range errs {
+		all = append(all, err)
+	}
+	require.NoError(t, errors.Join(all...))
+
+	users := allUsers(t, repo)
+	assert.Len(t, users, count)
+	for _, user := range users {
+		assert.Equal(t, "UK", user.Country)
+	}
+}
@@ -0,0 +1,144 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"users-app/domain"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+type TracingConfig struct {
+	ServiceName string
+	// Exporter is one of TracingExporterNone, TracingExporterStdout, TracingExporterOTLP
+	Exporter string
+	// OTLPEndpoint is the host:port of the OTLP gRPC collector
+	OTLPEndpoint string
+	OTLPInsecure bool
+	// SampleRatio is the ratio of the traces started by this service which get recorded,
+	// traces started by the callers follow their sampling decision
+	SampleRatio float64
+}
+
+// NewTracerProvider creates the tracer provider and sets it, together with the W3C trace context propagator,
+// as the global one, so that the instrumentation libraries can use it.
+// The provider needs to be shut down to flush the remaining spans.
+func NewTracerProvider(ctx context.Context, config TracingConfig) (*sdktrace.TracerProvider, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName))),
+	}
+
+	switch config.Exporter {
+	case TracingExporterNone:
+	case TracingExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case TracingExporterOTLP:
+		exporterOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.OTLPEndpoint)}
+		if config.OTLPInsecure {
+			exporterOptions = append(exporterOptions, otlptracegrpc.WithInsecure())
+		}
+		// the connection is established lazily, so the collector being down doesn't stop the application
+		exporter, err := otlptracegrpc.New(ctx, exporterOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider, nil
+}
+
+// tracer is not kept in a variable, so that it always comes from the current global provider
+func tracer() trace.Tracer {
+	return otel.Tracer("users-app/adapters")
+}
+
+// recordError marks the span as failed when the error is not nil, the error is returned as it is
+func recordError(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// repositoryTracing creates a span for every repository operation, as a child of the span from the context
+type repositoryTracing struct {
+	wrapped domain.Repository
+}
+
+func NewRepositoryTracingWrapper(wrapped domain.Repository) domain.Repository {
+	return repositoryTracing{wrapped}
+}
+
+func (r repositoryTracing) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "Repository."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+}
+
+func (r repositoryTracing) AddUser(ctx context.Context, user domain.User) error {
+	ctx, span := r.start(ctx, "AddUser")
+	defer span.End()
+
+	return recordError(span, r.wrapped.AddUser(ctx, user))
+}
+
+func (r repositoryTracing) ModifyUser(ctx context.Context, id domain.UserID, fields domain.Fields) (domain.User, error) {
+	ctx, span := r.start(ctx, "ModifyUser")
+	defer span.End()
+
+	user, err := r.wrapped.ModifyUser(ctx, id, fields)
+	return user, recordError(span, err)
+}
+
+func (r repositoryTracing) RemoveUser(ctx context.Context, id domain.UserID) error {
+	ctx, span := r.start(ctx, "RemoveUser")
+	defer span.End()
+
+	return recordError(span, r.wrapped.RemoveUser(ctx, id))
+}
+
+func (r repositoryTracing) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	ctx, span := r.start(ctx, "Users")
+	defer span.End()
+
+	users, err := r.wrapped.Users(ctx, filter, pagination)
+	return users, recordError(span, err)
+}
+
+func (r repositoryTracing) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	ctx, span := r.start(ctx, "CountryCounts")
+	defer span.End()
+
+	counts, err := r.wrapped.CountryCounts(ctx)
+	return counts, recordError(span, err)
+}
@@ -0,0 +1,111 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// repositoryStub returns the configured error from every method, the contexts it gets called with are kept
+type repositoryStub struct {
+	err      error
+	contexts *[]context.Context
+}
+
+func (r repositoryStub) called(ctx context.Context) error {
+	*r.contexts = append(*r.contexts, ctx)
+	return r.err
+}
+
+func (r repositoryStub) AddUser(ctx context.Context, _ domain.User) error { return r.called(ctx) }
+func (r repositoryStub) ModifyUser(ctx context.Context, _ domain.UserID, _ domain.Fields) (domain.User, error) {
+	return domain.User{}, r.called(ctx)
+}
+func (r repositoryStub) RemoveUser(ctx context.Context, _ domain.UserID) error { return r.called(ctx) }
+func (r repositoryStub) Users(ctx context.Context, _ domain.Filter, _ domain.Pagination) ([]domain.User, error) {
+	return nil, r.called(ctx)
+}
+func (r repositoryStub) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	return nil, r.called(ctx)
+}
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func TestRepositoryTracingWrapper(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(ctx context.Context, repo domain.Repository) error
+		err  error
+	}{
+		{
+			name: "AddUser",
+			call: func(ctx context.Context, repo domain.Repository) error { return repo.AddUser(ctx, domain.User{}) },
+		},
+		{
+			name: "ModifyUser",
+			call: func(ctx context.Context, repo domain.Repository) error {
+				_, err := repo.ModifyUser(ctx, domain.NewUserID(), domain.Fields{})
+				return err
+			},
+		},
+		{
+			name: "RemoveUser",
+			call: func(ctx context.Context, repo domain.Repository) error {
+				return repo.RemoveUser(ctx, domain.NewUserID())
+			},
+			err: errors.New("failed"),
+		},
+		{
+			name: "Users",
+			call: func(ctx context.Context, repo domain.Repository) error {
+				_, err := repo.Users(ctx, domain.Filter{}, domain.DefaultPagination)
+				return err
+			},
+			err: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			var contexts []context.Context
+			repo := NewRepositoryTracingWrapper(repositoryStub{err: tt.err, contexts: &contexts})
+
+			ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+			err := tt.call(ctx, repo)
+			parent.End()
+			assert.ErrorIs(t, err, tt.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			span := spans[0]
+			assert.Equal(t, "Repository."+tt.name, span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), "repository span is a child of the span from the context")
+			require.Len(t, contexts, 1)
+			assert.Equal(t, span.SpanContext(), trace.SpanContextFromContext(contexts[0]), "the repository gets the context of its span")
+
+			if tt.err != nil {
+				assert.Equal(t, codes.Error, span.Status().Code)
+			} else {
+				assert.Equal(t, codes.Unset, span.Status().Code)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 type UserDTO struct {
 	ID           uuid.UUID `db:"id"`
+	TenantID     string    `db:"tenant_id"`
 	FirstName    string    `db:"first_name"`
 	LastName     string    `db:"last_name"`
 	Nickname     string    `db:"nickname"`
@@ -31,6 +32,7 @@ func toDomainUsers(users []UserDTO) []domain.User {
 func fromDomain(user domain.User) UserDTO {
 	return UserDTO{
 		ID:           user.ID,
+		TenantID:     string(user.TenantID),
 		FirstName:    user.FirstName,
 		LastName:     user.LastName,
 		Nickname:     user.Nickname,
@@ -45,6 +47,7 @@ func fromDomain(user domain.User) UserDTO {
 func toDomain(user UserDTO) domain.User {
 	return domain.User{
 		ID:           user.ID,
+		TenantID:     domain.TenantID(user.TenantID),
 		FirstName:    user.FirstName,
 		LastName:     user.LastName,
 		Nickname:     user.Nickname,
@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"time"
+	"users-app/domain"
+
+	"github.com/google/uuid"
+	"github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/postgresql"
+)
+
+// userEventDTO is a row of user_events, the outbox of the changes of the users the projections are built from
+type userEventDTO struct {
+	ID       int64            `db:"id,omitempty"`
+	Type     string           `db:"type"`
+	TenantID string           `db:"tenant_id"`
+	UserID   uuid.UUID        `db:"user_id"`
+	UserData postgresql.JSONB `db:"user_data"`
+}
+
+// userEventData is the user carried by the events, its fields are named after the columns of users_view,
+// so that the rows can be populated from it directly
+type userEventData struct {
+	ID        uuid.UUID `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname"`
+	Email     string    `json:"email"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// appendUserEvent adds the event of the change to user_events, it must be called in the transaction of the change.
+// The user is nil for the deletions. The event gets the id of the transaction (tx_id), which orders it for the
+// projections, see Projection.
+func appendUserEvent(tx db.Session, msg domain.EventMsg, tenant domain.TenantID, id domain.UserID, user *domain.User) error {
+	event := userEventDTO{Type: string(msg), TenantID: string(tenant), UserID: id, UserData: postgresql.JSONB{Data: struct{}{}}}
+	if user != nil {
+		event.UserData = postgresql.JSONB{Data: userEventData{
+			ID:        user.ID,
+			TenantID:  string(user.TenantID),
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Nickname:  user.Nickname,
+			Email:     user.Email,
+			Country:   user.Country,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		}}
+	}
+	_, err := tx.Collection("user_events").Insert(event)
+
+	return err
+}
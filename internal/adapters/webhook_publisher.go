@@ -0,0 +1,308 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"users-app/domain"
+	"users-app/logging"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+type WebhookConfig struct {
+	// MaxAttempts is the number of delivery attempts of a single event, including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, it's doubled with every next retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxConsecutiveFailures is the number of failed deliveries (after all retries) after which
+	// the subscription gets disabled. 0 means that subscriptions never get disabled.
+	MaxConsecutiveFailures int
+	// Timeout of a single HTTP call
+	Timeout time.Duration
+}
+
+// WebhookPublisher is a domain.Publisher, which delivers events to the registered webhook subscribers with HTTP POST.
+//
+// Every delivery is signed with HMAC-SHA256 using the subscription's secret - the signature is calculated
+// over "<timestamp>.<body>" and sent in the X-Webhook-Signature header as "sha256=<hex>".
+// Deliveries are retried with exponential backoff, every attempt is stored in the delivery log.
+// Subscriptions, which keep failing, are disabled automatically.
+//
+// Deliveries are done asynchronously, so that a slow subscriber doesn't block the other publishers. The delivery is
+// best-effort: the retries are kept only in memory, the ones still waiting for their backoff are abandoned
+// on shutdown (see Wait) and lost on a crash.
+//
+// The subscribers are called only on public addresses, the address is checked when it's dialed (after the name
+// of the host is resolved), and the redirects are not followed, so that the webhooks can't reach the services
+// of the deployment (SSRF).
+type WebhookPublisher struct {
+	repo   domain.WebhookRepository
+	client *http.Client
+	config WebhookConfig
+
+	// stopped is canceled on shutdown, the retries waiting for their backoff are abandoned then
+	stopped context.Context
+	stop    context.CancelFunc
+	// pending tracks the deliveries in progress
+	pending sync.WaitGroup
+}
+
+func NewWebhookPublisher(repo domain.WebhookRepository, config WebhookConfig) *WebhookPublisher {
+	stopped, stop := context.WithCancel(context.Background())
+
+	return &WebhookPublisher{
+		repo:    repo,
+		client:  newWebhookClient(config.Timeout),
+		config:  config,
+		stopped: stopped,
+		stop:    stop,
+	}
+}
+
+// errPrivateWebhookAddress is returned when the host of a webhook resolves to an address which is not public
+var errPrivateWebhookAddress = errors.New("webhook address is not public")
+
+// newWebhookClient returns the client which dials only the public addresses and doesn't follow the redirects
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !domain.PublicWebhookAddress(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", errPrivateWebhookAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would dial the subscribers on its own, without the check of the address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookPayload is the body of every webhook delivery
+type webhookPayload struct {
+	// ID identifies the event, it stays the same between retries, so subscribers can deduplicate deliveries
+	ID         uuid.UUID              `json:"id"`
+	Type       domain.EventMsg        `json:"type"`
+	TenantID   domain.TenantID        `json:"tenant_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Payload    map[string]interface{} `json:"payload"`
+}
+
+// PublishEvent delivers the event to the subscribers of its tenant
+func (p *WebhookPublisher) PublishEvent(ctx context.Context, event domain.Event) error {
+	subscriptions, err := p.repo.EnabledSubscriptions(event.TenantID)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook subscriptions: %w", err)
+	}
+
+	payload := webhookPayload{
+		ID:         uuid.New(),
+		Type:       event.Msg,
+		TenantID:   event.TenantID,
+		OccurredAt: time.Now().UTC(),
+		Payload:    event.Payload(),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	for _, subscription := range subscriptions {
+		if !subscription.Accepts(event.Msg) {
+			continue
+		}
+
+		// deliveries outlive the request that triggered the event, so only the values (e.g. the trace) are kept
+		p.pending.Add(1)
+		go func(subscription domain.WebhookSubscription) {
+			defer p.pending.Done()
+			p.deliver(context.WithoutCancel(ctx), subscription, payload, body)
+		}(subscription)
+	}
+
+	return nil
+}
+
+// Wait abandons the retries waiting for their backoff and blocks until the attempts in progress are finished,
+// or the context is done. It's called on shutdown, the publisher doesn't deliver the events afterwards.
+func (p *WebhookPublisher) Wait(ctx context.Context) error {
+	p.stop()
+	done := make(chan struct{})
+	go func() {
+		p.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhook deliveries still pending: %w", ctx.Err())
+	}
+}
+
+// deliver sends the event to the subscriber, retrying with exponential backoff
+func (p *WebhookPublisher) deliver(ctx context.Context, subscription domain.WebhookSubscription, payload webhookPayload, body []byte) {
+	backoff := p.config.InitialBackoff
+	succeeded := false
+	logger := logging.FromContext(ctx).With(zap.Stringer("webhook_id", subscription.ID))
+
+	for attempt := 1; attempt <= p.config.MaxAttempts; attempt++ {
+		delivery := p.send(ctx, subscription, payload, body)
+		delivery.Attempt = attempt
+
+		if err := p.repo.AddDelivery(delivery); err != nil {
+			logger.Error("failed to store webhook delivery", zap.Error(err))
+		}
+
+		if delivery.Succeeded {
+			succeeded = true
+			break
+		}
+
+		if attempt < p.config.MaxAttempts {
+			if !p.sleep(backoff) {
+				// the subscriber didn't fail, the delivery is not counted
+				logger.Warn("webhook delivery abandoned on shutdown", zap.Int("attempts", attempt))
+				return
+			}
+			backoff = min(backoff*2, p.config.MaxBackoff)
+		}
+	}
+
+	p.registerResult(logger, subscription.TenantID, subscription.ID, succeeded)
+}
+
+// sleep waits for the backoff, it returns false when the publisher is stopped in the meantime
+func (p *WebhookPublisher) sleep(backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-p.stopped.Done():
+		return false
+	}
+}
+
+func (p *WebhookPublisher) send(ctx context.Context, subscription domain.WebhookSubscription, payload webhookPayload, body []byte) domain.WebhookDelivery {
+	delivery := domain.WebhookDelivery{
+		ID:             uuid.New(),
+		TenantID:       subscription.TenantID,
+		SubscriptionID: subscription.ID,
+		EventType:      payload.Type,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	ctx, span := tracer().Start(ctx, "webhook deliver "+string(payload.Type), trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	defer func() {
+		span.SetAttributes(attribute.Int("http.response.status_code", delivery.StatusCode))
+		if !delivery.Succeeded {
+			span.SetStatus(codes.Error, delivery.Error)
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, payload.ID.String())
+	req.Header.Set(WebhookEventHeader, string(payload.Type))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, timestamp, body))
+	// traceparent header lets the subscriber continue the trace
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	delivery.Duration = time.Since(start)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Succeeded = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Succeeded {
+		delivery.Error = fmt.Sprintf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return delivery
+}
+
+// registerResult updates the failures counter of the subscription, disabling it if needed
+func (p *WebhookPublisher) registerResult(logger *zap.Logger, tenant domain.TenantID, id domain.WebhookID, succeeded bool) {
+	subscription, err := p.repo.RegisterDeliveryResult(tenant, id, succeeded, p.config.MaxConsecutiveFailures)
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		// the subscription might have been removed in the meantime
+		logger.Warn("webhook subscription not found", zap.Error(err))
+		return
+	}
+	if err != nil {
+		logger.Error("failed to update webhook subscription", zap.Error(err))
+		return
+	}
+
+	if !succeeded && !subscription.Enabled {
+		logger.Warn("webhook subscription disabled", zap.Int("consecutive_failures", subscription.ConsecutiveFailures))
+	}
+}
+
+// SignWebhook calculates the signature sent in the X-Webhook-Signature header.
+// Subscribers should calculate it on their side and compare with the received one.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,197 @@
+package adapters
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRepositoryStub keeps the webhook subscriptions and deliveries in memory
+type webhookRepositoryStub struct {
+	domain.WebhookRepository
+
+	mu           sync.Mutex
+	subscription domain.WebhookSubscription
+	deliveries   []domain.WebhookDelivery
+}
+
+func (r *webhookRepositoryStub) Subscription(domain.TenantID, domain.WebhookID) (domain.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subscription, nil
+}
+
+func (r *webhookRepositoryStub) UpdateSubscription(s domain.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscription = s
+	return nil
+}
+
+func (r *webhookRepositoryStub) RegisterDeliveryResult(
+	_ domain.TenantID, _ domain.WebhookID, succeeded bool, maxConsecutiveFailures int,
+) (domain.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscription.RegisterDeliveryResult(succeeded, maxConsecutiveFailures)
+	return r.subscription, nil
+}
+
+func (r *webhookRepositoryStub) AddDelivery(d domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, d)
+	return nil
+}
+
+func TestWebhookPublisher_deliver(t *testing.T) {
+	tests := []struct {
+		name string
+		// statuses returned by the subscriber for the consecutive calls
+		statuses    []int
+		failures    int
+		maxFailures int
+
+		expectedAttempts int
+		expectedFailures int
+		expectedEnabled  bool
+	}{
+		{name: "delivered_at_first_attempt", statuses: []int{200}, failures: 2, maxFailures: 3, expectedAttempts: 1, expectedFailures: 0, expectedEnabled: true},
+		{name: "delivered_after_retries", statuses: []int{500, 502, 204}, maxFailures: 3, expectedAttempts: 3, expectedFailures: 0, expectedEnabled: true},
+		{name: "all_attempts_failed", statuses: []int{500, 500, 500}, maxFailures: 3, expectedAttempts: 3, expectedFailures: 1, expectedEnabled: true},
+		{name: "subscription_disabled_after_too_many_failures", statuses: []int{500, 500, 500}, failures: 2, maxFailures: 3, expectedAttempts: 3, expectedFailures: 3, expectedEnabled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription, err := domain.NewWebhookSubscription(domain.DefaultTenant, "http://example.com", nil)
+			assert.NoError(t, err)
+			subscription.ConsecutiveFailures = tt.failures
+
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				expectedSignature := SignWebhook(subscription.Secret, r.Header.Get(WebhookTimestampHeader), body)
+				assert.Equal(t, expectedSignature, r.Header.Get(WebhookSignatureHeader))
+
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer server.Close()
+			subscription.URL = server.URL
+
+			repo := &webhookRepositoryStub{subscription: subscription}
+			publisher := NewWebhookPublisher(repo, WebhookConfig{
+				MaxAttempts:            3,
+				InitialBackoff:         time.Millisecond,
+				MaxBackoff:             time.Millisecond,
+				MaxConsecutiveFailures: tt.maxFailures,
+				Timeout:                time.Second,
+			})
+			// the test server listens on the loopback address
+			publisher.client = server.Client()
+
+			publisher.deliver(context.Background(), subscription, webhookPayload{Type: domain.UserAdded}, []byte(`{}`))
+
+			assert.Len(t, repo.deliveries, tt.expectedAttempts)
+			assert.Equal(t, tt.expectedFailures, repo.subscription.ConsecutiveFailures)
+			assert.Equal(t, tt.expectedEnabled, repo.subscription.Enabled)
+		})
+	}
+}
+
+func TestWebhookPublisher_PublishEvent_delivers_to_the_tenant_of_the_event(t *testing.T) {
+	received := map[domain.TenantID]int{}
+	mu := sync.Mutex{}
+	newSubscriber := func(tenant domain.TenantID) domain.WebhookSubscription {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[tenant]++
+		}))
+		t.Cleanup(server.Close)
+
+		subscription, err := domain.NewWebhookSubscription(tenant, "http://example.com", nil)
+		assert.NoError(t, err)
+		subscription.URL = server.URL
+		return subscription
+	}
+
+	repo := NewMemoryWebhookRepository()
+	for _, tenant := range []domain.TenantID{"tenant-a", "tenant-b"} {
+		assert.NoError(t, repo.AddSubscription(newSubscriber(tenant)))
+	}
+	publisher := NewWebhookPublisher(repo, WebhookConfig{MaxAttempts: 1, Timeout: time.Second})
+	publisher.client = &http.Client{Timeout: time.Second}
+
+	err := publisher.PublishEvent(context.Background(), domain.Event{Msg: domain.UserAdded, TenantID: "tenant-a"})
+	assert.NoError(t, err)
+	assert.NoError(t, publisher.Wait(context.Background()))
+
+	assert.Equal(t, map[domain.TenantID]int{"tenant-a": 1}, received)
+}
+
+func TestWebhookPublisher_send_reaches_only_public_addresses(t *testing.T) {
+	var called atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called.Store(true) }))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	subscription, err := domain.NewWebhookSubscription(domain.DefaultTenant, "http://example.com", nil)
+	require.NoError(t, err)
+	publisher := NewWebhookPublisher(&webhookRepositoryStub{}, WebhookConfig{MaxAttempts: 1, Timeout: time.Second})
+
+	subscription.URL = target.URL
+	delivery := publisher.send(context.Background(), subscription, webhookPayload{Type: domain.UserAdded}, []byte(`{}`))
+	assert.False(t, delivery.Succeeded)
+	assert.Contains(t, delivery.Error, errPrivateWebhookAddress.Error(), "the loopback address is refused when it's dialed")
+
+	// the redirects are not followed, the test servers are reached with a client without the check of the address
+	publisher.client.Transport = http.DefaultTransport
+	subscription.URL = redirect.URL
+	delivery = publisher.send(context.Background(), subscription, webhookPayload{Type: domain.UserAdded}, []byte(`{}`))
+	assert.False(t, delivery.Succeeded)
+	assert.Equal(t, http.StatusFound, delivery.StatusCode)
+	assert.False(t, called.Load())
+}
+
+func TestWebhookPublisher_Wait_abandons_the_retries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	subscription, err := domain.NewWebhookSubscription(domain.DefaultTenant, "http://example.com", nil)
+	require.NoError(t, err)
+	subscription.URL = server.URL
+	repo := &webhookRepositoryStub{subscription: subscription}
+	publisher := NewWebhookPublisher(repo, WebhookConfig{
+		MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxConsecutiveFailures: 1, Timeout: time.Second,
+	})
+	publisher.client = server.Client()
+
+	publisher.pending.Add(1)
+	go func() {
+		defer publisher.pending.Done()
+		publisher.deliver(context.Background(), subscription, webhookPayload{Type: domain.UserAdded}, []byte(`{}`))
+	}()
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.deliveries) == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, publisher.Wait(ctx))
+	assert.True(t, repo.subscription.Enabled, "the abandoned delivery is not counted as a failure")
+}
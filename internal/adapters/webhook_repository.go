@@ -0,0 +1,232 @@
+package adapters
+
+import (
+	"errors"
+	"time"
+	"users-app/domain"
+
+	"github.com/google/uuid"
+	"github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/postgresql"
+)
+
+// webhookRepository stores webhook subscriptions and the log of their deliveries in postgres,
+// every query is limited to the rows of a single tenant
+type webhookRepository struct {
+	db db.Session
+}
+
+func NewWebhookRepository(repositoryConfig RepoConfig) webhookRepository {
+	return webhookRepository{db: openSession(repositoryConfig)}
+}
+
+// Close closes the database session
+func (r webhookRepository) Close() error {
+	return r.db.Close()
+}
+
+type WebhookSubscriptionDTO struct {
+	ID                  uuid.UUID              `db:"id"`
+	TenantID            string                 `db:"tenant_id"`
+	URL                 string                 `db:"url"`
+	EventTypes          postgresql.StringArray `db:"event_types"`
+	Secret              string                 `db:"secret"`
+	Enabled             bool                   `db:"enabled"`
+	ConsecutiveFailures int                    `db:"consecutive_failures"`
+	CreatedAt           time.Time              `db:"created_at"`
+	UpdatedAt           time.Time              `db:"updated_at"`
+}
+
+type WebhookDeliveryDTO struct {
+	ID             uuid.UUID `db:"id"`
+	TenantID       string    `db:"tenant_id"`
+	SubscriptionID uuid.UUID `db:"subscription_id"`
+	EventType      string    `db:"event_type"`
+	Attempt        int       `db:"attempt"`
+	StatusCode     int       `db:"status_code"`
+	Error          string    `db:"error"`
+	Succeeded      bool      `db:"succeeded"`
+	DurationMs     int64     `db:"duration_ms"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+func (r webhookRepository) AddSubscription(subscription domain.WebhookSubscription) error {
+	_, err := r.db.Collection("webhook_subscriptions").Insert(subscriptionFromDomain(subscription))
+	return err
+}
+
+// UpdateSubscription overwrites the stored subscription with the given one
+func (r webhookRepository) UpdateSubscription(subscription domain.WebhookSubscription) error {
+	res := r.db.Collection("webhook_subscriptions").Find(db.Cond{"tenant_id": subscription.TenantID, "id": subscription.ID})
+	exists, err := res.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrWebhookNotFound
+	}
+
+	return res.Update(subscriptionFromDomain(subscription))
+}
+
+// RemoveSubscription removes the subscription together with its deliveries log
+func (r webhookRepository) RemoveSubscription(tenant domain.TenantID, id domain.WebhookID) error {
+	return r.db.Collection("webhook_subscriptions").Find(db.Cond{"tenant_id": tenant, "id": id}).Delete()
+}
+
+func (r webhookRepository) Subscription(tenant domain.TenantID, id domain.WebhookID) (domain.WebhookSubscription, error) {
+	var ret WebhookSubscriptionDTO
+	err := r.db.Collection("webhook_subscriptions").Find(db.Cond{"tenant_id": tenant, "id": id}).One(&ret)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return domain.WebhookSubscription{}, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	return subscriptionToDomain(ret), nil
+}
+
+func (r webhookRepository) Subscriptions(tenant domain.TenantID, pagination domain.Pagination) ([]domain.WebhookSubscription, error) {
+	query := r.db.Collection("webhook_subscriptions").Find(db.Cond{"tenant_id": tenant}).
+		OrderBy("created_at").
+		Limit(pagination.Limit()).
+		Offset(pagination.Offset)
+
+	var ret []WebhookSubscriptionDTO
+	if err := query.All(&ret); err != nil {
+		return nil, err
+	}
+
+	return subscriptionsToDomain(ret), nil
+}
+
+func (r webhookRepository) EnabledSubscriptions(tenant domain.TenantID) ([]domain.WebhookSubscription, error) {
+	var ret []WebhookSubscriptionDTO
+	err := r.db.Collection("webhook_subscriptions").Find(db.Cond{"tenant_id": tenant, "enabled": true}).All(&ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptionsToDomain(ret), nil
+}
+
+// registerDeliveryResultQuery applies WebhookSubscription.RegisterDeliveryResult in a single statement, so that
+// it doesn't overwrite the concurrent changes of the subscription (e.g. re-enabling it) made by any instance
+const registerDeliveryResultQuery = `
+UPDATE webhook_subscriptions SET
+	consecutive_failures = CASE WHEN ? THEN 0 ELSE consecutive_failures + 1 END,
+	enabled = enabled AND (? OR ? <= 0 OR consecutive_failures + 1 < ?),
+	updated_at = now()
+WHERE tenant_id = ? AND id = ?
+RETURNING *`
+
+func (r webhookRepository) RegisterDeliveryResult(
+	tenant domain.TenantID, id domain.WebhookID, succeeded bool, maxConsecutiveFailures int,
+) (domain.WebhookSubscription, error) {
+	var ret []WebhookSubscriptionDTO
+	err := r.db.SQL().Iterator(registerDeliveryResultQuery,
+		succeeded, succeeded, maxConsecutiveFailures, maxConsecutiveFailures, tenant, id).All(&ret)
+	if err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+	if len(ret) == 0 {
+		return domain.WebhookSubscription{}, domain.ErrWebhookNotFound
+	}
+
+	return subscriptionToDomain(ret[0]), nil
+}
+
+func (r webhookRepository) AddDelivery(delivery domain.WebhookDelivery) error {
+	_, err := r.db.Collection("webhook_deliveries").Insert(WebhookDeliveryDTO{
+		ID:             delivery.ID,
+		TenantID:       string(delivery.TenantID),
+		SubscriptionID: delivery.SubscriptionID,
+		EventType:      string(delivery.EventType),
+		Attempt:        delivery.Attempt,
+		StatusCode:     delivery.StatusCode,
+		Error:          delivery.Error,
+		Succeeded:      delivery.Succeeded,
+		DurationMs:     delivery.Duration.Milliseconds(),
+		CreatedAt:      delivery.CreatedAt,
+	})
+	return err
+}
+
+// Deliveries returns the deliveries log of a subscription, the most recent deliveries first
+func (r webhookRepository) Deliveries(tenant domain.TenantID, id domain.WebhookID, pagination domain.Pagination) ([]domain.WebhookDelivery, error) {
+	query := r.db.Collection("webhook_deliveries").Find(db.Cond{"tenant_id": tenant, "subscription_id": id}).
+		OrderBy("-created_at").
+		Limit(pagination.Limit()).
+		Offset(pagination.Offset)
+
+	var deliveries []WebhookDeliveryDTO
+	if err := query.All(&deliveries); err != nil {
+		return nil, err
+	}
+
+	ret := make([]domain.WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		ret[i] = domain.WebhookDelivery{
+			ID:             d.ID,
+			TenantID:       domain.TenantID(d.TenantID),
+			SubscriptionID: d.SubscriptionID,
+			EventType:      domain.EventMsg(d.EventType),
+			Attempt:        d.Attempt,
+			StatusCode:     d.StatusCode,
+			Error:          d.Error,
+			Succeeded:      d.Succeeded,
+			Duration:       time.Duration(d.DurationMs) * time.Millisecond,
+			CreatedAt:      d.CreatedAt,
+		}
+	}
+
+	return ret, nil
+}
+
+func subscriptionFromDomain(s domain.WebhookSubscription) WebhookSubscriptionDTO {
+	eventTypes := make(postgresql.StringArray, len(s.EventTypes))
+	for i, t := range s.EventTypes {
+		eventTypes[i] = string(t)
+	}
+
+	return WebhookSubscriptionDTO{
+		ID:                  s.ID,
+		TenantID:            string(s.TenantID),
+		URL:                 s.URL,
+		EventTypes:          eventTypes,
+		Secret:              s.Secret,
+		Enabled:             s.Enabled,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+	}
+}
+
+func subscriptionToDomain(s WebhookSubscriptionDTO) domain.WebhookSubscription {
+	eventTypes := make([]domain.EventMsg, len(s.EventTypes))
+	for i, t := range s.EventTypes {
+		eventTypes[i] = domain.EventMsg(t)
+	}
+
+	return domain.WebhookSubscription{
+		ID:                  s.ID,
+		TenantID:            domain.TenantID(s.TenantID),
+		URL:                 s.URL,
+		EventTypes:          eventTypes,
+		Secret:              s.Secret,
+		Enabled:             s.Enabled,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+	}
+}
+
+func subscriptionsToDomain(subscriptions []WebhookSubscriptionDTO) []domain.WebhookSubscription {
+	ret := make([]domain.WebhookSubscription, len(subscriptions))
+	for i, s := range subscriptions {
+		ret[i] = subscriptionToDomain(s)
+	}
+
+	return ret
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// apiKeyEntry is an API key in the API keys file, only the hash of the key is kept:
+//
+//   - name: billing
+//     sha256: 5d41402abc4b2a76b9719d911017c592...
+//     scopes: [users:read]
+type apiKeyEntry struct {
+	Name   string   `yaml:"name"`
+	SHA256 string   `yaml:"sha256"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// apiKeys are the callers by the hashes of their keys
+type apiKeys map[string]Principal
+
+func readAPIKeys(path string) (apiKeys, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []apiKeyEntry
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+
+	keys := make(apiKeys, len(entries))
+	for i, entry := range entries {
+		hash := strings.ToLower(entry.SHA256)
+		if entry.Name == "" {
+			return nil, fmt.Errorf("key %d: the name is required", i+1)
+		}
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("key %s: sha256 must be a hex-encoded SHA-256 hash", entry.Name)
+		}
+		if _, ok := keys[hash]; ok {
+			return nil, fmt.Errorf("key %s: the same key is listed twice", entry.Name)
+		}
+		keys[hash] = Principal{Subject: entry.Name, Method: "api_key", Scopes: toScopes(entry.Scopes)}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no keys found")
+	}
+
+	return keys, nil
+}
+
+// lookup finds the caller by the hash of the key, the keys are random, so a fast hash is enough
+func (k apiKeys) lookup(key string) (Principal, bool) {
+	principal, ok := k[HashAPIKey(key)]
+	return principal, ok
+}
+
+// HashAPIKey returns the hash of the key, as it's kept in the API keys file
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewAPIKey generates a random API key
+func NewAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
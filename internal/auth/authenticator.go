@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNoCredentials is returned when the caller sent neither a JWT nor an API key
+	ErrNoCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned when the JWT or the API key can't be verified
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Credentials are sent by the caller, the JWT in the Authorization header (Bearer) and the API key
+// in the X-API-Key header, or the authorization and x-api-key gRPC metadata
+type Credentials struct {
+	BearerToken string
+	APIKey      string
+}
+
+type Config struct {
+	// JWKSFile is the JSON Web Key Set the JWTs are verified with, empty disables the JWTs
+	JWKSFile string
+	// Issuer and Audience are checked against the iss and aud claims of the JWTs, when they are set
+	Issuer   string
+	Audience string
+	// APIKeysFile lists the hashes of the API keys and their scopes, empty disables the API keys
+	APIKeysFile string
+}
+
+// Authenticator verifies the credentials of the callers
+type Authenticator struct {
+	jwt     *jwtVerifier
+	apiKeys apiKeys
+	now     func() time.Time
+}
+
+// NewAuthenticator reads the key set and the API keys, at least one of them must be configured
+func NewAuthenticator(config Config) (*Authenticator, error) {
+	if config.JWKSFile == "" && config.APIKeysFile == "" {
+		return nil, errors.New("neither the JWKS nor the API keys file is set")
+	}
+
+	a := &Authenticator{now: time.Now}
+	if config.JWKSFile != "" {
+		verifier, err := newJWTVerifier(config.JWKSFile, config.Issuer, config.Audience)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the JWKS: %w", err)
+		}
+		a.jwt = verifier
+	}
+	if config.APIKeysFile != "" {
+		keys, err := readAPIKeys(config.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the API keys: %w", err)
+		}
+		a.apiKeys = keys
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the caller identified by the credentials, the JWT is checked when both are sent.
+// The errors wrap ErrNoCredentials or ErrInvalidCredentials.
+func (a *Authenticator) Authenticate(credentials Credentials) (Principal, error) {
+	switch {
+	case credentials.BearerToken != "" && a.jwt != nil:
+		principal, err := a.jwt.verify(credentials.BearerToken, a.now())
+		if err != nil {
+			return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		return principal, nil
+	case credentials.APIKey != "" && a.apiKeys != nil:
+		principal, ok := a.apiKeys.lookup(credentials.APIKey)
+		if !ok {
+			return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+		}
+		return principal, nil
+	case credentials.BearerToken != "" || credentials.APIKey != "":
+		return Principal{}, fmt.Errorf("%w: the credentials of this kind are not accepted", ErrInvalidCredentials)
+	default:
+		return Principal{}, ErrNoCredentials
+	}
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func writeFile(t *testing.T, name string, content []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	return path
+}
+
+func newSigningKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// signToken creates a JWT signed with the key, the claims are merged into the registered ones
+func signToken(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.Claims, extra map[string]interface{}) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(signer).Claims(claims).Claims(extra).Serialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	key := newSigningKey(t)
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: key.Public(), KeyID: "key-1", Algorithm: "ES256"}}})
+	require.NoError(t, err)
+	apiKeys := "- name: billing\n  sha256: " + HashAPIKey("billing-key") + "\n  scopes: [users:read]\n"
+
+	authenticator, err := NewAuthenticator(Config{
+		JWKSFile:    writeFile(t, "jwks.json", jwks),
+		Issuer:      "https://issuer.example.com",
+		Audience:    "users-app",
+		APIKeysFile: writeFile(t, "api_keys.yml", []byte(apiKeys)),
+	})
+	require.NoError(t, err)
+	authenticator.now = func() time.Time { return testNow }
+
+	valid := jwt.Claims{
+		Subject:  "reporting",
+		Issuer:   "https://issuer.example.com",
+		Audience: jwt.Audience{"users-app"},
+		Expiry:   jwt.NewNumericDate(testNow.Add(time.Hour)),
+	}
+	with := func(modify func(*jwt.Claims)) jwt.Claims {
+		claims := valid
+		modify(&claims)
+		return claims
+	}
+
+	tests := []struct {
+		name          string
+		credentials   Credentials
+		wantPrincipal Principal
+		wantErr       error
+	}{
+		{
+			name:          "jwt_with_scope_claim",
+			credentials:   Credentials{BearerToken: signToken(t, key, "key-1", valid, map[string]interface{}{"scope": "users:read users:write"})},
+			wantPrincipal: Principal{Subject: "reporting", Method: "jwt", Scopes: []Scope{ScopeRead, ScopeWrite}},
+		},
+		{
+			name:          "jwt_with_scp_claim",
+			credentials:   Credentials{BearerToken: signToken(t, key, "key-1", valid, map[string]interface{}{"scp": []string{"users:admin"}})},
+			wantPrincipal: Principal{Subject: "reporting", Method: "jwt", Scopes: []Scope{ScopeAdmin}},
+		},
+		{
+			name:        "jwt_expired",
+			credentials: Credentials{BearerToken: signToken(t, key, "key-1", with(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(testNow.Add(-time.Hour)) }), nil)},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "jwt_without_expiry",
+			credentials: Credentials{BearerToken: signToken(t, key, "key-1", with(func(c *jwt.Claims) { c.Expiry = nil }), nil)},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "jwt_other_issuer",
+			credentials: Credentials{BearerToken: signToken(t, key, "key-1", with(func(c *jwt.Claims) { c.Issuer = "https://evil.example.com" }), nil)},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "jwt_other_audience",
+			credentials: Credentials{BearerToken: signToken(t, key, "key-1", with(func(c *jwt.Claims) { c.Audience = jwt.Audience{"billing"} }), nil)},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "jwt_unknown_key",
+			credentials: Credentials{BearerToken: signToken(t, key, "key-2", valid, nil)},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "jwt_signed_with_other_key",
+			credentials: Credentials{BearerToken: signToken(t, newSigningKey(t), "key-1", valid, nil)},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:        "malformed_jwt",
+			credentials: Credentials{BearerToken: "not-a-jwt"},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:          "api_key",
+			credentials:   Credentials{APIKey: "billing-key"},
+			wantPrincipal: Principal{Subject: "billing", Method: "api_key", Scopes: []Scope{ScopeRead}},
+		},
+		{
+			name:        "unknown_api_key",
+			credentials: Credentials{APIKey: "other-key"},
+			wantErr:     ErrInvalidCredentials,
+		},
+		{
+			name:    "no_credentials",
+			wantErr: ErrNoCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(tt.credentials)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrincipal, principal)
+		})
+	}
+}
+
+func TestAuthenticator_Authenticate_disabled_kind(t *testing.T) {
+	apiKeys := "- name: billing\n  sha256: " + HashAPIKey("billing-key") + "\n  scopes: [users:read]\n"
+	authenticator, err := NewAuthenticator(Config{APIKeysFile: writeFile(t, "api_keys.yml", []byte(apiKeys))})
+	require.NoError(t, err)
+
+	_, err = authenticator.Authenticate(Credentials{BearerToken: "eyJ.eyJ.sig"})
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "the JWTs are rejected without the JWKS")
+}
+
+func TestNewAuthenticator_invalid_api_keys(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "no_keys", content: "[]", wantErr: "no keys found"},
+		{name: "no_name", content: "- sha256: " + HashAPIKey("key"), wantErr: "the name is required"},
+		{name: "plain_key", content: "- name: billing\n  sha256: key", wantErr: "hex-encoded SHA-256"},
+		{
+			name:    "duplicated_key",
+			content: "- name: a\n  sha256: " + HashAPIKey("key") + "\n- name: b\n  sha256: " + HashAPIKey("key"),
+			wantErr: "listed twice",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAuthenticator(Config{APIKeysFile: writeFile(t, "api_keys.yml", []byte(tt.content))})
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	tests := []struct {
+		granted  []Scope
+		required Scope
+		want     bool
+	}{
+		{granted: []Scope{ScopeRead}, required: ScopeRead, want: true},
+		{granted: []Scope{ScopeRead}, required: ScopeWrite, want: false},
+		{granted: []Scope{ScopeWrite}, required: ScopeRead, want: true},
+		{granted: []Scope{ScopeWrite}, required: ScopeAdmin, want: false},
+		{granted: []Scope{ScopeAdmin}, required: ScopeRead, want: true},
+		{granted: []Scope{"orders:admin"}, required: ScopeRead, want: false},
+		{granted: nil, required: ScopeRead, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.required), func(t *testing.T) {
+			assert.Equal(t, tt.want, Principal{Scopes: tt.granted}.HasScope(tt.required), "granted %v", tt.granted)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Package auth identifies the callers of the API.
+//
+// The ports put the identity of the caller into the context of every request, so that it can be logged and checked
+// the same way regardless of the transport.
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// ClientIdentity describes the client, which authenticated with a certificate verified against the client CA (mTLS)
+type ClientIdentity struct {
+	// Subject is the distinguished name of the certificate, e.g. CN=billing,O=Example
+	Subject    string
+	CommonName string
+	// DNSNames and URIs are the subject alternative names, e.g. the SPIFFE id is one of the URIs
+	DNSNames []string
+	URIs     []string
+}
+
+func NewClientIdentity(cert *x509.Certificate) ClientIdentity {
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return ClientIdentity{
+		Subject:    cert.Subject.String(),
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+		URIs:       uris,
+	}
+}
+
+// VerifiedClientIdentity returns the identity from the client certificate of the connection,
+// false when the connection is not encrypted or the client didn't present a verified certificate
+func VerifiedClientIdentity(state *tls.ConnectionState) (ClientIdentity, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ClientIdentity{}, false
+	}
+
+	return NewClientIdentity(state.VerifiedChains[0][0]), true
+}
+
+type clientIdentityKey struct{}
+
+// WithClientIdentity returns a copy of the context carrying the identity of the client
+func WithClientIdentity(ctx context.Context, identity ClientIdentity) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, identity)
+}
+
+// ClientIdentityFromContext returns the identity of the client, false when the client didn't present a certificate
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityKey{}).(ClientIdentity)
+	return identity, ok
+}
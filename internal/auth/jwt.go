@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// signatureAlgorithms are accepted in the JWTs, the symmetric ones are not - the key set is public
+var signatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.PS256, jose.ES256, jose.ES384, jose.EdDSA}
+
+// jwtVerifier checks the signature of the JWTs with the key set read from a file (e.g. mounted from the identity
+// provider's /.well-known/jwks.json), the exp, nbf, iss and aud claims
+type jwtVerifier struct {
+	keys     jose.JSONWebKeySet
+	issuer   string
+	audience string
+}
+
+func newJWTVerifier(path, issuer, audience string) (*jwtVerifier, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, err
+	}
+	if len(keys.Keys) == 0 {
+		return nil, errors.New("no keys found")
+	}
+
+	return &jwtVerifier{keys: keys, issuer: issuer, audience: audience}, nil
+}
+
+// tokenClaims are the claims besides the registered ones, the scopes are either a space-separated scope claim
+// (RFC 8693) or a scp array, depending on the identity provider
+type tokenClaims struct {
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+}
+
+func (v *jwtVerifier) verify(token string, now time.Time) (Principal, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	var claims jwt.Claims
+	var custom tokenClaims
+	if err := v.claims(parsed, &claims, &custom); err != nil {
+		return Principal{}, err
+	}
+
+	expected := jwt.Expected{Issuer: v.issuer, Time: now}
+	if v.audience != "" {
+		expected.AnyAudience = jwt.Audience{v.audience}
+	}
+	if err := claims.Validate(expected); err != nil {
+		return Principal{}, err
+	}
+	if claims.Expiry == nil {
+		return Principal{}, errors.New("the exp claim is required")
+	}
+	if claims.Subject == "" {
+		return Principal{}, errors.New("the sub claim is required")
+	}
+
+	scopes := custom.Scp
+	if custom.Scope != "" {
+		scopes = strings.Fields(custom.Scope)
+	}
+
+	return Principal{Subject: claims.Subject, Method: "jwt", Scopes: toScopes(scopes)}, nil
+}
+
+// claims verifies the signature with the key of the kid header, or with any of the keys when the header is missing
+func (v *jwtVerifier) claims(token *jwt.JSONWebToken, dest ...interface{}) error {
+	keys := v.keys.Keys
+	if kid := token.Headers[0].KeyID; kid != "" {
+		keys = v.keys.Key(kid)
+		if len(keys) == 0 {
+			return fmt.Errorf("unknown key %q", kid)
+		}
+	}
+
+	var err error
+	for _, key := range keys {
+		if err = token.Claims(key.Public(), dest...); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func toScopes(names []string) []Scope {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		scopes = append(scopes, Scope(name))
+	}
+
+	return scopes
+}
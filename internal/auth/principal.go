@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Scope is a permission granted to the caller
+type Scope string
+
+// The scopes are nested: users:admin includes users:write, which includes users:read
+const (
+	// ScopeRead lets the caller list the users and stream the events
+	ScopeRead Scope = "users:read"
+	// ScopeWrite lets the caller create and modify the users
+	ScopeWrite Scope = "users:write"
+	// ScopeAdmin lets the caller delete the users and manage the webhooks
+	ScopeAdmin Scope = "users:admin"
+)
+
+// implied lists the scopes granting the scope
+var implied = map[Scope][]Scope{
+	ScopeRead:  {ScopeRead, ScopeWrite, ScopeAdmin},
+	ScopeWrite: {ScopeWrite, ScopeAdmin},
+	ScopeAdmin: {ScopeAdmin},
+}
+
+// Principal is the authenticated caller
+type Principal struct {
+	// Subject is the sub claim of the JWT or the name of the API key
+	Subject string
+	// Method is jwt or api_key
+	Method string
+	Scopes []Scope
+}
+
+// HasScope tells if any of the granted scopes includes the required one
+func (p Principal) HasScope(required Scope) bool {
+	for _, granted := range implied[required] {
+		if slices.Contains(p.Scopes, granted) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, false when the request was not authenticated
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
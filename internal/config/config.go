@@ -0,0 +1,484 @@
+// Package config loads the application configuration.
+//
+// The configuration is read from the following sources, every next one overrides the previous ones:
+//   - defaults (see Default)
+//   - YAML file, passed with the --config flag or CONFIG_FILE environment variable
+//   - environment variables (see the env tags), every variable can be also read from a file
+//     pointed by the variable with the _FILE suffix (e.g. POSTGRES_PASSWORD_FILE), which is useful for secrets
+//   - command line flags, named after the YAML path (e.g. --http.port=8080)
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	HTTP        HTTPConfig        `yaml:"http"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	TLS         TLSConfig         `yaml:"tls"`
+	Auth        AuthConfig        `yaml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Tenant      TenantConfig      `yaml:"tenant"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Projection  ProjectionConfig  `yaml:"projection"`
+	DBReplica   DBReplicaConfig   `yaml:"db_replica"`
+	DB          DBConfig          `yaml:"db"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Log         LogConfig         `yaml:"log"`
+	Events      EventsConfig      `yaml:"events"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Health      HealthConfig      `yaml:"health"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+
+	// ShutdownTimeout limits the time of the graceful shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+
+	// Dev runs the whole application in a single process, with the users, webhooks and idempotency keys kept
+	// in memory and the events published in-process and printed to the console - Postgres and Redis are not needed
+	Dev bool `yaml:"dev" env:"DEV"`
+	// DevFixtures is the file with the users added at the startup in the dev mode,
+	// one JSON object per line (the same as the POST /users body), empty means no users
+	DevFixtures string `yaml:"dev_fixtures" env:"DEV_FIXTURES"`
+}
+
+type HTTPConfig struct {
+	Enabled bool `yaml:"enabled" env:"RUN_HTTP"`
+	Port    int  `yaml:"port" env:"PORT_HTTP"`
+}
+
+type GRPCConfig struct {
+	Enabled bool `yaml:"enabled" env:"RUN_GRPC"`
+	Port    int  `yaml:"port" env:"PORT_GRPC"`
+	// Multiplex serves gRPC on the HTTP port instead of grpc.port, the requests are dispatched on the content type
+	Multiplex bool `yaml:"multiplex" env:"GRPC_MULTIPLEX"`
+	// Web serves the gRPC-Web and Connect protocols on the HTTP port, so that the browsers can call the RPCs
+	Web bool `yaml:"web" env:"GRPC_WEB"`
+	// DefaultTimeout is the deadline of the calls made without one, 0 disables it
+	DefaultTimeout time.Duration `yaml:"default_timeout" env:"GRPC_DEFAULT_TIMEOUT"`
+}
+
+// TLSConfig is shared by the HTTP and the gRPC servers
+type TLSConfig struct {
+	// CertFile and KeyFile enable TLS, HTTP/2 is negotiated with ALPN then - without them the servers speak plaintext
+	// and the multiplexed gRPC calls are served over cleartext HTTP/2 (h2c)
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// ClientAuth is one of none, optional (the presented certificates are verified) and require (mTLS)
+	ClientAuth string `yaml:"client_auth" env:"TLS_CLIENT_AUTH"`
+	// ClientCAFile is the CA bundle the client certificates are verified against
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	// ReloadInterval is the interval of reading the files again, so that the renewed certificates are picked up
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL"`
+}
+
+// Enabled tells if the servers use TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// AuthConfig configures the authorization of the services calling the API
+type AuthConfig struct {
+	// Enabled requires the callers to authenticate with a JWT or an API key and checks their scopes
+	// per HTTP route and gRPC method, only the health checks and the metrics stay public
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED"`
+	// JWKSFile is the JSON Web Key Set the JWTs are verified with, empty disables the JWTs
+	JWKSFile string `yaml:"jwks_file" env:"AUTH_JWKS_FILE"`
+	// JWTIssuer and JWTAudience are checked against the iss and aud claims, when they are set
+	JWTIssuer   string `yaml:"jwt_issuer" env:"AUTH_JWT_ISSUER"`
+	JWTAudience string `yaml:"jwt_audience" env:"AUTH_JWT_AUDIENCE"`
+	// APIKeysFile lists the SHA-256 hashes of the API keys with their scopes, empty disables the API keys
+	APIKeysFile string `yaml:"api_keys_file" env:"AUTH_API_KEYS_FILE"`
+}
+
+// RateLimitConfig limits the rate of the requests per caller, the callers are told apart by the principal,
+// the client certificate or the IP address. The buckets are kept in Redis, or in memory in the dev mode
+// and while Redis is unavailable.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// ReadRate is the number of the requests per second reading the users, ReadBurst the number
+	// of the requests allowed at once
+	ReadRate  float64 `yaml:"read_rate" env:"RATE_LIMIT_READ_RATE"`
+	ReadBurst int     `yaml:"read_burst" env:"RATE_LIMIT_READ_BURST"`
+	// WriteRate and WriteBurst are the same for the rest of the requests
+	WriteRate  float64 `yaml:"write_rate" env:"RATE_LIMIT_WRITE_RATE"`
+	WriteBurst int     `yaml:"write_burst" env:"RATE_LIMIT_WRITE_BURST"`
+	// Routes are the budgets of the HTTP routes and the gRPC methods, which replace the read or write one,
+	// as comma separated route=rate:burst, e.g. "GET /users=5:10,/users.Users/GetUsers=5:10"
+	Routes string `yaml:"routes" env:"RATE_LIMIT_ROUTES"`
+	// RedisTimeout limits the time of taking a token from Redis, the in-memory buckets are used when it's exceeded
+	RedisTimeout time.Duration `yaml:"redis_timeout" env:"RATE_LIMIT_REDIS_TIMEOUT"`
+	// TrustedProxies are the proxies in front of the application (e.g. the ingress), as comma separated addresses
+	// or CIDR prefixes, the IP address of the client of their requests is taken from X-Forwarded-For
+	TrustedProxies string `yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES"`
+}
+
+// RouteLimit is the budget of a route, see RateLimitConfig.Routes
+type RouteLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RouteLimits parses Routes
+func (c RateLimitConfig) RouteLimits() (map[string]RouteLimit, error) {
+	limits := make(map[string]RouteLimit)
+	for _, entry := range strings.Split(c.Routes, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not route=rate:burst", entry)
+		}
+		route, limit := strings.TrimSpace(entry[:i]), entry[i+1:]
+		rate, burst, ok := strings.Cut(limit, ":")
+		if !ok {
+			return nil, fmt.Errorf("%q is not route=rate:burst", entry)
+		}
+
+		var routeLimit RouteLimit
+		var err error
+		if routeLimit.Rate, err = strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil || routeLimit.Rate <= 0 {
+			return nil, fmt.Errorf("rate of %s must be a positive number, got %q", route, rate)
+		}
+		if routeLimit.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || routeLimit.Burst < 1 {
+			return nil, fmt.Errorf("burst of %s must be a positive integer, got %q", route, burst)
+		}
+		limits[route] = routeLimit
+	}
+
+	return limits, nil
+}
+
+// TrustedProxyPrefixes parses TrustedProxies, the addresses are the prefixes of a single address
+func (c RateLimitConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(c.TrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an address nor a CIDR prefix", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// TenantConfig configures the partitioning of the users by the tenants, the requests name the tenant
+// with the X-Tenant-ID header or the x-tenant-id gRPC metadata
+type TenantConfig struct {
+	// Required rejects the requests which don't name the tenant, otherwise they are made for the default tenant
+	Required bool `yaml:"required" env:"TENANT_REQUIRED"`
+}
+
+// CacheConfig configures the cache of the users queries kept in Redis, it's not used in the dev mode
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled" env:"CACHE_ENABLED"`
+	// UserTTL is the time of keeping the lookups of a single user by the email, ListTTL of the rest of the queries
+	UserTTL time.Duration `yaml:"user_ttl" env:"CACHE_USER_TTL"`
+	ListTTL time.Duration `yaml:"list_ttl" env:"CACHE_LIST_TTL"`
+	// RedisTimeout limits the time of the cache calls, the queries go to the repository when it's exceeded
+	RedisTimeout time.Duration `yaml:"redis_timeout" env:"CACHE_REDIS_TIMEOUT"`
+}
+
+// ProjectionConfig configures the projection of the users built from their events, which serves the queries instead
+// of the users table, it's not used in the dev mode
+type ProjectionConfig struct {
+	Enabled bool `yaml:"enabled" env:"PROJECTION_ENABLED"`
+	// PollInterval is the interval of checking for the new events, the queries can lag behind the commands by it
+	PollInterval time.Duration `yaml:"poll_interval" env:"PROJECTION_POLL_INTERVAL"`
+	// BatchSize is the maximum number of the events applied in one transaction
+	BatchSize int `yaml:"batch_size" env:"PROJECTION_BATCH_SIZE"`
+	// EventsRetention is the time the events are kept for once they're applied, afterwards the ones superseded by
+	// the later events of their users, and the ones of the deleted users, are pruned every PruneInterval.
+	// 0 keeps all the events.
+	EventsRetention time.Duration `yaml:"events_retention" env:"PROJECTION_EVENTS_RETENTION"`
+	PruneInterval   time.Duration `yaml:"prune_interval" env:"PROJECTION_PRUNE_INTERVAL"`
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Database string `yaml:"database" env:"POSTGRES_DB"`
+	User     string `yaml:"user" env:"POSTGRES_USER"`
+	Password string `yaml:"password" env:"POSTGRES_PASSWORD" secret:"true"`
+
+	// ReadTimeout and WriteTimeout limit the duration of the repository queries and modifications, 0 disables them
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"DB_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"DB_WRITE_TIMEOUT"`
+	// StatementTimeout is the Postgres statement_timeout of the database sessions, 0 keeps the database default
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+}
+
+// DBReplicaConfig configures the read replicas of the database, the users queries are sent to them while they don't
+// lag behind the primary, the commands always go to the primary
+type DBReplicaConfig struct {
+	// Hosts are the comma separated hosts of the replicas, none disables them
+	Hosts string `yaml:"hosts" env:"DB_REPLICA_HOSTS"`
+	// Database, User and Password default to the ones of the primary
+	Database string `yaml:"database" env:"DB_REPLICA_DATABASE"`
+	User     string `yaml:"user" env:"DB_REPLICA_USER"`
+	Password string `yaml:"password" env:"DB_REPLICA_PASSWORD" secret:"true"`
+	// MaxLag is the lag behind the primary, above which the replica is not queried
+	MaxLag time.Duration `yaml:"max_lag" env:"DB_REPLICA_MAX_LAG"`
+	// CheckInterval is the interval of checking the lag of the replicas
+	CheckInterval time.Duration `yaml:"check_interval" env:"DB_REPLICA_CHECK_INTERVAL"`
+}
+
+// HostList returns the hosts of the replicas
+func (c DBReplicaConfig) HostList() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.Hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+type RedisConfig struct {
+	Host          string `yaml:"host" env:"REDIS_HOST"`
+	Port          int    `yaml:"port" env:"REDIS_PORT"`
+	Password      string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB            int    `yaml:"db" env:"REDIS_DB"`
+	EventsChannel string `yaml:"events_channel" env:"REDIS_EVENTS_CHANNEL"`
+	// EventsChannelPerTenant publishes the events of every tenant to its own channel, named events_channel:<tenant id>
+	EventsChannelPerTenant bool `yaml:"events_channel_per_tenant" env:"REDIS_EVENTS_CHANNEL_PER_TENANT"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+	JSON  bool   `yaml:"json" env:"LOG_JSON"`
+	// HashPII replaces the personal data (e.g. emails) in the logs with its hash
+	HashPII bool `yaml:"hash_pii" env:"LOG_HASH_PII"`
+}
+
+type EventsConfig struct {
+	// LogFilePath is the path of the events log
+	LogFilePath string `yaml:"log_file_path" env:"EVENTS_LOG_FILE_PATH"`
+	// StreamHistorySize is the number of events kept in memory for resuming the Server-Sent Events streams
+	StreamHistorySize int `yaml:"stream_history_size" env:"EVENTS_STREAM_HISTORY_SIZE"`
+	// StreamBufferSize is the number of events buffered for a single stream, before the client gets dropped
+	StreamBufferSize int           `yaml:"stream_buffer_size" env:"EVENTS_STREAM_BUFFER_SIZE"`
+	StreamHeartbeat  time.Duration `yaml:"stream_heartbeat" env:"EVENTS_STREAM_HEARTBEAT"`
+}
+
+type WebhooksConfig struct {
+	MaxAttempts            int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	InitialBackoff         time.Duration `yaml:"initial_backoff" env:"WEBHOOK_INITIAL_BACKOFF"`
+	MaxBackoff             time.Duration `yaml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF"`
+	MaxConsecutiveFailures int           `yaml:"max_consecutive_failures" env:"WEBHOOK_MAX_CONSECUTIVE_FAILURES"`
+	Timeout                time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
+}
+
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `yaml:"key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+	// ReservationTTL is the time after which the key of a request which never finished can be taken over by a retry
+	ReservationTTL time.Duration `yaml:"reservation_ttl" env:"IDEMPOTENCY_RESERVATION_TTL"`
+}
+
+type HealthConfig struct {
+	// CheckTimeout limits the time of checking a single dependency
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// CheckInterval is the interval of updating the gRPC serving status
+	CheckInterval time.Duration `yaml:"check_interval" env:"HEALTH_CHECK_INTERVAL"`
+}
+
+type MetricsConfig struct {
+	// Enabled exposes the Prometheus metrics on the HTTP server
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+	Path    string `yaml:"path" env:"METRICS_PATH"`
+}
+
+type TracingConfig struct {
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	// Exporter is one of none, stdout, otlp - spans are still created with none, e.g. for the trace ids in the logs
+	Exporter     string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+	// SampleRatio is the ratio of the recorded traces started by the application, between 0 and 1
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Default returns the configuration used when no other source sets a value
+func Default() Config {
+	return Config{
+		HTTP: HTTPConfig{Enabled: true, Port: 8080},
+		GRPC: GRPCConfig{Enabled: true, Port: 50051, DefaultTimeout: 10 * time.Second},
+		TLS:  TLSConfig{ClientAuth: "none", ReloadInterval: time.Minute},
+		RateLimit: RateLimitConfig{
+			ReadRate:     20,
+			ReadBurst:    40,
+			WriteRate:    5,
+			WriteBurst:   10,
+			RedisTimeout: 100 * time.Millisecond,
+		},
+		DBReplica: DBReplicaConfig{
+			MaxLag:        5 * time.Second,
+			CheckInterval: time.Second,
+		},
+		Cache: CacheConfig{
+			UserTTL:      5 * time.Minute,
+			ListTTL:      30 * time.Second,
+			RedisTimeout: 100 * time.Millisecond,
+		},
+		Projection: ProjectionConfig{
+			PollInterval:    200 * time.Millisecond,
+			BatchSize:       500,
+			EventsRetention: 7 * 24 * time.Hour,
+			PruneInterval:   time.Hour,
+		},
+		DB: DBConfig{
+			Host:     "db",
+			Database: "users",
+			User:     "postgres",
+
+			ReadTimeout:      5 * time.Second,
+			WriteTimeout:     5 * time.Second,
+			StatementTimeout: 10 * time.Second,
+		},
+		Redis: RedisConfig{
+			Host:          "redis",
+			Port:          6379,
+			EventsChannel: "events",
+		},
+		Log: LogConfig{Level: "info", JSON: true},
+		Events: EventsConfig{
+			LogFilePath:       "../logs/events.log",
+			StreamHistorySize: 1000,
+			StreamBufferSize:  100,
+			StreamHeartbeat:   15 * time.Second,
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:            5,
+			InitialBackoff:         500 * time.Millisecond,
+			MaxBackoff:             30 * time.Second,
+			MaxConsecutiveFailures: 10,
+			Timeout:                5 * time.Second,
+		},
+		Idempotency: IdempotencyConfig{KeyTTL: 24 * time.Hour, ReservationTTL: time.Minute},
+		Health:      HealthConfig{CheckTimeout: 2 * time.Second, CheckInterval: 5 * time.Second},
+		Metrics:     MetricsConfig{Enabled: true, Path: "/metrics"},
+		Tracing: TracingConfig{
+			ServiceName:  "users-app",
+			Exporter:     "none",
+			OTLPEndpoint: "localhost:4317",
+			SampleRatio:  1,
+		},
+		ShutdownTimeout: 30 * time.Second,
+		DevFixtures:     "../fixtures/post_users",
+	}
+}
+
+var logLevels = map[string]bool{"trace": true, "debug": true, "info": true, "warn": true, "error": true}
+
+var clientAuths = map[string]bool{"none": true, "optional": true, "require": true}
+
+var tracingExporters = map[string]bool{"none": true, "stdout": true, "otlp": true}
+
+// Validate checks the whole configuration and returns all the problems at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.HTTP.Enabled || c.GRPC.Enabled, "at least one of http.enabled and grpc.enabled must be true")
+	check(validPort(c.HTTP.Port), "http.port must be between 1 and 65535, got %d", c.HTTP.Port)
+	check(validPort(c.GRPC.Port), "grpc.port must be between 1 and 65535, got %d", c.GRPC.Port)
+	check(c.GRPC.DefaultTimeout >= 0, "grpc.default_timeout must not be negative, got %s", c.GRPC.DefaultTimeout)
+	check(!c.HTTP.Enabled || !c.GRPC.Enabled || c.GRPC.Multiplex || c.HTTP.Port != c.GRPC.Port, "http.port and grpc.port must be different")
+	check(!c.GRPC.Multiplex || c.HTTP.Enabled && c.GRPC.Enabled, "grpc.multiplex requires http.enabled and grpc.enabled")
+	check(!c.GRPC.Web || c.HTTP.Enabled && c.GRPC.Enabled, "grpc.web requires http.enabled and grpc.enabled")
+
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	check(clientAuths[c.TLS.ClientAuth], "tls.client_auth must be one of none, optional, require, got %q", c.TLS.ClientAuth)
+	check(c.TLS.ClientAuth == "none" || c.TLS.Enabled(), "tls.client_auth %s requires tls.cert_file", c.TLS.ClientAuth)
+	check(c.TLS.ClientAuth == "none" || c.TLS.ClientCAFile != "", "tls.client_ca_file is required for tls.client_auth %s", c.TLS.ClientAuth)
+	check(c.TLS.ReloadInterval > 0, "tls.reload_interval must be positive, got %s", c.TLS.ReloadInterval)
+	check(!c.Auth.Enabled || c.Auth.JWKSFile != "" || c.Auth.APIKeysFile != "", "auth.jwks_file or auth.api_keys_file is required when auth is enabled")
+	check(c.RateLimit.ReadRate > 0, "rate_limit.read_rate must be positive, got %v", c.RateLimit.ReadRate)
+	check(c.RateLimit.ReadBurst > 0, "rate_limit.read_burst must be positive, got %d", c.RateLimit.ReadBurst)
+	check(c.RateLimit.WriteRate > 0, "rate_limit.write_rate must be positive, got %v", c.RateLimit.WriteRate)
+	check(c.RateLimit.WriteBurst > 0, "rate_limit.write_burst must be positive, got %d", c.RateLimit.WriteBurst)
+	check(c.RateLimit.RedisTimeout > 0, "rate_limit.redis_timeout must be positive, got %s", c.RateLimit.RedisTimeout)
+	if _, err := c.RateLimit.RouteLimits(); err != nil {
+		check(false, "invalid rate_limit.routes: %v", err)
+	}
+	if _, err := c.RateLimit.TrustedProxyPrefixes(); err != nil {
+		check(false, "invalid rate_limit.trusted_proxies: %v", err)
+	}
+
+	check(c.DB.Host != "", "db.host is required")
+	check(c.DB.Database != "", "db.database is required")
+	check(c.DB.User != "", "db.user is required")
+	check(c.DB.ReadTimeout >= 0, "db.read_timeout must not be negative, got %s", c.DB.ReadTimeout)
+	check(c.DB.WriteTimeout >= 0, "db.write_timeout must not be negative, got %s", c.DB.WriteTimeout)
+	check(c.DB.StatementTimeout >= 0, "db.statement_timeout must not be negative, got %s", c.DB.StatementTimeout)
+	check(c.DBReplica.MaxLag > 0, "db_replica.max_lag must be positive, got %s", c.DBReplica.MaxLag)
+	check(c.DBReplica.CheckInterval > 0, "db_replica.check_interval must be positive, got %s", c.DBReplica.CheckInterval)
+
+	check(c.Redis.Host != "", "redis.host is required")
+	check(validPort(c.Redis.Port), "redis.port must be between 1 and 65535, got %d", c.Redis.Port)
+	check(c.Redis.DB >= 0, "redis.db must not be negative, got %d", c.Redis.DB)
+	check(c.Redis.EventsChannel != "", "redis.events_channel is required")
+
+	check(c.Cache.UserTTL > 0, "cache.user_ttl must be positive, got %s", c.Cache.UserTTL)
+	check(c.Cache.ListTTL > 0, "cache.list_ttl must be positive, got %s", c.Cache.ListTTL)
+	check(c.Cache.RedisTimeout > 0, "cache.redis_timeout must be positive, got %s", c.Cache.RedisTimeout)
+
+	check(c.Projection.PollInterval > 0, "projection.poll_interval must be positive, got %s", c.Projection.PollInterval)
+	check(c.Projection.BatchSize > 0, "projection.batch_size must be positive, got %d", c.Projection.BatchSize)
+	check(c.Projection.EventsRetention >= 0, "projection.events_retention must not be negative, got %s",
+		c.Projection.EventsRetention)
+	check(c.Projection.PruneInterval > 0, "projection.prune_interval must be positive, got %s", c.Projection.PruneInterval)
+
+	check(logLevels[c.Log.Level], "log.level must be one of trace, debug, info, warn, error, got %q", c.Log.Level)
+
+	check(c.Events.LogFilePath != "", "events.log_file_path is required")
+	check(c.Events.StreamHistorySize > 0, "events.stream_history_size must be positive, got %d", c.Events.StreamHistorySize)
+	check(c.Events.StreamBufferSize > 0, "events.stream_buffer_size must be positive, got %d", c.Events.StreamBufferSize)
+	check(c.Events.StreamHeartbeat > 0, "events.stream_heartbeat must be positive, got %s", c.Events.StreamHeartbeat)
+
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive, got %d", c.Webhooks.MaxAttempts)
+	check(c.Webhooks.InitialBackoff >= 0, "webhooks.initial_backoff must not be negative, got %s", c.Webhooks.InitialBackoff)
+	check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks.max_backoff must not be lower than webhooks.initial_backoff")
+	check(c.Webhooks.MaxConsecutiveFailures >= 0, "webhooks.max_consecutive_failures must not be negative, got %d", c.Webhooks.MaxConsecutiveFailures)
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive, got %s", c.Webhooks.Timeout)
+
+	check(c.Idempotency.KeyTTL > 0, "idempotency.key_ttl must be positive, got %s", c.Idempotency.KeyTTL)
+	check(c.Idempotency.ReservationTTL > 0, "idempotency.reservation_ttl must be positive, got %s", c.Idempotency.ReservationTTL)
+	check(c.Idempotency.ReservationTTL > c.DB.WriteTimeout, "idempotency.reservation_ttl must be longer than db.write_timeout")
+	check(c.Health.CheckTimeout > 0, "health.check_timeout must be positive, got %s", c.Health.CheckTimeout)
+	check(c.Health.CheckInterval > 0, "health.check_interval must be positive, got %s", c.Health.CheckInterval)
+	check(!c.Metrics.Enabled || strings.HasPrefix(c.Metrics.Path, "/"), "metrics.path must start with /, got %q", c.Metrics.Path)
+	check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+	check(tracingExporters[c.Tracing.Exporter], "tracing.exporter must be one of none, stdout, otlp, got %q", c.Tracing.Exporter)
+	check(c.Tracing.Exporter != "otlp" || c.Tracing.OTLPEndpoint != "", "tracing.otlp_endpoint is required for otlp exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive, got %s", c.ShutdownTimeout)
+
+	return errors.Join(errs...)
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) LookupEnv {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_defaults(t *testing.T) {
+	cfg, args, err := Load(nil, env(nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Empty(t, args)
+}
+
+func TestLoad_precedence(t *testing.T) {
+	configFile := writeFile(t, "config.yml", `
+http:
+  port: 8081
+grpc:
+  port: 50052
+db:
+  host: file-host
+redis:
+  port: 6380
+`)
+
+	cfg, _, err := Load(
+		[]string{"--config", configFile, "--http.port=8083", "--log.json=false"},
+		env(map[string]string{"PORT_HTTP": "8082", "DB_HOST": "env-host", "LOG_JSON": "true"}),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, 8083, cfg.HTTP.Port, "flag overrides env and file")
+	assert.Equal(t, false, cfg.Log.JSON, "flag overrides env")
+	assert.Equal(t, "env-host", cfg.DB.Host, "env overrides file")
+	assert.Equal(t, 50052, cfg.GRPC.Port, "file overrides default")
+	assert.Equal(t, 6380, cfg.Redis.Port, "file overrides default")
+	assert.Equal(t, Default().Redis.Host, cfg.Redis.Host, "default is kept when no source sets the value")
+}
+
+func TestLoad_env_values(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		assert func(t *testing.T, cfg Config)
+	}{
+		{
+			name:   "bool_accepts_1",
+			env:    map[string]string{"RUN_GRPC": "1"},
+			assert: func(t *testing.T, cfg Config) { assert.True(t, cfg.GRPC.Enabled) },
+		},
+		{
+			name:   "bool_accepts_false",
+			env:    map[string]string{"RUN_GRPC": "false"},
+			assert: func(t *testing.T, cfg Config) { assert.False(t, cfg.GRPC.Enabled) },
+		},
+		{
+			name:   "int_is_trimmed",
+			env:    map[string]string{"REDIS_DB": " 2 "},
+			assert: func(t *testing.T, cfg Config) { assert.Equal(t, 2, cfg.Redis.DB) },
+		},
+		{
+			name:   "duration",
+			env:    map[string]string{"SHUTDOWN_TIMEOUT": "1m"},
+			assert: func(t *testing.T, cfg Config) { assert.Equal(t, time.Minute, cfg.ShutdownTimeout) },
+		},
+		{
+			name:   "secret_from_file",
+			env:    map[string]string{"POSTGRES_PASSWORD_FILE": writeFile(t, "password", "top-secret\n")},
+			assert: func(t *testing.T, cfg Config) { assert.Equal(t, "top-secret", cfg.DB.Password) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load(nil, env(tt.env))
+			require.NoError(t, err)
+			tt.assert(t, cfg)
+		})
+	}
+}
+
+func TestLoad_reports_all_errors(t *testing.T) {
+	_, _, err := Load(nil, env(map[string]string{
+		"PORT_HTTP":               "http",
+		"RUN_GRPC":                "maybe",
+		"REDIS_PASSWORD":          "secret",
+		"REDIS_PASSWORD_FILE":     "/run/secrets/redis",
+		"EVENTS_STREAM_HEARTBEAT": "15",
+	}))
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "invalid PORT_HTTP")
+	assert.ErrorContains(t, err, "invalid RUN_GRPC")
+	assert.ErrorContains(t, err, "only one of REDIS_PASSWORD and REDIS_PASSWORD_FILE can be set")
+	assert.ErrorContains(t, err, "invalid EVENTS_STREAM_HEARTBEAT")
+}
+
+func TestLoad_reports_parsing_and_validation_errors(t *testing.T) {
+	configFile := writeFile(t, "config.yml", "http:\n  prot: 8080\n")
+
+	_, _, err := Load([]string{"--config", configFile}, env(map[string]string{
+		"PORT_HTTP":  "abc",
+		"LOG_LEVEL":  "x",
+		"REDIS_PORT": "0",
+	}))
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "prot")
+	assert.ErrorContains(t, err, "invalid PORT_HTTP")
+	assert.ErrorContains(t, err, "log.level must be one of")
+	assert.ErrorContains(t, err, "redis.port must be between")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.Port = 0
+	cfg.GRPC.Port = 70000
+	cfg.Log.Level = "verbose"
+	cfg.Webhooks.MaxBackoff = time.Millisecond
+	cfg.TLS.ClientAuth = "require"
+	cfg.Auth.Enabled = true
+	cfg.RateLimit.Routes = "GET /users=fast"
+	cfg.Projection.EventsRetention = -time.Hour
+
+	err := cfg.Validate()
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "http.port")
+	assert.ErrorContains(t, err, "grpc.port")
+	assert.ErrorContains(t, err, "log.level")
+	assert.ErrorContains(t, err, "webhooks.max_backoff")
+	assert.ErrorContains(t, err, "tls.client_auth require requires tls.cert_file")
+	assert.ErrorContains(t, err, "tls.client_ca_file is required")
+	assert.ErrorContains(t, err, "auth.jwks_file or auth.api_keys_file is required")
+	assert.ErrorContains(t, err, "invalid rate_limit.routes")
+	assert.ErrorContains(t, err, "projection.events_retention")
+}
+
+func TestRateLimitConfig_RouteLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  string
+		want    map[string]RouteLimit
+		wantErr string
+	}{
+		{
+			name:   "empty",
+			routes: "",
+			want:   map[string]RouteLimit{},
+		},
+		{
+			name:   "http_and_grpc",
+			routes: "GET /users=0.5:10, /users.Users/GetUsers=5:10,",
+			want: map[string]RouteLimit{
+				"GET /users":            {Rate: 0.5, Burst: 10},
+				"/users.Users/GetUsers": {Rate: 5, Burst: 10},
+			},
+		},
+		{
+			name:    "missing_burst",
+			routes:  "GET /users=5",
+			wantErr: "is not route=rate:burst",
+		},
+		{
+			name:    "zero_rate",
+			routes:  "GET /users=0:10",
+			wantErr: "rate of GET /users must be a positive number",
+		},
+		{
+			name:    "fractional_burst",
+			routes:  "GET /users=5:1.5",
+			wantErr: "burst of GET /users must be a positive integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RateLimitConfig{Routes: tt.routes}.RouteLimits()
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRateLimitConfig_TrustedProxyPrefixes(t *testing.T) {
+	got, err := RateLimitConfig{TrustedProxies: " 10.0.0.0/8, 192.168.1.7,,fd00::/8"}.TrustedProxyPrefixes()
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.7/32"), netip.MustParsePrefix("fd00::/8"),
+	}, got)
+
+	_, err = RateLimitConfig{TrustedProxies: "ingress"}.TrustedProxyPrefixes()
+	assert.ErrorContains(t, err, "neither an address nor a CIDR prefix")
+}
+
+func TestDBReplicaConfig_HostList(t *testing.T) {
+	assert.Empty(t, DBReplicaConfig{}.HostList())
+	assert.Equal(t, []string{"replica-1", "replica-2"}, DBReplicaConfig{Hosts: " replica-1,,replica-2 "}.HostList())
+}
+
+func TestLoad_unknown_field_in_file(t *testing.T) {
+	configFile := writeFile(t, "config.yml", "http:\n  prot: 8080\n")
+
+	_, _, err := Load([]string{"--config", configFile}, env(nil))
+
+	assert.ErrorContains(t, err, "prot")
+}
+
+func TestLoad_returns_remaining_args(t *testing.T) {
+	_, args, err := Load([]string{"--http.port=9090", "config", "print"}, env(nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config", "print"}, args)
+}
+
+func TestPrint_masks_secrets(t *testing.T) {
+	cfg := Default()
+	cfg.DB.Password = "db-secret"
+	cfg.Redis.Password = ""
+
+	out := bytes.Buffer{}
+	require.NoError(t, Print(&out, cfg))
+
+	assert.NotContains(t, out.String(), "db-secret")
+	assert.Contains(t, out.String(), "password: '******'")
+	assert.Contains(t, out.String(), "shutdown_timeout: 30s")
+	assert.Equal(t, "db-secret", cfg.DB.Password, "printing must not modify the config")
+}
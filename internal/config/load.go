@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LookupEnv is the signature of os.LookupEnv, it's passed to Load so that the environment can be replaced in tests
+type LookupEnv func(key string) (string, bool)
+
+// Load reads the configuration from all the sources and validates it.
+// It returns the arguments left after parsing the flags (e.g. a subcommand).
+// All the problems found (invalid values, failed validation) are reported at once.
+func Load(args []string, lookupEnv LookupEnv) (Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("users-app", flag.ContinueOnError)
+	configFile := flags.String("config", "", "path of the YAML configuration file, can be also set with CONFIG_FILE")
+	flagValues := make(map[string]*rawFlag)
+	for _, f := range fieldsOf(&cfg) {
+		flagValues[f.path] = &rawFlag{isBool: f.value.Kind() == reflect.Bool}
+		flags.Var(flagValues[f.path], f.path, fmt.Sprintf("overrides %s (default %s)", f.env, f.format()))
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	var errs []error
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, f := range fieldsOf(&cfg) {
+		raw, ok, err := lookupEnvOrFile(f.env, lookupEnv)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			if err := f.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", f.env, err))
+			}
+		}
+	}
+
+	for _, f := range fieldsOf(&cfg) {
+		if value := flagValues[f.path]; value.isSet {
+			if err := f.set(value.value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid --%s: %w", f.path, err))
+			}
+		}
+	}
+
+	// the values which failed to parse keep their previous ones, the rest is validated anyway
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return Config{}, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// Print writes the effective configuration as YAML, with the secrets masked
+func Print(w io.Writer, cfg Config) error {
+	for _, f := range fieldsOf(&cfg) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString("******")
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+
+	return encoder.Encode(cfg)
+}
+
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// lookupEnvOrFile returns the value of the environment variable, or the content of the file
+// pointed by the variable with _FILE suffix. Setting both of them is an error.
+func lookupEnvOrFile(key string, lookupEnv LookupEnv) (string, bool, error) {
+	value, ok := lookupEnv(key)
+	filePath, fileOk := lookupEnv(key + "_FILE")
+
+	if !fileOk {
+		return value, ok, nil
+	}
+	if ok {
+		return "", false, fmt.Errorf("only one of %s and %s_FILE can be set", key, key)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// field is a single configuration value, found by walking the Config struct
+type field struct {
+	// path is the YAML path of the field, e.g. http.port
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+func fieldsOf(cfg *Config) []field {
+	return walk(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func walk(v reflect.Value, prefix string) []field {
+	var ret []field
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		path := prefix + strings.Split(structField.Tag.Get("yaml"), ",")[0]
+
+		if structField.Type.Kind() == reflect.Struct {
+			ret = append(ret, walk(v.Field(i), path+".")...)
+			continue
+		}
+
+		ret = append(ret, field{
+			path:   path,
+			env:    structField.Tag.Get("env"),
+			secret: structField.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+
+	return ret
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (f field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(i))
+	case f.value.Kind() == reflect.Float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+func (f field) format() string {
+	if f.secret {
+		return "not shown"
+	}
+	if f.value.Type() == durationType {
+		return time.Duration(f.value.Int()).String()
+	}
+
+	return fmt.Sprint(f.value.Interface())
+}
+
+// rawFlag keeps the flag value as it was passed, so that it can be applied after the other sources
+type rawFlag struct {
+	value  string
+	isSet  bool
+	isBool bool
+}
+
+func (r *rawFlag) String() string { return r.value }
+
+func (r *rawFlag) Set(value string) error {
+	r.value = value
+	r.isSet = true
+	return nil
+}
+
+func (r *rawFlag) IsBoolFlag() bool { return r.isBool }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"users-app/adapters"
+	"users-app/gen/api"
+	"users-app/ratelimit"
+	"users-app/service"
+)
+
+// newDevDependencies keeps everything in memory, so that the application can run without Postgres and Redis.
+// The events are published to an in-process bus and printed to the standard output.
+func newDevDependencies() dependencies {
+	return dependencies{
+		users:         adapters.NewMemoryRepository(),
+		webhooks:      adapters.NewMemoryWebhookRepository(),
+		idempotency:   adapters.NewMemoryIdempotencyRepository(),
+		publisher:     adapters.NewMemoryPublisher(),
+		publisherName: "bus",
+		eventsLog:     adapters.NewConsoleEventLogger(),
+		rateLimits:    ratelimit.NewMemoryLimiter(),
+	}
+}
+
+// seedUsers adds the users from the fixtures file, one POST /users body per line - empty lines are skipped.
+// It returns the number of the added users.
+func seedUsers(ctx context.Context, commands service.UsersCommandService, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var user api.PostUser
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		_, err := commands.AddUser(ctx, service.AddUserCommand{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Nickname:  user.Nickname,
+			Password:  user.Password,
+			Email:     string(user.Email),
+			Country:   user.Country,
+		})
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		count++
+	}
+
+	return count, scanner.Err()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"users-app/adapters"
+	"users-app/domain"
+	"users-app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedUsers(t *testing.T) {
+	repo := adapters.NewMemoryRepository()
+
+	count, err := seedUsers(context.Background(), service.NewUserCommandService(repo), "../fixtures/post_users")
+	require.NoError(t, err)
+
+	users, err := repo.Users(context.Background(), domain.Filter{}, domain.Pagination{})
+	require.NoError(t, err)
+	assert.Equal(t, 11, count)
+	assert.Len(t, users, count)
+	assert.Equal(t, "krzysztof@skolimowski.com", users[0].Email)
+	assert.NotEqual(t, "top-secret", users[0].PasswordHash, "users are added with the command, so the passwords get hashed")
+}
+
+func TestSeedUsers_invalid_line(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"first_name": "John", "last_name": "Doe", "nickname": "jd", "email": "john@doe.com", "country": "UK", "password": "secret"}`+
+			"\n\n{\n",
+	), 0o644))
+
+	count, err := seedUsers(context.Background(), service.NewUserCommandService(adapters.NewMemoryRepository()), path)
+	assert.Equal(t, 1, count)
+	assert.ErrorContains(t, err, "line 3")
+}
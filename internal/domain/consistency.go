@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+	"sync"
+)
+
+// ConsistencyToken identifies the position of a write in the database log. It's returned to the clients after
+// the commands, the queries they make with it see the write (read-your-writes) - they are not served by the read
+// replicas which haven't replayed it yet.
+type ConsistencyToken string
+
+type consistencyTokenKey struct{}
+
+// WithConsistencyToken returns a copy of the context, whose queries need to see the write of the token
+func WithConsistencyToken(ctx context.Context, token ConsistencyToken) context.Context {
+	return context.WithValue(ctx, consistencyTokenKey{}, token)
+}
+
+// ConsistencyTokenFromContext returns the token of the queries, empty when any replica can serve them
+func ConsistencyTokenFromContext(ctx context.Context) ConsistencyToken {
+	token, _ := ctx.Value(consistencyTokenKey{}).(ConsistencyToken)
+	return token
+}
+
+type primaryReadsKey struct{}
+
+// WithPrimaryReads returns a copy of the context, whose queries go to the primary database,
+// e.g. the checks made by the commands before they write
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// PrimaryReads tells if the queries of the context need to go to the primary database
+func PrimaryReads(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return primary
+}
+
+// WriteTracker collects the consistency token of the writes made with the context, see TrackWrites
+type WriteTracker struct {
+	mu    sync.Mutex
+	token ConsistencyToken
+}
+
+// Record keeps the token of the last write, it's called by the repositories
+func (t *WriteTracker) Record(token ConsistencyToken) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+}
+
+// Token returns the token of the last write, empty when there was none
+func (t *WriteTracker) Token() ConsistencyToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.token
+}
+
+type writeTrackerKey struct{}
+
+// TrackWrites returns a copy of the context, whose writes are recorded by the returned tracker
+func TrackWrites(ctx context.Context) (context.Context, *WriteTracker) {
+	tracker := &WriteTracker{}
+	return context.WithValue(ctx, writeTrackerKey{}, tracker), tracker
+}
+
+// WriteTrackerFromContext returns the tracker of the writes, ok is false when they are not tracked
+func WriteTrackerFromContext(ctx context.Context) (*WriteTracker, bool) {
+	tracker, ok := ctx.Value(writeTrackerKey{}).(*WriteTracker)
+	return tracker, ok
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"users-app/redact"
+)
 
 // List of possible and currently supported events
 var (
@@ -16,6 +19,26 @@ type EventMsg string
 type Event struct {
 	Command
 	Msg EventMsg
+	// TenantID is the tenant of the user changed by the command
+	TenantID TenantID
+	// UserID is the id of the user changed by the command, the commands adding the users don't carry it
+	UserID UserID
+	// User is the user after the change, nil for the deletions. It's carried only by the events of the projections,
+	// which have no command.
+	User *User
+}
+
+// Payload returns the data of the event which can be published outside of the application.
+// It's built from the command with all the secrets (e.g. passwords) left out,
+// so the publishers should always use it instead of serializing the command on their own.
+// It carries the id of the changed user, e.g. of the added one.
+func (e Event) Payload() map[string]interface{} {
+	payload := redact.Payload(e.Command)
+	if e.UserID != (UserID{}) {
+		payload["id"] = e.UserID.String()
+	}
+
+	return payload
 }
 
 type Publisher interface {
@@ -26,3 +49,41 @@ type Publisher interface {
 type Command interface {
 	EncodeEvent() (Event, error)
 }
+
+// SequencedEvent is an Event numbered by the stream it was published to.
+// IDs are increasing within the Epoch of the stream, so a consumer can resume the stream from the last ID it has
+// seen. The epoch changes when the stream starts again (e.g. the process restarts), the IDs start again with it.
+type SequencedEvent struct {
+	Epoch string
+	ID    uint64
+	Event
+}
+
+// EventPosition is the position of a consumer in a stream, the last event it has seen
+type EventPosition struct {
+	Epoch string
+	ID    uint64
+}
+
+// EventSubscriber lets in-process consumers (e.g. Server-Sent Events connections) follow published events.
+type EventSubscriber interface {
+	// Subscribe registers a new subscription. Events following the position that are still retained by
+	// the stream are returned in the subscription backlog. The nil position means that the consumer
+	// is interested only in new events.
+	Subscribe(after *EventPosition) Subscription
+}
+
+// Subscription is a single consumer of an EventSubscriber.
+type Subscription interface {
+	// Backlog returns the retained events published after the requested position
+	Backlog() []SequencedEvent
+	// Reset returns the position of the last published event when the events following the requested position
+	// are not all retained - the position is of another epoch or older than the history - and nil otherwise.
+	// The backlog is empty then, the consumer should reload its state and follow the stream from the position.
+	Reset() *EventPosition
+	// Events returns a channel with live events. The channel gets closed when the subscription is closed,
+	// or when the consumer was too slow to keep up with the publisher and got dropped.
+	Events() <-chan SequencedEvent
+	// Close unregisters the subscription, it is safe to call it multiple times
+	Close()
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with the same idempotency key is still in progress")
+	ErrInvalidIdempotencyKey    = errors.New("invalid idempotency key")
+)
+
+// idempotencyKeyPattern allows the printable ASCII characters, e.g. of the UUIDs, up to the length of the stored keys
+var idempotencyKeyPattern = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+// ValidateIdempotencyKey checks that the key consists of 1 to 255 printable ASCII characters, without spaces
+func ValidateIdempotencyKey(key string) error {
+	if !idempotencyKeyPattern.MatchString(key) {
+		return ErrInvalidIdempotencyKey
+	}
+
+	return nil
+}
+
+// IdempotencyRecord remembers a request made with an idempotency key, so that the request can be safely retried
+// by the client - a repeated request returns the original result instead of being executed again.
+type IdempotencyRecord struct {
+	// Operation scopes the keys, so the same key can be used for different operations
+	Operation string
+	Key       string
+	// Fingerprint identifies the request payload, the same key used with a different payload is an error
+	Fingerprint string
+	// Completed is false while the original request is still being processed
+	Completed bool
+	// User is the result of the original request
+	User User
+	// CreatedAt identifies the reservation of the record, it's kept with the precision of microseconds
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// ReservedUntil ends the reservation of the not completed record. The request which made it is presumed dead
+	// afterwards (e.g. the process crashed), so the record can be reserved again by a retry.
+	ReservedUntil time.Time
+}
+
+// Reservable returns true if the record doesn't keep its key from being reserved at the time: it's expired,
+// or it's not completed and its reservation has ended
+func (r IdempotencyRecord) Reservable(at time.Time) bool {
+	return r.ExpiresAt.Before(at) || (!r.Completed && r.ReservedUntil.Before(at))
+}
+
+// Matches returns true if the record was created for a request with the same payload
+func (r IdempotencyRecord) Matches(fingerprint string) bool {
+	return r.Fingerprint == fingerprint
+}
+
+type IdempotencyRepository interface {
+	// Reserve stores a new, not completed record. In case a record with the same operation and key, which is not
+	// Reservable at the CreatedAt of the new one, already exists, it's returned and reserved is false.
+	Reserve(ctx context.Context, record IdempotencyRecord) (existing IdempotencyRecord, reserved bool, err error)
+	// Complete stores the result of the request in the reserved record, unless it was reserved again in the meantime
+	Complete(ctx context.Context, record IdempotencyRecord, user User) error
+	// Release removes the reserved record, so that the request can be retried - used when the request has failed.
+	// The record reserved again in the meantime is left.
+	Release(ctx context.Context, record IdempotencyRecord) error
+}
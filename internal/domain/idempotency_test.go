@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{name: "uuid", key: "6f1c2a9e-0c4b-4d8e-9a57-3f0e2b1d7c11"},
+		{name: "max_length", key: strings.Repeat("k", 255)},
+		{name: "too_long", key: strings.Repeat("k", 256), wantErr: ErrInvalidIdempotencyKey},
+		{name: "empty", key: "", wantErr: ErrInvalidIdempotencyKey},
+		{name: "space", key: "my key", wantErr: ErrInvalidIdempotencyKey},
+		{name: "non_ascii", key: "klucz-ż", wantErr: ErrInvalidIdempotencyKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, ValidateIdempotencyKey(tt.key))
+		})
+	}
+}
+
+func TestIdempotencyRecord_Reservable(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		record IdempotencyRecord
+		want   bool
+	}{
+		{name: "reserved", record: IdempotencyRecord{ExpiresAt: now.Add(time.Hour), ReservedUntil: now.Add(time.Minute)}},
+		{name: "reservation_ended", record: IdempotencyRecord{ExpiresAt: now.Add(time.Hour), ReservedUntil: now.Add(-time.Second)}, want: true},
+		{name: "completed", record: IdempotencyRecord{Completed: true, ExpiresAt: now.Add(time.Hour), ReservedUntil: now.Add(-time.Second)}},
+		{name: "expired", record: IdempotencyRecord{Completed: true, ExpiresAt: now.Add(-time.Second)}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.record.Reservable(now))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+var ErrInvalidTenant = errors.New("invalid tenant id")
+
+// TenantID identifies the tenant (e.g. a game title), the users of every tenant are kept apart:
+// the repositories see only the users of the tenant the request was made for (see WithTenant),
+// so the same email can be used in every one of them
+type TenantID string
+
+// DefaultTenant is the tenant of the requests which don't name one, and of the users created before the tenants
+// were introduced
+const DefaultTenant TenantID = "default"
+
+// tenantIDPattern keeps the ids safe to be used in the names, e.g. of the Redis channels
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ParseTenantID checks that the id consists of lowercase letters, digits, '-' and '_', up to 63 characters
+func ParseTenantID(id string) (TenantID, error) {
+	if !tenantIDPattern.MatchString(id) {
+		return "", ErrInvalidTenant
+	}
+
+	return TenantID(id), nil
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context carrying the tenant of the request
+func WithTenant(ctx context.Context, tenant TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant of the request, DefaultTenant when the context carries none
+func TenantFromContext(ctx context.Context) TenantID {
+	if tenant, ok := ctx.Value(tenantKey{}).(TenantID); ok {
+		return tenant
+	}
+
+	return DefaultTenant
+}
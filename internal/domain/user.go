@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -31,11 +32,12 @@ func ParseID(id string) (UserID, error) {
 // since it's a security risk
 type User struct {
 	ID           UserID
+	TenantID     TenantID
 	FirstName    string
 	LastName     string
 	Nickname     string
-	PasswordHash string
-	Email        string
+	PasswordHash string `redact:"secret"`
+	Email        string `redact:"pii"`
 	Country      string
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
@@ -58,15 +60,20 @@ func (f Filter) LastName() *string  { return f.lastName }
 func (f Filter) Nickname() *string  { return f.nickname }
 func (f Filter) Email() *string     { return f.email }
 func (f Filter) Country() *string   { return f.country }
+func (f Filter) Search() *string    { return f.search }
 
 // Filter represents a filter that can be used to search users
 // In case a field is nil, it will not be used in the search
+// The email is matched case-insensitively, the rest of the fields exactly
 type Filter struct {
 	firstName *string
 	lastName  *string
 	nickname  *string
 	email     *string
 	country   *string
+	// search is the full-text search of the words of the names and the email, in the web search syntax
+	// ("quoted phrases", or, -excluded)
+	search *string
 }
 
 func NewFilterEmail(email string) Filter {
@@ -93,6 +100,12 @@ func NewFilter(firstName, lastName, nickname, email, country string) Filter {
 	return filter
 }
 
+// WithSearch returns the filter with the full-text search, the empty search is not used
+func (f Filter) WithSearch(search string) Filter {
+	f.search = valueIfNotEmpty(search)
+	return f
+}
+
 var MaxPaginationLimit = 100
 var DefaultPaginationLimit = 10
 var DefaultPagination = Pagination{DefaultPaginationLimit, 0}
@@ -129,7 +142,7 @@ func min(a, b int) int {
 }
 
 func NewUser(
-	firstName string, lastName string, nickname string,
+	tenantID TenantID, firstName string, lastName string, nickname string,
 	password string, email string, country string,
 ) (User, error) {
 	if email == "" {
@@ -138,6 +151,7 @@ func NewUser(
 
 	return User{
 		ID:           NewUserID(),
+		TenantID:     tenantID,
 		FirstName:    firstName,
 		LastName:     lastName,
 		Nickname:     nickname,
@@ -157,9 +171,29 @@ func hash(password string) string {
 	return hex.EncodeToString(hashed[:])
 }
 
+// Repository stores the users.
+// The operations are bound to the context, they are aborted when it's canceled or its deadline is exceeded.
+// They are scoped by the tenant of the context (see TenantFromContext): Users, ModifyUser and RemoveUser see only
+// the users of the tenant, AddUser adds the user to its TenantID.
+// ModifyUser returns the user after the modification.
 type Repository interface {
-	AddUser(User) error
-	ModifyUser(UserID, Fields) error
-	RemoveUser(UserID) error
-	Users(Filter, Pagination) ([]User, error)
+	AddUser(context.Context, User) error
+	ModifyUser(context.Context, UserID, Fields) (User, error)
+	RemoveUser(context.Context, UserID) error
+	Users(context.Context, Filter, Pagination) ([]User, error)
+	// CountryCounts returns the number of the users of every country, the countries without users are left out
+	CountryCounts(context.Context) ([]CountryCount, error)
+}
+
+// CountryCount is the number of the users of a country
+type CountryCount struct {
+	Country string
+	Users   int
+}
+
+// UsersReadModel is the projection of the users optimized for the queries. It's built from the changes made
+// to the Repository asynchronously, so it can lag behind it a little.
+type UsersReadModel interface {
+	Users(context.Context, Filter, Pagination) ([]User, error)
+	CountryCounts(context.Context) ([]CountryCount, error)
 }
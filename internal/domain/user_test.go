@@ -131,7 +131,7 @@ func TestNewUser(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NewUser("", "", "", "", tt.email, "")
+			got, err := NewUser(DefaultTenant, "", "", "", "", tt.email, "")
 			assert.Equal(t, tt.want, got)
 			assert.Equal(t, tt.wantErr, err)
 		})
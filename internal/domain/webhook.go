@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook subscription not found")
+	ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url")
+	ErrPrivateWebhookURL = errors.New("webhook url must not point to a loopback, link-local or private address")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+// Events is a list of all the events that the application publishes
+var Events = []EventMsg{UserAdded, UserModified, UserDeleted}
+
+type WebhookID = uuid.UUID
+
+func ParseWebhookID(id string) (WebhookID, error) {
+	return uuid.Parse(id)
+}
+
+// WebhookSubscription represents an external service which wants to be notified about events with HTTP callbacks.
+// Every delivery is signed with the subscription Secret, so the subscriber can verify that the callback
+// was sent by this service. Only the events of the subscription's tenant are delivered.
+type WebhookSubscription struct {
+	ID       WebhookID
+	TenantID TenantID
+	URL      string
+	// EventTypes limits the events delivered to the subscriber, empty list means all events
+	EventTypes []EventMsg
+	Secret     string
+	Enabled    bool
+	// ConsecutiveFailures counts the deliveries that failed after all retries, since the last successful one
+	ConsecutiveFailures int
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+func NewWebhookSubscription(tenantID TenantID, rawURL string, eventTypes []EventMsg) (WebhookSubscription, error) {
+	if err := validateWebhookURL(rawURL); err != nil {
+		return WebhookSubscription{}, err
+	}
+	if err := validateEventTypes(eventTypes); err != nil {
+		return WebhookSubscription{}, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return WebhookSubscription{}, err
+	}
+
+	return WebhookSubscription{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Enabled:    true,
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// Modify changes the subscription, nil values are left unchanged.
+// Re-enabling a subscription resets its failures counter.
+func (s *WebhookSubscription) Modify(rawURL *string, eventTypes *[]EventMsg, enabled *bool) error {
+	if rawURL != nil {
+		if err := validateWebhookURL(*rawURL); err != nil {
+			return err
+		}
+		s.URL = *rawURL
+	}
+	if eventTypes != nil {
+		if err := validateEventTypes(*eventTypes); err != nil {
+			return err
+		}
+		s.EventTypes = *eventTypes
+	}
+	if enabled != nil {
+		if *enabled && !s.Enabled {
+			s.ConsecutiveFailures = 0
+		}
+		s.Enabled = *enabled
+	}
+
+	s.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// Accepts returns true if the event should be delivered to the subscriber
+func (s WebhookSubscription) Accepts(msg EventMsg) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+
+	for _, t := range s.EventTypes {
+		if t == msg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RegisterDeliveryResult updates the failures counter after a delivery has finished (with all its retries).
+// The subscription gets disabled once it reaches maxConsecutiveFailures,
+// so that a dead subscriber doesn't keep consuming resources.
+func (s *WebhookSubscription) RegisterDeliveryResult(succeeded bool, maxConsecutiveFailures int) {
+	if succeeded {
+		s.ConsecutiveFailures = 0
+	} else {
+		s.ConsecutiveFailures++
+	}
+
+	if maxConsecutiveFailures > 0 && s.ConsecutiveFailures >= maxConsecutiveFailures {
+		s.Enabled = false
+	}
+
+	s.UpdatedAt = time.Now().UTC()
+}
+
+// WebhookDelivery is a single attempt of delivering an event to a webhook subscriber
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	TenantID       TenantID
+	SubscriptionID WebhookID
+	EventType      EventMsg
+	Attempt        int
+	StatusCode     int
+	Error          string
+	Succeeded      bool
+	Duration       time.Duration
+	CreatedAt      time.Time
+}
+
+// WebhookRepository keeps the subscriptions and the deliveries of all the tenants, the ones of the other tenants
+// are never found. AddSubscription and AddDelivery store them in their TenantID, UpdateSubscription finds the
+// subscription in its TenantID.
+type WebhookRepository interface {
+	AddSubscription(WebhookSubscription) error
+	UpdateSubscription(WebhookSubscription) error
+	RemoveSubscription(TenantID, WebhookID) error
+	Subscription(TenantID, WebhookID) (WebhookSubscription, error)
+	Subscriptions(TenantID, Pagination) ([]WebhookSubscription, error)
+	// EnabledSubscriptions returns all the subscriptions of the tenant that should receive its events
+	EnabledSubscriptions(TenantID) ([]WebhookSubscription, error)
+
+	// RegisterDeliveryResult updates the failures counter of the subscription after a delivery has finished, and
+	// disables it once it reaches maxConsecutiveFailures, in one atomic update - see
+	// WebhookSubscription.RegisterDeliveryResult. It returns the updated subscription.
+	RegisterDeliveryResult(tenant TenantID, id WebhookID, succeeded bool, maxConsecutiveFailures int) (WebhookSubscription, error)
+
+	AddDelivery(WebhookDelivery) error
+	Deliveries(TenantID, WebhookID, Pagination) ([]WebhookDelivery, error)
+}
+
+// validateWebhookURL rejects the urls of the hosts of the deployment, e.g. the cloud metadata or the services
+// listening on localhost. The hosts given by the names are checked when they are dialed, see PublicWebhookAddress.
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrInvalidWebhookURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateWebhookURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !PublicWebhookAddress(addr) {
+		return ErrPrivateWebhookURL
+	}
+
+	return nil
+}
+
+// PublicWebhookAddress tells whether the webhooks can be delivered to the address: the loopback, link-local,
+// private, multicast and unspecified addresses are rejected
+func PublicWebhookAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+func validateEventTypes(eventTypes []EventMsg) error {
+	for _, t := range eventTypes {
+		known := false
+		for _, e := range Events {
+			if e == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return ErrUnknownEventType
+		}
+	}
+
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWebhookSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		eventTypes []EventMsg
+		wantErr    error
+	}{
+		{name: "valid_subscription", url: "https://example.com/hook", eventTypes: []EventMsg{UserAdded}},
+		{name: "all_events", url: "http://example.com/hook"},
+		{name: "relative_url", url: "/hook", wantErr: ErrInvalidWebhookURL},
+		{name: "unsupported_scheme", url: "ftp://example.com/hook", wantErr: ErrInvalidWebhookURL},
+		{name: "public_address", url: "http://93.184.216.34:8080/hook"},
+		{name: "localhost", url: "http://localhost:6379", wantErr: ErrPrivateWebhookURL},
+		{name: "loopback_address", url: "http://127.0.0.1/hook", wantErr: ErrPrivateWebhookURL},
+		{name: "link_local_address", url: "http://169.254.169.254/latest/meta-data", wantErr: ErrPrivateWebhookURL},
+		{name: "private_address", url: "https://10.0.0.12/hook", wantErr: ErrPrivateWebhookURL},
+		{name: "ipv6_loopback_address", url: "http://[::1]/hook", wantErr: ErrPrivateWebhookURL},
+		{name: "ipv4_mapped_private_address", url: "http://[::ffff:192.168.0.1]/hook", wantErr: ErrPrivateWebhookURL},
+		{name: "unknown_event_type", url: "https://example.com/hook", eventTypes: []EventMsg{"user-born"}, wantErr: ErrUnknownEventType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewWebhookSubscription(DefaultTenant, tt.url, tt.eventTypes)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, DefaultTenant, got.TenantID)
+				assert.True(t, got.Enabled)
+				assert.NotEmpty(t, got.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookSubscription_Accepts(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription WebhookSubscription
+		msg          EventMsg
+		want         bool
+	}{
+		{name: "no_filter_accepts_all", subscription: WebhookSubscription{Enabled: true}, msg: UserDeleted, want: true},
+		{name: "matching_filter", subscription: WebhookSubscription{Enabled: true, EventTypes: []EventMsg{UserAdded}}, msg: UserAdded, want: true},
+		{name: "not_matching_filter", subscription: WebhookSubscription{Enabled: true, EventTypes: []EventMsg{UserAdded}}, msg: UserDeleted},
+		{name: "disabled", subscription: WebhookSubscription{}, msg: UserAdded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.subscription.Accepts(tt.msg))
+		})
+	}
+}
+
+func TestWebhookSubscription_RegisterDeliveryResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		succeeded   bool
+		maxFailures int
+
+		wantFailures int
+		wantEnabled  bool
+	}{
+		{name: "success_resets_failures", failures: 3, succeeded: true, maxFailures: 5, wantFailures: 0, wantEnabled: true},
+		{name: "failure_increments_failures", failures: 3, maxFailures: 5, wantFailures: 4, wantEnabled: true},
+		{name: "too_many_failures_disable_subscription", failures: 4, maxFailures: 5, wantFailures: 5, wantEnabled: false},
+		{name: "no_limit", failures: 100, maxFailures: 0, wantFailures: 101, wantEnabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := WebhookSubscription{Enabled: true, ConsecutiveFailures: tt.failures}
+			s.RegisterDeliveryResult(tt.succeeded, tt.maxFailures)
+			assert.Equal(t, tt.wantFailures, s.ConsecutiveFailures)
+			assert.Equal(t, tt.wantEnabled, s.Enabled)
+		})
+	}
+}
+
+func TestWebhookSubscription_Modify_enabling_resets_failures(t *testing.T) {
+	enabled := true
+	s := WebhookSubscription{Enabled: false, ConsecutiveFailures: 10}
+
+	assert.NoError(t, s.Modify(nil, nil, &enabled))
+	assert.True(t, s.Enabled)
+	assert.Zero(t, s.ConsecutiveFailures)
+}
@@ -10,20 +10,47 @@ import (
 )
 
 const (
-	BasicAuthScopes = "basicAuth.Scopes"
+	ApiKeyAuthScopes = "apiKeyAuth.Scopes"
+	BearerAuthScopes = "bearerAuth.Scopes"
 )
 
+// Defines values for EventType.
+const (
+	UserAdded    EventType = "user-added"
+	UserDeleted  EventType = "user-deleted"
+	UserModified EventType = "user-modified"
+)
+
+// Defines values for HealthStatus.
+const (
+	Down HealthStatus = "down"
+	Up   HealthStatus = "up"
+)
+
+// DependencyHealth defines model for DependencyHealth.
+type DependencyHealth struct {
+	DurationMs int64        `json:"duration_ms"`
+	Status     HealthStatus `json:"status"`
+}
+
 // Error defines model for Error.
 type Error struct {
 	Code    int32  `json:"code"`
 	Message string `json:"message"`
 }
 
-// Ok defines model for Ok.
-type Ok struct {
-	Status string `json:"status"`
+// EventType defines model for EventType.
+type EventType string
+
+// Health defines model for Health.
+type Health struct {
+	Dependencies *map[string]DependencyHealth `json:"dependencies,omitempty"`
+	Status       HealthStatus                 `json:"status"`
 }
 
+// HealthStatus defines model for HealthStatus.
+type HealthStatus string
+
 // PatchUser defines model for PatchUser.
 type PatchUser struct {
 	Country   *string              `json:"country,omitempty"`
@@ -33,6 +60,13 @@ type PatchUser struct {
 	Nickname  *string              `json:"nickname,omitempty"`
 }
 
+// PatchWebhook defines model for PatchWebhook.
+type PatchWebhook struct {
+	Enabled    *bool        `json:"enabled,omitempty"`
+	EventTypes *[]EventType `json:"event_types,omitempty"`
+	Url        *string      `json:"url,omitempty"`
+}
+
 // PostUser defines model for PostUser.
 type PostUser struct {
 	Country   string              `json:"country"`
@@ -43,6 +77,12 @@ type PostUser struct {
 	Password  string              `json:"password"`
 }
 
+// PostWebhook defines model for PostWebhook.
+type PostWebhook struct {
+	EventTypes *[]EventType `json:"event_types,omitempty"`
+	Url        string       `json:"url"`
+}
+
 // User defines model for User.
 type User struct {
 	Country   string              `json:"country"`
@@ -60,6 +100,53 @@ type Users struct {
 	Users []User `json:"users"`
 }
 
+// Webhook defines model for Webhook.
+type Webhook struct {
+	// ConsecutiveFailures Number of deliveries which failed after all retries since the last successful one
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CreatedAt           time.Time `json:"created_at"`
+	Enabled             bool      `json:"enabled"`
+
+	// EventTypes Events delivered to the subscriber, empty list means all events
+	EventTypes []EventType        `json:"event_types"`
+	Id         openapi_types.UUID `json:"id"`
+
+	// Secret Secret used to sign the deliveries, returned only when the subscription is created
+	Secret    *string   `json:"secret,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Url       string    `json:"url"`
+}
+
+// WebhookDeliveries defines model for WebhookDeliveries.
+type WebhookDeliveries struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookDelivery defines model for WebhookDelivery.
+type WebhookDelivery struct {
+	Attempt    int                `json:"attempt"`
+	CreatedAt  time.Time          `json:"created_at"`
+	DurationMs int64              `json:"duration_ms"`
+	Error      string             `json:"error"`
+	EventType  EventType          `json:"event_type"`
+	Id         openapi_types.UUID `json:"id"`
+
+	// StatusCode HTTP status code returned by the subscriber, 0 if no response was received
+	StatusCode int  `json:"status_code"`
+	Succeeded  bool `json:"succeeded"`
+}
+
+// Webhooks defines model for Webhooks.
+type Webhooks struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// Limit Maximum number of records to return.
+type Limit = int32
+
+// Offset Number of records to skip for pagination.
+type Offset = int32
+
 // GetUsersParams defines parameters for GetUsers.
 type GetUsersParams struct {
 	FirstName *string              `form:"first_name,omitempty" json:"first_name,omitempty"`
@@ -71,8 +158,34 @@ type GetUsersParams struct {
 	Offset    *int32               `form:"offset,omitempty" json:"offset,omitempty"`
 }
 
+// PostUsersParams defines parameters for PostUsers.
+type PostUsersParams struct {
+	// IdempotencyKey Makes the request safe to retry. A repeated request with the same key returns the result of the original
+	// request, instead of creating the user again. Using the key with a different payload results in 422.
+	// The key consists of printable ASCII characters without spaces, e.g. a UUID, the other ones result in 400.
+	IdempotencyKey *string `json:"Idempotency-Key,omitempty"`
+}
+
+// GetWebhooksParams defines parameters for GetWebhooks.
+type GetWebhooksParams struct {
+	Limit  *Limit  `form:"limit,omitempty" json:"limit,omitempty"`
+	Offset *Offset `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// GetWebhooksWebhookIDDeliveriesParams defines parameters for GetWebhooksWebhookIDDeliveries.
+type GetWebhooksWebhookIDDeliveriesParams struct {
+	Limit  *Limit  `form:"limit,omitempty" json:"limit,omitempty"`
+	Offset *Offset `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
 // PostUsersJSONRequestBody defines body for PostUsers for application/json ContentType.
 type PostUsersJSONRequestBody = PostUser
 
 // PatchUsersUserIDJSONRequestBody defines body for PatchUsersUserID for application/json ContentType.
 type PatchUsersUserIDJSONRequestBody = PatchUser
+
+// PostWebhooksJSONRequestBody defines body for PostWebhooks for application/json ContentType.
+type PostWebhooksJSONRequestBody = PostWebhook
+
+// PatchWebhooksWebhookIDJSONRequestBody defines body for PatchWebhooksWebhookID for application/json ContentType.
+type PatchWebhooksWebhookIDJSONRequestBody = PatchWebhook
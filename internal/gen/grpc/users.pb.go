@@ -7,10 +7,13 @@
 package users_app
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -67,50 +70,6 @@ func (x *HealthCheckResponse) GetStatus() string {
 	return ""
 }
 
-type ModifyUserResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *ModifyUserResponse) Reset() {
-	*x = ModifyUserResponse{}
-	mi := &file_users_proto_msgTypes[1]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *ModifyUserResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*ModifyUserResponse) ProtoMessage() {}
-
-func (x *ModifyUserResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[1]
-	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use ModifyUserResponse.ProtoReflect.Descriptor instead.
-func (*ModifyUserResponse) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *ModifyUserResponse) GetStatus() string {
-	if x != nil {
-		return x.Status
-	}
-	return ""
-}
-
 type GetUsersRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Filter        *Filter                `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
@@ -121,7 +80,7 @@ type GetUsersRequest struct {
 
 func (x *GetUsersRequest) Reset() {
 	*x = GetUsersRequest{}
-	mi := &file_users_proto_msgTypes[2]
+	mi := &file_users_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -133,7 +92,7 @@ func (x *GetUsersRequest) String() string {
 func (*GetUsersRequest) ProtoMessage() {}
 
 func (x *GetUsersRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[2]
+	mi := &file_users_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -146,7 +105,7 @@ func (x *GetUsersRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetUsersRequest.ProtoReflect.Descriptor instead.
 func (*GetUsersRequest) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{2}
+	return file_users_proto_rawDescGZIP(), []int{1}
 }
 
 func (x *GetUsersRequest) GetFilter() *Filter {
@@ -173,7 +132,7 @@ type Pagination struct {
 
 func (x *Pagination) Reset() {
 	*x = Pagination{}
-	mi := &file_users_proto_msgTypes[3]
+	mi := &file_users_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -185,7 +144,7 @@ func (x *Pagination) String() string {
 func (*Pagination) ProtoMessage() {}
 
 func (x *Pagination) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[3]
+	mi := &file_users_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -198,7 +157,7 @@ func (x *Pagination) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Pagination.ProtoReflect.Descriptor instead.
 func (*Pagination) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{3}
+	return file_users_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Pagination) GetLimit() int32 {
@@ -215,20 +174,24 @@ func (x *Pagination) GetOffset() int32 {
 	return 0
 }
 
+// Filter matches the email case-insensitively, the rest of the fields exactly
 type Filter struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	FirstName     string                 `protobuf:"bytes,1,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
-	LastName      string                 `protobuf:"bytes,2,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
-	Nickname      string                 `protobuf:"bytes,3,opt,name=nickname,proto3" json:"nickname,omitempty"`
-	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
-	Country       string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	FirstName string                 `protobuf:"bytes,1,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName  string                 `protobuf:"bytes,2,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Nickname  string                 `protobuf:"bytes,3,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	Email     string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	Country   string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`
+	// search is the full-text search of the words of the names and the email, in the web search syntax:
+	// "quoted phrases", or, -excluded
+	Search        string `protobuf:"bytes,6,opt,name=search,proto3" json:"search,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Filter) Reset() {
 	*x = Filter{}
-	mi := &file_users_proto_msgTypes[4]
+	mi := &file_users_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -240,7 +203,7 @@ func (x *Filter) String() string {
 func (*Filter) ProtoMessage() {}
 
 func (x *Filter) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[4]
+	mi := &file_users_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -253,7 +216,7 @@ func (x *Filter) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Filter.ProtoReflect.Descriptor instead.
 func (*Filter) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{4}
+	return file_users_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Filter) GetFirstName() string {
@@ -291,6 +254,13 @@ func (x *Filter) GetCountry() string {
 	return ""
 }
 
+func (x *Filter) GetSearch() string {
+	if x != nil {
+		return x.Search
+	}
+	return ""
+}
+
 type GetUsersResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Users         []*User                `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
@@ -300,7 +270,7 @@ type GetUsersResponse struct {
 
 func (x *GetUsersResponse) Reset() {
 	*x = GetUsersResponse{}
-	mi := &file_users_proto_msgTypes[5]
+	mi := &file_users_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -312,7 +282,7 @@ func (x *GetUsersResponse) String() string {
 func (*GetUsersResponse) ProtoMessage() {}
 
 func (x *GetUsersResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[5]
+	mi := &file_users_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -325,7 +295,7 @@ func (x *GetUsersResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetUsersResponse.ProtoReflect.Descriptor instead.
 func (*GetUsersResponse) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{5}
+	return file_users_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *GetUsersResponse) GetUsers() []*User {
@@ -335,21 +305,121 @@ func (x *GetUsersResponse) GetUsers() []*User {
 	return nil
 }
 
-type CreateUserRequest struct {
+type GetCountryCountsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Countries     []*CountryCount        `protobuf:"bytes,1,rep,name=countries,proto3" json:"countries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCountryCountsResponse) Reset() {
+	*x = GetCountryCountsResponse{}
+	mi := &file_users_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCountryCountsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCountryCountsResponse) ProtoMessage() {}
+
+func (x *GetCountryCountsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_users_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCountryCountsResponse.ProtoReflect.Descriptor instead.
+func (*GetCountryCountsResponse) Descriptor() ([]byte, []int) {
+	return file_users_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetCountryCountsResponse) GetCountries() []*CountryCount {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+type CountryCount struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	FirstName     string                 `protobuf:"bytes,1,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
-	LastName      string                 `protobuf:"bytes,2,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
-	Nickname      string                 `protobuf:"bytes,3,opt,name=nickname,proto3" json:"nickname,omitempty"`
-	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
-	Country       string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`
-	Password      string                 `protobuf:"bytes,6,opt,name=password,proto3" json:"password,omitempty"`
+	Country       string                 `protobuf:"bytes,1,opt,name=country,proto3" json:"country,omitempty"`
+	Users         int32                  `protobuf:"varint,2,opt,name=users,proto3" json:"users,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *CountryCount) Reset() {
+	*x = CountryCount{}
+	mi := &file_users_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountryCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountryCount) ProtoMessage() {}
+
+func (x *CountryCount) ProtoReflect() protoreflect.Message {
+	mi := &file_users_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountryCount.ProtoReflect.Descriptor instead.
+func (*CountryCount) Descriptor() ([]byte, []int) {
+	return file_users_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CountryCount) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *CountryCount) GetUsers() int32 {
+	if x != nil {
+		return x.Users
+	}
+	return 0
+}
+
+type CreateUserRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	FirstName string                 `protobuf:"bytes,1,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName  string                 `protobuf:"bytes,2,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Nickname  string                 `protobuf:"bytes,3,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	Email     string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	Country   string                 `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`
+	Password  string                 `protobuf:"bytes,6,opt,name=password,proto3" json:"password,omitempty"`
+	// idempotency_key makes the request safe to retry, it can be passed in the idempotency-key metadata as well.
+	// A repeated request with the same key returns the original result, the key used with a different payload
+	// results in FAILED_PRECONDITION.
+	IdempotencyKey string `protobuf:"bytes,7,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
 func (x *CreateUserRequest) Reset() {
 	*x = CreateUserRequest{}
-	mi := &file_users_proto_msgTypes[6]
+	mi := &file_users_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -361,7 +431,7 @@ func (x *CreateUserRequest) String() string {
 func (*CreateUserRequest) ProtoMessage() {}
 
 func (x *CreateUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[6]
+	mi := &file_users_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -374,7 +444,7 @@ func (x *CreateUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateUserRequest.ProtoReflect.Descriptor instead.
 func (*CreateUserRequest) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{6}
+	return file_users_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *CreateUserRequest) GetFirstName() string {
@@ -419,21 +489,32 @@ func (x *CreateUserRequest) GetPassword() string {
 	return ""
 }
 
+func (x *CreateUserRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+// ModifyUserRequest changes exactly the fields listed in update_mask, a listed field which isn't set gets cleared.
+// Without update_mask the fields which are set are changed. The email can't be cleared.
 type ModifyUserRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	FirstName     string                 `protobuf:"bytes,2,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
-	LastName      string                 `protobuf:"bytes,3,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
-	Nickname      string                 `protobuf:"bytes,4,opt,name=nickname,proto3" json:"nickname,omitempty"`
-	Email         string                 `protobuf:"bytes,5,opt,name=email,proto3" json:"email,omitempty"`
-	Country       string                 `protobuf:"bytes,6,opt,name=country,proto3" json:"country,omitempty"`
+	state     protoimpl.MessageState  `protogen:"open.v1"`
+	Id        string                  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	FirstName *wrapperspb.StringValue `protobuf:"bytes,7,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName  *wrapperspb.StringValue `protobuf:"bytes,8,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Nickname  *wrapperspb.StringValue `protobuf:"bytes,9,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	Email     *wrapperspb.StringValue `protobuf:"bytes,10,opt,name=email,proto3" json:"email,omitempty"`
+	Country   *wrapperspb.StringValue `protobuf:"bytes,11,opt,name=country,proto3" json:"country,omitempty"`
+	// update_mask paths: first_name, last_name, nickname, email, country
+	UpdateMask    *fieldmaskpb.FieldMask `protobuf:"bytes,12,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ModifyUserRequest) Reset() {
 	*x = ModifyUserRequest{}
-	mi := &file_users_proto_msgTypes[7]
+	mi := &file_users_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -445,7 +526,7 @@ func (x *ModifyUserRequest) String() string {
 func (*ModifyUserRequest) ProtoMessage() {}
 
 func (x *ModifyUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[7]
+	mi := &file_users_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -458,7 +539,7 @@ func (x *ModifyUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ModifyUserRequest.ProtoReflect.Descriptor instead.
 func (*ModifyUserRequest) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{7}
+	return file_users_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ModifyUserRequest) GetId() string {
@@ -468,39 +549,46 @@ func (x *ModifyUserRequest) GetId() string {
 	return ""
 }
 
-func (x *ModifyUserRequest) GetFirstName() string {
+func (x *ModifyUserRequest) GetFirstName() *wrapperspb.StringValue {
 	if x != nil {
 		return x.FirstName
 	}
-	return ""
+	return nil
 }
 
-func (x *ModifyUserRequest) GetLastName() string {
+func (x *ModifyUserRequest) GetLastName() *wrapperspb.StringValue {
 	if x != nil {
 		return x.LastName
 	}
-	return ""
+	return nil
 }
 
-func (x *ModifyUserRequest) GetNickname() string {
+func (x *ModifyUserRequest) GetNickname() *wrapperspb.StringValue {
 	if x != nil {
 		return x.Nickname
 	}
-	return ""
+	return nil
 }
 
-func (x *ModifyUserRequest) GetEmail() string {
+func (x *ModifyUserRequest) GetEmail() *wrapperspb.StringValue {
 	if x != nil {
 		return x.Email
 	}
-	return ""
+	return nil
 }
 
-func (x *ModifyUserRequest) GetCountry() string {
+func (x *ModifyUserRequest) GetCountry() *wrapperspb.StringValue {
 	if x != nil {
 		return x.Country
 	}
-	return ""
+	return nil
+}
+
+func (x *ModifyUserRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
 }
 
 type DeleteUserRequest struct {
@@ -512,7 +600,7 @@ type DeleteUserRequest struct {
 
 func (x *DeleteUserRequest) Reset() {
 	*x = DeleteUserRequest{}
-	mi := &file_users_proto_msgTypes[8]
+	mi := &file_users_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -524,7 +612,7 @@ func (x *DeleteUserRequest) String() string {
 func (*DeleteUserRequest) ProtoMessage() {}
 
 func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[8]
+	mi := &file_users_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -537,7 +625,7 @@ func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteUserRequest.ProtoReflect.Descriptor instead.
 func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{8}
+	return file_users_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DeleteUserRequest) GetId() string {
@@ -563,7 +651,7 @@ type User struct {
 
 func (x *User) Reset() {
 	*x = User{}
-	mi := &file_users_proto_msgTypes[9]
+	mi := &file_users_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -575,7 +663,7 @@ func (x *User) String() string {
 func (*User) ProtoMessage() {}
 
 func (x *User) ProtoReflect() protoreflect.Message {
-	mi := &file_users_proto_msgTypes[9]
+	mi := &file_users_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -588,7 +676,7 @@ func (x *User) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use User.ProtoReflect.Descriptor instead.
 func (*User) Descriptor() ([]byte, []int) {
-	return file_users_proto_rawDescGZIP(), []int{9}
+	return file_users_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *User) GetId() string {
@@ -651,10 +739,8 @@ var File_users_proto protoreflect.FileDescriptor
 
 const file_users_proto_rawDesc = "" +
 	"\n" +
-	"\vusers.proto\x12\x05users\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"-\n" +
+	"\vusers.proto\x12\x05users\x1a\x1cgoogle/api/annotations.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a google/protobuf/field_mask.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1egoogle/protobuf/wrappers.proto\"-\n" +
 	"\x13HealthCheckResponse\x12\x16\n" +
-	"\x06status\x18\x01 \x01(\tR\x06status\",\n" +
-	"\x12ModifyUserResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\"k\n" +
 	"\x0fGetUsersRequest\x12%\n" +
 	"\x06filter\x18\x01 \x01(\v2\r.users.FilterR\x06filter\x121\n" +
@@ -664,16 +750,22 @@ const file_users_proto_rawDesc = "" +
 	"\n" +
 	"Pagination\x12\x14\n" +
 	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x16\n" +
-	"\x06offset\x18\x02 \x01(\x05R\x06offset\"\x90\x01\n" +
+	"\x06offset\x18\x02 \x01(\x05R\x06offset\"\xa8\x01\n" +
 	"\x06Filter\x12\x1d\n" +
 	"\n" +
 	"first_name\x18\x01 \x01(\tR\tfirstName\x12\x1b\n" +
 	"\tlast_name\x18\x02 \x01(\tR\blastName\x12\x1a\n" +
 	"\bnickname\x18\x03 \x01(\tR\bnickname\x12\x14\n" +
 	"\x05email\x18\x04 \x01(\tR\x05email\x12\x18\n" +
-	"\acountry\x18\x05 \x01(\tR\acountry\"5\n" +
+	"\acountry\x18\x05 \x01(\tR\acountry\x12\x16\n" +
+	"\x06search\x18\x06 \x01(\tR\x06search\"5\n" +
 	"\x10GetUsersResponse\x12!\n" +
-	"\x05users\x18\x01 \x03(\v2\v.users.UserR\x05users\"\xb7\x01\n" +
+	"\x05users\x18\x01 \x03(\v2\v.users.UserR\x05users\"M\n" +
+	"\x18GetCountryCountsResponse\x121\n" +
+	"\tcountries\x18\x01 \x03(\v2\x13.users.CountryCountR\tcountries\">\n" +
+	"\fCountryCount\x12\x18\n" +
+	"\acountry\x18\x01 \x01(\tR\acountry\x12\x14\n" +
+	"\x05users\x18\x02 \x01(\x05R\x05users\"\xe0\x01\n" +
 	"\x11CreateUserRequest\x12\x1d\n" +
 	"\n" +
 	"first_name\x18\x01 \x01(\tR\tfirstName\x12\x1b\n" +
@@ -681,15 +773,19 @@ const file_users_proto_rawDesc = "" +
 	"\bnickname\x18\x03 \x01(\tR\bnickname\x12\x14\n" +
 	"\x05email\x18\x04 \x01(\tR\x05email\x12\x18\n" +
 	"\acountry\x18\x05 \x01(\tR\acountry\x12\x1a\n" +
-	"\bpassword\x18\x06 \x01(\tR\bpassword\"\xab\x01\n" +
+	"\bpassword\x18\x06 \x01(\tR\bpassword\x12'\n" +
+	"\x0fidempotency_key\x18\a \x01(\tR\x0eidempotencyKey\"\x84\x03\n" +
 	"\x11ModifyUserRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12;\n" +
 	"\n" +
-	"first_name\x18\x02 \x01(\tR\tfirstName\x12\x1b\n" +
-	"\tlast_name\x18\x03 \x01(\tR\blastName\x12\x1a\n" +
-	"\bnickname\x18\x04 \x01(\tR\bnickname\x12\x14\n" +
-	"\x05email\x18\x05 \x01(\tR\x05email\x12\x18\n" +
-	"\acountry\x18\x06 \x01(\tR\acountry\"#\n" +
+	"first_name\x18\a \x01(\v2\x1c.google.protobuf.StringValueR\tfirstName\x129\n" +
+	"\tlast_name\x18\b \x01(\v2\x1c.google.protobuf.StringValueR\blastName\x128\n" +
+	"\bnickname\x18\t \x01(\v2\x1c.google.protobuf.StringValueR\bnickname\x122\n" +
+	"\x05email\x18\n" +
+	" \x01(\v2\x1c.google.protobuf.StringValueR\x05email\x126\n" +
+	"\acountry\x18\v \x01(\v2\x1c.google.protobuf.StringValueR\acountry\x12;\n" +
+	"\vupdate_mask\x18\f \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMaskJ\x04\b\x02\x10\a\"#\n" +
 	"\x11DeleteUserRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\"\x94\x02\n" +
 	"\x04User\x12\x0e\n" +
@@ -703,16 +799,17 @@ const file_users_proto_rawDesc = "" +
 	"\n" +
 	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
 	"\n" +
-	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt2\xc9\x02\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt2\xf3\x03\n" +
 	"\x05Users\x12C\n" +
-	"\vHealthCheck\x12\x16.google.protobuf.Empty\x1a\x1a.users.HealthCheckResponse\"\x00\x12=\n" +
-	"\bGetUsers\x12\x16.users.GetUsersRequest\x1a\x17.users.GetUsersResponse\"\x00\x125\n" +
+	"\vHealthCheck\x12\x16.google.protobuf.Empty\x1a\x1a.users.HealthCheckResponse\"\x00\x12N\n" +
+	"\bGetUsers\x12\x16.users.GetUsersRequest\x1a\x17.users.GetUsersResponse\"\x11\x82\xd3\xe4\x93\x02\v\x12\t/v1/users\x12b\n" +
+	"\x10GetCountryCounts\x12\x16.google.protobuf.Empty\x1a\x1f.users.GetCountryCountsResponse\"\x15\x82\xd3\xe4\x93\x02\x0f\x12\r/v1/countries\x12I\n" +
 	"\n" +
-	"CreateUser\x12\x18.users.CreateUserRequest\x1a\v.users.User\"\x00\x12C\n" +
+	"CreateUser\x12\x18.users.CreateUserRequest\x1a\v.users.User\"\x14\x82\xd3\xe4\x93\x02\x0e:\x01*\"\t/v1/users\x12N\n" +
 	"\n" +
-	"ModifyUser\x12\x18.users.ModifyUserRequest\x1a\x19.users.ModifyUserResponse\"\x00\x12@\n" +
+	"ModifyUser\x12\x18.users.ModifyUserRequest\x1a\v.users.User\"\x19\x82\xd3\xe4\x93\x02\x13:\x01*2\x0e/v1/users/{id}\x12V\n" +
 	"\n" +
-	"DeleteUser\x12\x18.users.DeleteUserRequest\x1a\x16.google.protobuf.Empty\"\x00B+Z)github.com/krzysztofSkolimowski/users-appb\x06proto3"
+	"DeleteUser\x12\x18.users.DeleteUserRequest\x1a\x16.google.protobuf.Empty\"\x16\x82\xd3\xe4\x93\x02\x10*\x0e/v1/users/{id}B+Z)github.com/krzysztofSkolimowski/users-appb\x06proto3"
 
 var (
 	file_users_proto_rawDescOnce sync.Once
@@ -726,42 +823,54 @@ func file_users_proto_rawDescGZIP() []byte {
 	return file_users_proto_rawDescData
 }
 
-var file_users_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_users_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_users_proto_goTypes = []any{
-	(*HealthCheckResponse)(nil),   // 0: users.HealthCheckResponse
-	(*ModifyUserResponse)(nil),    // 1: users.ModifyUserResponse
-	(*GetUsersRequest)(nil),       // 2: users.GetUsersRequest
-	(*Pagination)(nil),            // 3: users.Pagination
-	(*Filter)(nil),                // 4: users.Filter
-	(*GetUsersResponse)(nil),      // 5: users.GetUsersResponse
-	(*CreateUserRequest)(nil),     // 6: users.CreateUserRequest
-	(*ModifyUserRequest)(nil),     // 7: users.ModifyUserRequest
-	(*DeleteUserRequest)(nil),     // 8: users.DeleteUserRequest
-	(*User)(nil),                  // 9: users.User
-	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
-	(*emptypb.Empty)(nil),         // 11: google.protobuf.Empty
+	(*HealthCheckResponse)(nil),      // 0: users.HealthCheckResponse
+	(*GetUsersRequest)(nil),          // 1: users.GetUsersRequest
+	(*Pagination)(nil),               // 2: users.Pagination
+	(*Filter)(nil),                   // 3: users.Filter
+	(*GetUsersResponse)(nil),         // 4: users.GetUsersResponse
+	(*GetCountryCountsResponse)(nil), // 5: users.GetCountryCountsResponse
+	(*CountryCount)(nil),             // 6: users.CountryCount
+	(*CreateUserRequest)(nil),        // 7: users.CreateUserRequest
+	(*ModifyUserRequest)(nil),        // 8: users.ModifyUserRequest
+	(*DeleteUserRequest)(nil),        // 9: users.DeleteUserRequest
+	(*User)(nil),                     // 10: users.User
+	(*wrapperspb.StringValue)(nil),   // 11: google.protobuf.StringValue
+	(*fieldmaskpb.FieldMask)(nil),    // 12: google.protobuf.FieldMask
+	(*timestamppb.Timestamp)(nil),    // 13: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),            // 14: google.protobuf.Empty
 }
 var file_users_proto_depIdxs = []int32{
-	4,  // 0: users.GetUsersRequest.filter:type_name -> users.Filter
-	3,  // 1: users.GetUsersRequest.pagination:type_name -> users.Pagination
-	9,  // 2: users.GetUsersResponse.users:type_name -> users.User
-	10, // 3: users.User.created_at:type_name -> google.protobuf.Timestamp
-	10, // 4: users.User.updated_at:type_name -> google.protobuf.Timestamp
-	11, // 5: users.Users.HealthCheck:input_type -> google.protobuf.Empty
-	2,  // 6: users.Users.GetUsers:input_type -> users.GetUsersRequest
-	6,  // 7: users.Users.CreateUser:input_type -> users.CreateUserRequest
-	7,  // 8: users.Users.ModifyUser:input_type -> users.ModifyUserRequest
-	8,  // 9: users.Users.DeleteUser:input_type -> users.DeleteUserRequest
-	0,  // 10: users.Users.HealthCheck:output_type -> users.HealthCheckResponse
-	5,  // 11: users.Users.GetUsers:output_type -> users.GetUsersResponse
-	9,  // 12: users.Users.CreateUser:output_type -> users.User
-	1,  // 13: users.Users.ModifyUser:output_type -> users.ModifyUserResponse
-	11, // 14: users.Users.DeleteUser:output_type -> google.protobuf.Empty
-	10, // [10:15] is the sub-list for method output_type
-	5,  // [5:10] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	3,  // 0: users.GetUsersRequest.filter:type_name -> users.Filter
+	2,  // 1: users.GetUsersRequest.pagination:type_name -> users.Pagination
+	10, // 2: users.GetUsersResponse.users:type_name -> users.User
+	6,  // 3: users.GetCountryCountsResponse.countries:type_name -> users.CountryCount
+	11, // 4: users.ModifyUserRequest.first_name:type_name -> google.protobuf.StringValue
+	11, // 5: users.ModifyUserRequest.last_name:type_name -> google.protobuf.StringValue
+	11, // 6: users.ModifyUserRequest.nickname:type_name -> google.protobuf.StringValue
+	11, // 7: users.ModifyUserRequest.email:type_name -> google.protobuf.StringValue
+	11, // 8: users.ModifyUserRequest.country:type_name -> google.protobuf.StringValue
+	12, // 9: users.ModifyUserRequest.update_mask:type_name -> google.protobuf.FieldMask
+	13, // 10: users.User.created_at:type_name -> google.protobuf.Timestamp
+	13, // 11: users.User.updated_at:type_name -> google.protobuf.Timestamp
+	14, // 12: users.Users.HealthCheck:input_type -> google.protobuf.Empty
+	1,  // 13: users.Users.GetUsers:input_type -> users.GetUsersRequest
+	14, // 14: users.Users.GetCountryCounts:input_type -> google.protobuf.Empty
+	7,  // 15: users.Users.CreateUser:input_type -> users.CreateUserRequest
+	8,  // 16: users.Users.ModifyUser:input_type -> users.ModifyUserRequest
+	9,  // 17: users.Users.DeleteUser:input_type -> users.DeleteUserRequest
+	0,  // 18: users.Users.HealthCheck:output_type -> users.HealthCheckResponse
+	4,  // 19: users.Users.GetUsers:output_type -> users.GetUsersResponse
+	5,  // 20: users.Users.GetCountryCounts:output_type -> users.GetCountryCountsResponse
+	10, // 21: users.Users.CreateUser:output_type -> users.User
+	10, // 22: users.Users.ModifyUser:output_type -> users.User
+	14, // 23: users.Users.DeleteUser:output_type -> google.protobuf.Empty
+	18, // [18:24] is the sub-list for method output_type
+	12, // [12:18] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_users_proto_init() }
@@ -775,7 +884,7 @@ func file_users_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_users_proto_rawDesc), len(file_users_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
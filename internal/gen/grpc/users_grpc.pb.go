@@ -20,11 +20,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Users_HealthCheck_FullMethodName = "/users.Users/HealthCheck"
-	Users_GetUsers_FullMethodName    = "/users.Users/GetUsers"
-	Users_CreateUser_FullMethodName  = "/users.Users/CreateUser"
-	Users_ModifyUser_FullMethodName  = "/users.Users/ModifyUser"
-	Users_DeleteUser_FullMethodName  = "/users.Users/DeleteUser"
+	Users_HealthCheck_FullMethodName      = "/users.Users/HealthCheck"
+	Users_GetUsers_FullMethodName         = "/users.Users/GetUsers"
+	Users_GetCountryCounts_FullMethodName = "/users.Users/GetCountryCounts"
+	Users_CreateUser_FullMethodName       = "/users.Users/CreateUser"
+	Users_ModifyUser_FullMethodName       = "/users.Users/ModifyUser"
+	Users_DeleteUser_FullMethodName       = "/users.Users/DeleteUser"
 )
 
 // UsersClient is the client API for Users service.
@@ -33,8 +34,11 @@ const (
 type UsersClient interface {
 	HealthCheck(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*HealthCheckResponse, error)
 	GetUsers(ctx context.Context, in *GetUsersRequest, opts ...grpc.CallOption) (*GetUsersResponse, error)
+	// GetCountryCounts returns the number of the users of every country, ordered by the country
+	GetCountryCounts(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetCountryCountsResponse, error)
 	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error)
-	ModifyUser(ctx context.Context, in *ModifyUserRequest, opts ...grpc.CallOption) (*ModifyUserResponse, error)
+	// ModifyUser returns the user after the modification
+	ModifyUser(ctx context.Context, in *ModifyUserRequest, opts ...grpc.CallOption) (*User, error)
 	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
@@ -66,6 +70,16 @@ func (c *usersClient) GetUsers(ctx context.Context, in *GetUsersRequest, opts ..
 	return out, nil
 }
 
+func (c *usersClient) GetCountryCounts(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetCountryCountsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCountryCountsResponse)
+	err := c.cc.Invoke(ctx, Users_GetCountryCounts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *usersClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(User)
@@ -76,9 +90,9 @@ func (c *usersClient) CreateUser(ctx context.Context, in *CreateUserRequest, opt
 	return out, nil
 }
 
-func (c *usersClient) ModifyUser(ctx context.Context, in *ModifyUserRequest, opts ...grpc.CallOption) (*ModifyUserResponse, error) {
+func (c *usersClient) ModifyUser(ctx context.Context, in *ModifyUserRequest, opts ...grpc.CallOption) (*User, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(ModifyUserResponse)
+	out := new(User)
 	err := c.cc.Invoke(ctx, Users_ModifyUser_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -102,8 +116,11 @@ func (c *usersClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opt
 type UsersServer interface {
 	HealthCheck(context.Context, *emptypb.Empty) (*HealthCheckResponse, error)
 	GetUsers(context.Context, *GetUsersRequest) (*GetUsersResponse, error)
+	// GetCountryCounts returns the number of the users of every country, ordered by the country
+	GetCountryCounts(context.Context, *emptypb.Empty) (*GetCountryCountsResponse, error)
 	CreateUser(context.Context, *CreateUserRequest) (*User, error)
-	ModifyUser(context.Context, *ModifyUserRequest) (*ModifyUserResponse, error)
+	// ModifyUser returns the user after the modification
+	ModifyUser(context.Context, *ModifyUserRequest) (*User, error)
 	DeleteUser(context.Context, *DeleteUserRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedUsersServer()
 }
@@ -121,10 +138,13 @@ func (UnimplementedUsersServer) HealthCheck(context.Context, *emptypb.Empty) (*H
 func (UnimplementedUsersServer) GetUsers(context.Context, *GetUsersRequest) (*GetUsersResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUsers not implemented")
 }
+func (UnimplementedUsersServer) GetCountryCounts(context.Context, *emptypb.Empty) (*GetCountryCountsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCountryCounts not implemented")
+}
 func (UnimplementedUsersServer) CreateUser(context.Context, *CreateUserRequest) (*User, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
 }
-func (UnimplementedUsersServer) ModifyUser(context.Context, *ModifyUserRequest) (*ModifyUserResponse, error) {
+func (UnimplementedUsersServer) ModifyUser(context.Context, *ModifyUserRequest) (*User, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ModifyUser not implemented")
 }
 func (UnimplementedUsersServer) DeleteUser(context.Context, *DeleteUserRequest) (*emptypb.Empty, error) {
@@ -187,6 +207,24 @@ func _Users_GetUsers_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Users_GetCountryCounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).GetCountryCounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Users_GetCountryCounts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).GetCountryCounts(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Users_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateUserRequest)
 	if err := dec(in); err != nil {
@@ -256,6 +294,10 @@ var Users_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUsers",
 			Handler:    _Users_GetUsers_Handler,
 		},
+		{
+			MethodName: "GetCountryCounts",
+			Handler:    _Users_GetCountryCounts_Handler,
+		},
 		{
 			MethodName: "CreateUser",
 			Handler:    _Users_CreateUser_Handler,
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// lifecycle keeps track of the components, which need to be stopped when the application shuts down.
+// Shutdown hooks are run in the reverse order of registration - components should be registered right after
+// they are created, so that e.g. servers are stopped before the publishers and the database they depend on.
+type lifecycle struct {
+	logger *zap.Logger
+	hooks  []shutdownHook
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+func newLifecycle(logger *zap.Logger) *lifecycle {
+	return &lifecycle{logger: logger}
+}
+
+// onShutdown registers a function, which will be called during the shutdown
+func (l *lifecycle) onShutdown(name string, fn func(context.Context) error) {
+	l.hooks = append(l.hooks, shutdownHook{name, fn})
+}
+
+// onShutdownClose registers a Close function, which doesn't accept a context
+func (l *lifecycle) onShutdownClose(name string, closeFn func() error) {
+	l.onShutdown(name, func(context.Context) error { return closeFn() })
+}
+
+// shutdown runs all the hooks, even if some of them fail. All the errors are returned joined.
+// The context deadline is shared by all the hooks.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	var errs []error
+	for i := len(l.hooks) - 1; i >= 0; i-- {
+		hook := l.hooks[i]
+
+		l.logger.Info("shutting down", zap.String("component", hook.name))
+		if err := hook.fn(ctx); err != nil {
+			l.logger.Error("shutdown failed", zap.String("component", hook.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
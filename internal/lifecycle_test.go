@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLifecycle_shutdown(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	var called []string
+	hook := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			called = append(called, name)
+			return err
+		}
+	}
+
+	l := newLifecycle(zap.NewNop())
+	l.onShutdown("database", hook("database", nil))
+	l.onShutdown("publisher", hook("publisher", errFailed))
+	l.onShutdown("server", hook("server", nil))
+
+	err := l.shutdown(context.Background())
+
+	assert.Equal(t, []string{"server", "publisher", "database"}, called, "hooks should run in reverse order, all of them")
+	assert.ErrorIs(t, err, errFailed)
+	assert.ErrorContains(t, err, "publisher")
+}
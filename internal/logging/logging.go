@@ -0,0 +1,102 @@
+// Package logging carries the application logger in the context.
+//
+// The ports put a logger enriched with the request details (request id, trace id, transport, method) into the context
+// of every request, so that everything logged while handling the request, by the services and adapters as well,
+// can be correlated. FromContext should be used everywhere instead of a logger kept in a struct.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"users-app/redact"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Names of the fields shared by the log entries
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+	TransportKey = "transport"
+	MethodKey    = "method"
+	UserIDKey    = "user_id"
+	ClientKey    = "client"
+	PrincipalKey = "principal"
+	TenantKey    = "tenant"
+)
+
+type Config struct {
+	// Level is one of trace, debug, info, warn, error - trace is the same as debug, zap has no trace level
+	Level string
+	JSON  bool
+	// HashPII replaces the personal data with its hash, see redact.Options
+	HashPII bool
+}
+
+// New creates the application logger, it writes JSON (or human-readable text, for the development) to stderr.
+// The secrets are always masked in the logged fields, see redact.NewCore.
+func New(config Config) (*zap.Logger, error) {
+	level := config.Level
+	if level == "trace" {
+		level = "debug"
+	}
+	atomicLevel, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	zapConfig := zap.NewProductionConfig()
+	if !config.JSON {
+		zapConfig = zap.NewDevelopmentConfig()
+	}
+	zapConfig.Level = atomicLevel
+	zapConfig.EncoderConfig.TimeKey = "timestamp"
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	return zapConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redact.NewCore(core, redact.Options{HashPII: config.HashPII})
+	}))
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of the context carrying the logger
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// With returns a copy of the context carrying the logger from the context, extended with the fields.
+// The span fields are not stored, FromContext adds the ones of the span current at the time of logging.
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithLogger(ctx, contextLogger(ctx).With(fields...))
+}
+
+// FromContext returns the logger carried by the context, or the global one (see zap.ReplaceGlobals).
+// The trace and span ids of the current span are added, so that the log entries can be found from the trace.
+func FromContext(ctx context.Context) *zap.Logger {
+	logger := contextLogger(ctx)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		logger = logger.With(
+			zap.String(TraceIDKey, spanContext.TraceID().String()),
+			zap.String(SpanIDKey, spanContext.SpanID().String()),
+		)
+	}
+
+	return logger
+}
+
+func contextLogger(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.L()
+}
+
+// UserID is the field identifying the user the log entry is about
+func UserID(id fmt.Stringer) zap.Field {
+	return zap.Stringer(UserIDKey, id)
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := WithLogger(context.Background(), zap.New(core))
+	ctx = With(ctx, zap.String(RequestIDKey, "request-1"), zap.String(TransportKey, "http"))
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "span")
+	defer span.End()
+
+	FromContext(ctx).Info("hello")
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "request-1", fields[RequestIDKey])
+	assert.Equal(t, "http", fields[TransportKey])
+	assert.Equal(t, span.SpanContext().TraceID().String(), fields[TraceIDKey])
+	assert.Equal(t, span.SpanContext().SpanID().String(), fields[SpanIDKey])
+}
+
+func TestWith_span_fields_added_once(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+	ctx, parent := tracer.Start(WithLogger(context.Background(), zap.New(core)), "parent")
+	defer parent.End()
+	ctx = With(ctx, zap.String(PrincipalKey, "client-1"))
+	ctx = With(ctx, zap.String(TenantKey, "acme"))
+	ctx, child := tracer.Start(ctx, "child")
+	defer child.End()
+
+	FromContext(ctx).Info("hello")
+
+	require.Equal(t, 1, logs.Len())
+	var spanIDs []string
+	for _, field := range logs.All()[0].Context {
+		if field.Key == SpanIDKey {
+			spanIDs = append(spanIDs, field.String)
+		}
+	}
+	assert.Equal(t, []string{child.SpanContext().SpanID().String()}, spanIDs)
+}
+
+func TestFromContext_global_logger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	FromContext(context.Background()).Info("hello")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Empty(t, logs.All()[0].ContextMap())
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		level   string
+		enabled zap.AtomicLevel
+		wantErr bool
+	}{
+		{level: "trace", enabled: zap.NewAtomicLevelAt(zap.DebugLevel)},
+		{level: "info", enabled: zap.NewAtomicLevelAt(zap.InfoLevel)},
+		{level: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			logger, err := New(Config{Level: tt.level, JSON: true})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, logger.Core().Enabled(tt.enabled.Level()))
+			assert.False(t, logger.Core().Enabled(tt.enabled.Level()-1))
+		})
+	}
+}
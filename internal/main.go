@@ -1,143 +1,607 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 	"users-app/adapters"
+	"users-app/auth"
+	"users-app/config"
+	"users-app/domain"
 	"users-app/gen/api"
 	users_app "users-app/gen/grpc"
+	"users-app/logging"
 	ports_grpc "users-app/ports/grpc"
 	ports "users-app/ports/http"
+	"users-app/ratelimit"
 	"users-app/service"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httplog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
-	logger, err := zap.NewProduction()
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case len(args) == 2 && args[0] == "projection" && args[1] == "rebuild":
+		if err := rebuildProjection(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case len(args) == 2 && args[0] == "api-key" && args[1] == "new":
+		if err := printNewAPIKey(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", strings.Join(args, " "))
+		os.Exit(2)
+	}
+
+	logger, err := logging.New(logging.Config{Level: cfg.Log.Level, JSON: cfg.Log.JSON, HashPII: cfg.Log.HashPII})
 	if err != nil {
 		log.Fatalf("failed to create logger: %v", err)
 	}
-	repo := adapters.NewRepository(
-		adapters.RepoConfig{
-			Host:     os.Getenv("DB_HOST"),
-			Database: os.Getenv("POSTGRES_DB"),
-			User:     os.Getenv("POSTGRES_USER"),
-			Password: os.Getenv("POSTGRES_PASSWORD"),
-		},
-	)
-	querySvc := service.NewUserQueryService(repo)
+	// used by the code which has no request context, e.g. the background jobs
+	zap.ReplaceGlobals(logger)
 
-	redis := adapters.NewPublisher(adapters.RedisConfig{
-		Host:          getEnvString("REDIS_HOST", "redis"),
-		Port:          getEnvString("REDIS_PORT", "6379"),
-		Password:      getEnvString("REDIS_PASSWORD", ""), // todo - no password set for now
-		DB:            getEnvInt("REDIS_DB", 0),
-		EventsChannel: getEnvString("REDIS_EVENTS_CHANNEL", "events"),
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	app := newLifecycle(logger)
+
+	repoConfig := newRepoConfig(cfg)
+	tracerProvider, err := adapters.NewTracerProvider(ctx, adapters.TracingConfig{
+		ServiceName:  cfg.Tracing.ServiceName,
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
 	})
-	logger.Info("connected to Redis")
+	if err != nil {
+		logger.Fatal("failed to create tracer provider", zap.Error(err))
+	}
+	// registered first, so that it's shut down last and the spans of the shutdown are exported as well
+	app.onShutdown("tracer provider", tracerProvider.Shutdown)
 
-	commandSvcBase := service.NewUserCommandService(repo)
-	eventsLogFilePath := getEnvString("EVENTS_LOG_FILE_PATH", "../logs/events.log")
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
-	commandSvcLogging := service.NewCommandLoggingWrapper(logger, commandSvcBase)
+	var deps dependencies
+	if cfg.Dev {
+		logger.Warn("running in the dev mode, all the data is kept in memory")
+		deps = newDevDependencies()
+	} else {
+		deps = newDependencies(app, logger, registry, cfg, repoConfig)
+	}
 
-	eventsLogger := adapters.NewEventLogger(eventsLogFilePath)
-	defer eventsLogger.Close()
-	commandSvc := service.NewCommandEventsWrapper(redis, commandSvcLogging, eventsLogger)
+	usersRepo := adapters.NewRepositoryMetricsWrapper(registry, adapters.NewRepositoryTracingWrapper(deps.users))
+	var querySvcBase service.UsersQueryService = service.NewUserQueryService(usersRepo)
+	if cfg.Projection.Enabled && deps.readModel != nil {
+		querySvcBase = service.NewProjectedUserQueryService(usersRepo, deps.readModel)
+	} else if cfg.Projection.Enabled {
+		logger.Warn("the users projection is not used in the dev mode")
+	}
+	// queryCache gets the events, so that the entries changed by the commands are invalidated - the events of the
+	// commands, or of the projection when the queries read it
+	var queryCache domain.Publisher
+	if cfg.Cache.Enabled && deps.cache != nil {
+		queryCacheWrapper := service.NewQueryCacheWrapper(registry, deps.cache, querySvcBase, service.QueryCacheConfig{
+			UserTTL: cfg.Cache.UserTTL,
+			ListTTL: cfg.Cache.ListTTL,
+		})
+		querySvcBase, queryCache = queryCacheWrapper, queryCacheWrapper
+	} else if cfg.Cache.Enabled {
+		logger.Warn("the query cache is not used in the dev mode")
+	}
+	querySvc := service.NewQueryTracingWrapper(querySvcBase)
 
-	if getEnvBool("RUN_HTTP", true) {
-		go runHTTPServer(querySvc, commandSvc)
+	if cfg.Dev && cfg.DevFixtures != "" {
+		count, err := seedUsers(ctx, service.NewUserCommandService(usersRepo), cfg.DevFixtures)
+		if err != nil {
+			logger.Fatal("failed to seed the users", zap.String("fixtures", cfg.DevFixtures), zap.Error(err))
+		}
+		logger.Info("users seeded", zap.String("fixtures", cfg.DevFixtures), zap.Int("count", count))
 	}
 
-	if getEnvBool("RUN_GRPC", true) {
-		go runGRPCServer(querySvc, commandSvc)
+	healthSvc := service.NewHealthService(cfg.Health.CheckTimeout, deps.healthChecks...)
+
+	// eventStream keeps recent events in memory and streams them to the HTTP clients (GET /users/events)
+	eventStream := adapters.NewEventStream(cfg.Events.StreamHistorySize, cfg.Events.StreamBufferSize)
+
+	commandSvcBase := service.NewCommandTracingWrapper(service.NewUserCommandService(usersRepo))
+
+	commandSvcLogging := service.NewCommandLoggingWrapper(commandSvcBase)
+	commandSvcMetrics := service.NewCommandMetricsWrapper(registry, commandSvcLogging)
+
+	webhooksSvc := service.NewWebhooksService(deps.webhooks)
+	webhookPublisher := adapters.NewWebhookPublisher(deps.webhooks, adapters.WebhookConfig{
+		MaxAttempts:            cfg.Webhooks.MaxAttempts,
+		InitialBackoff:         cfg.Webhooks.InitialBackoff,
+		MaxBackoff:             cfg.Webhooks.MaxBackoff,
+		MaxConsecutiveFailures: cfg.Webhooks.MaxConsecutiveFailures,
+		Timeout:                cfg.Webhooks.Timeout,
+	})
+	app.onShutdown("webhook deliveries", webhookPublisher.Wait)
+
+	publisherMetrics := adapters.NewPublisherMetrics(registry)
+	publishers := []domain.Publisher{
+		publisherMetrics.Wrap(deps.publisherName, deps.publisher),
+		publisherMetrics.Wrap("events_stream", eventStream),
+		publisherMetrics.Wrap("webhooks", webhookPublisher),
+	}
+	if deps.projection != nil {
+		// the queries read the projection, so the query cache is invalidated once the projection has applied the events
+		var projectionEvents domain.Publisher
+		if queryCache != nil {
+			projectionEvents, queryCache = publisherMetrics.Wrap("query_cache", queryCache), nil
+		}
+		deps.projection.Start(projectionEvents)
 	}
+	if queryCache != nil {
+		publishers = append(publishers, publisherMetrics.Wrap("query_cache", queryCache))
+	}
+	commandSvcEvents := service.NewCommandEventsWrapper(adapters.NewMultiPublisher(publishers...), commandSvcMetrics, deps.eventsLog)
+	app.onShutdown("pending events", commandSvcEvents.Wait)
 
-	select {}
-}
+	// idempotency wrapper needs to be the outermost one, so that repeated requests don't publish events again
+	commandSvc := service.NewCommandIdempotencyWrapper(deps.idempotency, commandSvcEvents, service.IdempotencyConfig{
+		KeyTTL:         cfg.Idempotency.KeyTTL,
+		ReservationTTL: cfg.Idempotency.ReservationTTL,
+	})
 
-func runGRPCServer(querySvc service.UsersQueryService, commandSvc service.UsersCommandService) {
-	grpcServer := grpc.NewServer()
+	serverErrors := make(chan error, 2)
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator, err = auth.NewAuthenticator(auth.Config{
+			JWKSFile:    cfg.Auth.JWKSFile,
+			Issuer:      cfg.Auth.JWTIssuer,
+			Audience:    cfg.Auth.JWTAudience,
+			APIKeysFile: cfg.Auth.APIKeysFile,
+		})
+		if err != nil {
+			logger.Fatal("failed to create the authenticator", zap.Error(err))
+		}
+	} else {
+		logger.Warn("authorization is disabled, every caller can use the whole API")
+	}
+	var rateLimiter *ratelimit.RateLimiter
+	if cfg.RateLimit.Enabled {
+		rateLimiter = ratelimit.New(deps.rateLimits, rateLimitPolicy(cfg.RateLimit))
+	}
+	middlewares := newMiddlewares(cfg, logger, registry, authenticator, rateLimiter)
 
-	usersServer := ports_grpc.NewGRPCServer(querySvc, commandSvc)
-	users_app.RegisterUsersServer(grpcServer, usersServer)
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled() {
+		certificates, err := adapters.NewCertificates(adapters.CertificatesConfig{
+			CertFile:     cfg.TLS.CertFile,
+			KeyFile:      cfg.TLS.KeyFile,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+			ClientAuth:   clientAuthTypes[cfg.TLS.ClientAuth],
+		})
+		if err != nil {
+			logger.Fatal("failed to load the certificates", zap.Error(err))
+		}
+		go certificates.Run(ctx, cfg.TLS.ReloadInterval)
+		tlsConfig = certificates.TLSConfig()
+	}
 
-	port := getEnvString("PORT_GRPC", "50051")
-	lis, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		log.Fatalf("failed to listen on port: %v, error: %v", port, err)
+	var grpcServer *grpc.Server
+	var healthServer ports_grpc.HealthServer
+	if cfg.GRPC.Enabled {
+		healthServer = ports_grpc.NewHealthServer(healthSvc)
+		go healthServer.Run(ctx, cfg.Health.CheckInterval)
+
+		// the multiplexed calls are encrypted by the HTTP server
+		grpcTLSConfig := tlsConfig
+		if cfg.GRPC.Multiplex {
+			grpcTLSConfig = nil
+		}
+		grpcServer = newGRPCServer(middlewares, grpcTLSConfig, querySvc, commandSvc, healthSvc, healthServer)
+	}
+
+	// the HTTP server serves the gRPC calls as well when they are multiplexed, so the gRPC server is stopped after it
+	stopGRPC := func(ctx context.Context) error { return stopGRPCServer(ctx, grpcServer) }
+	if cfg.GRPC.Enabled {
+		app.onShutdown("gRPC server", func(ctx context.Context) error { return stopGRPC(ctx) })
+	}
+
+	if cfg.HTTP.Enabled {
+		httpServer, err := newHTTPServer(cfg, middlewares, registry, querySvc, commandSvc, webhooksSvc, healthSvc, eventStream)
+		if err != nil {
+			logger.Fatal("failed to create HTTP server", zap.Error(err))
+		}
+		httpServer.TLSConfig = tlsConfig
+
+		if cfg.GRPC.Multiplex || cfg.GRPC.Web {
+			mux, err := newMultiplexer(httpServer.Handler, grpcServer, cfg.GRPC.Multiplex, cfg.GRPC.Web)
+			if err != nil {
+				logger.Fatal("failed to create the multiplexer", zap.Error(err))
+			}
+			if err := serveMultiplexed(httpServer, mux); err != nil {
+				logger.Fatal("failed to configure HTTP/2", zap.Error(err))
+			}
+			stopGRPC = mux.Shutdown
+		}
+		app.onShutdown("HTTP server", httpServer.Shutdown)
+
+		go func() {
+			logger.Info("HTTP server listening", zap.String("addr", httpServer.Addr),
+				zap.Bool("tls", cfg.TLS.Enabled()), zap.Bool("grpc", cfg.GRPC.Multiplex), zap.Bool("grpc_web", cfg.GRPC.Web))
+			if err := listenAndServe(httpServer, cfg.TLS.Enabled()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- fmt.Errorf("HTTP server failed: %w", err)
+			}
+		}()
 	}
 
-	log.Printf("gRPC server listening on :%s", port)
-	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	if cfg.GRPC.Enabled {
+		// hooks run in reverse order, so the clients are told that the server is going away before it's stopped
+		app.onShutdown("gRPC health", func(context.Context) error {
+			healthServer.Shutdown()
+			return nil
+		})
 	}
+
+	if cfg.GRPC.Enabled && !cfg.GRPC.Multiplex {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPC.Port))
+		if err != nil {
+			logger.Fatal("failed to listen", zap.Int("port", cfg.GRPC.Port), zap.Error(err))
+		}
+
+		go func() {
+			logger.Info("gRPC server listening", zap.String("addr", lis.Addr().String()), zap.Bool("tls", cfg.TLS.Enabled()))
+			if err := grpcServer.Serve(lis); err != nil {
+				serverErrors <- fmt.Errorf("gRPC server failed: %w", err)
+			}
+		}()
+	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received")
+	case err := <-serverErrors:
+		logger.Error("server failed", zap.Error(err))
+		exitCode = 1
+	}
+	// restores the default signals handling, so that the second signal kills the application immediately
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := app.shutdown(shutdownCtx); err != nil {
+		exitCode = 1
+	}
+	cancel()
+
+	logger.Info("application stopped", zap.Int("exit_code", exitCode))
+	_ = logger.Sync()
+	os.Exit(exitCode)
 }
 
-func runHTTPServer(querySvc service.UsersQueryService, commandSvc service.UsersCommandService) {
-	router := chi.NewRouter()
+// printNewAPIKey generates an API key and prints it together with its entry for the API keys file,
+// the key itself is not kept anywhere
+func printNewAPIKey(w io.Writer) error {
+	key, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
 
-	httpServer := ports.NewHttpServer(querySvc, commandSvc)
-	handler := api.HandlerWithOptions(httpServer, api.ChiServerOptions{
-		BaseRouter: router,
-		Middlewares: []api.MiddlewareFunc{
-			middleware.RequestID,
-			httplog.RequestLogger(httplog.NewLogger("users-app", httplog.Options{
-				LogLevel:        getEnvString("LOG_LEVEL", "info"),
-				LevelFieldName:  "level",
-				JSON:            getEnvBool("LOG_JSON", true),
-				TimeFieldFormat: "2006-01-02T15:04:05.000Z07:00",
-				TimeFieldName:   "timestamp",
-			})),
-			middleware.Recoverer,
+	_, err = fmt.Fprintf(w, "key: %s\n\nentry for the API keys file:\n- name: \n  sha256: %s\n  scopes: [%s]\n",
+		key, auth.HashAPIKey(key), auth.ScopeRead)
+	return err
+}
+
+// newRepoConfig returns the configuration of the Postgres repositories
+func newRepoConfig(cfg config.Config) adapters.RepoConfig {
+	return adapters.RepoConfig{
+		Host:     cfg.DB.Host,
+		Database: cfg.DB.Database,
+		User:     cfg.DB.User,
+		Password: cfg.DB.Password,
+
+		ReadTimeout:      cfg.DB.ReadTimeout,
+		WriteTimeout:     cfg.DB.WriteTimeout,
+		StatementTimeout: cfg.DB.StatementTimeout,
+
+		Replicas: adapters.ReplicasConfig{
+			Hosts:         cfg.DBReplica.HostList(),
+			Database:      cfg.DBReplica.Database,
+			User:          cfg.DBReplica.User,
+			Password:      cfg.DBReplica.Password,
+			MaxLag:        cfg.DBReplica.MaxLag,
+			CheckInterval: cfg.DBReplica.CheckInterval,
 		},
+		// the queries reading their writes skip the projection
+		TrackWrites: cfg.Projection.Enabled,
+	}
+}
+
+// rebuildProjection builds the users projection from all the events again. The running instances keep serving
+// the old one until it's done, the interrupted rebuild is rolled back.
+func rebuildProjection(w io.Writer, cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	projection := adapters.NewProjection(prometheus.NewRegistry(), newRepoConfig(cfg), adapters.ProjectionConfig{
+		PollInterval: cfg.Projection.PollInterval,
+		BatchSize:    cfg.Projection.BatchSize,
 	})
+	defer projection.Close()
 
-	port := getEnvString("PORT_HTTP", "8080")
-	log.Printf("HTTP server listening on :%s", port)
-	err := http.ListenAndServe(":"+port, handler)
+	applied, err := projection.Rebuild(ctx)
 	if err != nil {
-		log.Fatalf("failed to listen and serve: %v", err)
+		return fmt.Errorf("failed to rebuild the users projection: %w", err)
 	}
+
+	_, err = fmt.Fprintf(w, "users projection rebuilt from %d events\n", applied)
+	return err
 }
 
-func getEnvString(key string, defaultValue string) string {
-	value, ok := os.LookupEnv(key)
-	if !ok {
-		return defaultValue
+// dependencies are the adapters connecting the application to the outside world
+type dependencies struct {
+	users       domain.Repository
+	webhooks    domain.WebhookRepository
+	idempotency domain.IdempotencyRepository
+	// publisher gets all the events, besides the events stream and the webhooks
+	publisher     domain.Publisher
+	publisherName string
+	eventsLog     interface{ LogEvent(domain.Event) }
+	healthChecks  []service.HealthCheck
+	// rateLimits keeps the token buckets of the rate limiting
+	rateLimits ratelimit.Limiter
+	// cache keeps the results of the queries, it's nil in the dev mode
+	cache service.UsersCache
+	// readModel is the projection of the users, it's nil in the dev mode and when the projection is disabled
+	readModel domain.UsersReadModel
+	// projection builds the read model, it's started once the publishers of its events are built
+	projection *adapters.Projection
+}
+
+// newDependencies connects to Postgres and Redis, the connections are closed on shutdown
+func newDependencies(
+	app *lifecycle,
+	logger *zap.Logger,
+	registry *prometheus.Registry,
+	cfg config.Config,
+	repoConfig adapters.RepoConfig,
+) dependencies {
+	repo := adapters.NewRepository(repoConfig)
+	app.onShutdownClose("users repository", repo.Close)
+	adapters.RegisterDBStats(registry, repo.DB())
+
+	redis := adapters.NewPublisher(adapters.RedisConfig{
+		Host:          cfg.Redis.Host,
+		Port:          strconv.Itoa(cfg.Redis.Port),
+		Password:      cfg.Redis.Password,
+		DB:            cfg.Redis.DB,
+		EventsChannel: cfg.Redis.EventsChannel,
+
+		EventsChannelPerTenant: cfg.Redis.EventsChannelPerTenant,
+	})
+	app.onShutdownClose("redis publisher", redis.Close)
+	logger.Info("connected to Redis")
+
+	// the rate limiter doesn't share the client of the publisher, its calls have a short timeout and no retries
+	redisRateLimiter := adapters.NewRedisRateLimiter(adapters.RedisConfig{
+		Host:     cfg.Redis.Host,
+		Port:     strconv.Itoa(cfg.Redis.Port),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	}, cfg.RateLimit.RedisTimeout)
+	app.onShutdownClose("redis rate limiter", redisRateLimiter.Close)
+
+	// the cache doesn't share the client either, the queries go to the repository when it's slow
+	redisCache := adapters.NewRedisCache(adapters.RedisConfig{
+		Host:     cfg.Redis.Host,
+		Port:     strconv.Itoa(cfg.Redis.Port),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	}, cfg.Cache.RedisTimeout)
+	app.onShutdownClose("redis cache", redisCache.Close)
+
+	eventsLogger := adapters.NewEventLogger(cfg.Events.LogFilePath)
+	app.onShutdownClose("events log", eventsLogger.Close)
+
+	webhookRepo := adapters.NewWebhookRepository(repoConfig)
+	app.onShutdownClose("webhooks repository", webhookRepo.Close)
+
+	idempotencyRepo := adapters.NewIdempotencyRepository(repoConfig)
+	app.onShutdownClose("idempotency repository", idempotencyRepo.Close)
+
+	var projection *adapters.Projection
+	var readModel domain.UsersReadModel
+	if cfg.Projection.Enabled {
+		projection = adapters.NewProjection(registry, repoConfig, adapters.ProjectionConfig{
+			PollInterval:    cfg.Projection.PollInterval,
+			BatchSize:       cfg.Projection.BatchSize,
+			EventsRetention: cfg.Projection.EventsRetention,
+			PruneInterval:   cfg.Projection.PruneInterval,
+		})
+		app.onShutdownClose("users projection", projection.Close)
+		readModel = adapters.NewReadModel(repo)
+	}
+
+	return dependencies{
+		users:         repo,
+		webhooks:      webhookRepo,
+		idempotency:   idempotencyRepo,
+		publisher:     redis,
+		publisherName: "redis",
+		eventsLog:     eventsLogger,
+		healthChecks: []service.HealthCheck{
+			{Name: "postgres", Check: repo.Ping},
+			{Name: "redis", Check: redis.Ping},
+		},
+		rateLimits: ratelimit.NewFallback(redisRateLimiter, ratelimit.NewMemoryLimiter(), rateLimitRetryInterval),
+		cache:      redisCache,
+		readModel:  readModel,
+		projection: projection,
+	}
+}
+
+// rateLimitRetryInterval is the interval of trying Redis again, while the rate limits are kept in memory
+const rateLimitRetryInterval = 5 * time.Second
+
+// rateLimitPolicy takes the budgets from the configuration, which is already validated
+func rateLimitPolicy(cfg config.RateLimitConfig) ratelimit.Policy {
+	routeLimits, _ := cfg.RouteLimits()
+	trustedProxies, _ := cfg.TrustedProxyPrefixes()
+	routes := make(map[string]ratelimit.Limit, len(routeLimits))
+	for route, limit := range routeLimits {
+		routes[route] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+
+	return ratelimit.Policy{
+		Read:   ratelimit.Limit{Rate: cfg.ReadRate, Burst: cfg.ReadBurst},
+		Write:  ratelimit.Limit{Rate: cfg.WriteRate, Burst: cfg.WriteBurst},
+		Routes: routes,
+
+		TrustedProxies: trustedProxies,
 	}
+}
 
-	return value
+// clientAuthTypes maps tls.client_auth to the verification of the client certificates
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":     tls.NoClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"require":  tls.RequireAndVerifyClientCert,
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
-	value, ok := os.LookupEnv(key)
-	if !ok {
-		return defaultValue
+// newGRPCServer creates the gRPC server, tlsConfig is nil for the plaintext one
+func newGRPCServer(
+	middlewares middlewares,
+	tlsConfig *tls.Config,
+	querySvc service.UsersQueryService,
+	commandSvc service.UsersCommandService,
+	healthSvc service.HealthService,
+	healthServer ports_grpc.HealthServer,
+) *grpc.Server {
+	options := middlewares.grpcServerOptions()
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
+	grpcServer := grpc.NewServer(options...)
+
+	usersServer := ports_grpc.NewGRPCServer(querySvc, commandSvc, healthSvc)
+	users_app.RegisterUsersServer(grpcServer, usersServer)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
 
-	return value == "true"
+	return grpcServer
+}
+
+// stopGRPCServer waits for the pending RPCs to finish, the server is stopped forcefully when the context is done
+func stopGRPCServer(ctx context.Context, grpcServer *grpc.Server) error {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		grpcServer.Stop()
+		return fmt.Errorf("graceful stop timed out: %w", ctx.Err())
+	}
 }
 
-func getEnvInt(s string, i int) int {
-	value, ok := os.LookupEnv(s)
-	if !ok {
-		return i
+func newHTTPServer(
+	cfg config.Config,
+	middlewares middlewares,
+	registry *prometheus.Registry,
+	querySvc service.UsersQueryService,
+	commandSvc service.UsersCommandService,
+	webhooksSvc service.WebhooksService,
+	healthSvc service.HealthService,
+	events domain.EventSubscriber,
+) (*http.Server, error) {
+	router := chi.NewRouter()
+
+	if cfg.Metrics.Enabled {
+		router.Handle(cfg.Metrics.Path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
+
+	// the events stream is not a part of the OpenAPI spec, so it's registered on the router directly
+	eventsHandler := ports.NewEventsHandler(events, cfg.Events.StreamHeartbeat)
+	router.Get("/users/events", withMiddlewares(eventsHandler, middlewares.http).ServeHTTP)
+
+	// the users API is generated from users.proto, the gateway calls the same server as the gRPC clients do
+	usersServer := ports_grpc.NewInterceptedUsersServer(
+		ports_grpc.NewGRPCServer(querySvc, commandSvc, healthSvc), middlewares.gateway,
+	)
+	gateway, err := ports.NewGateway(usersServer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the gateway: %w", err)
 	}
+	router.Mount("/v1", withMiddlewares(gateway, middlewares.http))
 
-	v, err := strconv.Atoi(strings.TrimSpace(value))
+	legacyUsers, err := ports.NewLegacyUsersHandler(usersServer)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to create the legacy users handler: %w", err)
+	}
+
+	httpServer := ports.NewHttpServer(webhooksSvc, healthSvc, legacyUsers)
+	handler := api.HandlerWithOptions(httpServer, api.ChiServerOptions{
+		BaseRouter:  router,
+		Middlewares: middlewares.http,
+	})
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.HTTP.Port),
+		Handler: handler,
+	}
+	// event streams never become idle, they need to be ended for the Shutdown to finish
+	server.RegisterOnShutdown(eventsHandler.Close)
+
+	return server, nil
+}
+
+// listenAndServe serves TLS with the certificates of the server's TLS configuration. The configuration alone
+// doesn't tell that TLS is enabled, http2.ConfigureServer sets it for the plaintext servers as well.
+func listenAndServe(server *http.Server, tls bool) error {
+	if tls {
+		return server.ListenAndServeTLS("", "")
+	}
+
+	return server.ListenAndServe()
+}
+
+// withMiddlewares wraps the handler the same way as the handlers generated from the OpenAPI spec are wrapped
+func withMiddlewares(handler http.Handler, middlewares []api.MiddlewareFunc) http.Handler {
+	for _, m := range middlewares {
+		handler = m(handler)
 	}
 
-	return v
+	return handler
 }
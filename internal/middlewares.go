@@ -0,0 +1,118 @@
+package main
+
+import (
+	"users-app/auth"
+	"users-app/config"
+	"users-app/gen/api"
+	ports_grpc "users-app/ports/grpc"
+	ports "users-app/ports/http"
+	"users-app/ratelimit"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// middlewares handle the concerns shared by all the requests (tracing, metrics, request id, access log,
+// panics recovery), they are configured here for both transports, so that the stacks don't drift apart
+type middlewares struct {
+	http       []api.MiddlewareFunc
+	grpcUnary  []grpc.UnaryServerInterceptor
+	grpcStream []grpc.StreamServerInterceptor
+	// gateway is applied to the calls of the REST gateway, which are already handled by the http middlewares
+	gateway grpc.UnaryServerInterceptor
+}
+
+// newMiddlewares configures the middlewares, authenticator is nil when the authorization is disabled
+// and rateLimiter when the rate limiting is
+func newMiddlewares(
+	cfg config.Config,
+	logger *zap.Logger,
+	registry prometheus.Registerer,
+	authenticator *auth.Authenticator,
+	rateLimiter *ratelimit.RateLimiter,
+) middlewares {
+	// every HTTP middleware wraps the previous ones, so the first one is the innermost:
+	// the span is started first, then the request id is set, so that both of them end up in the logs,
+	// the client identity is taken from the connection before the logger is created, so that it's logged as well,
+	// the callers are authorized after that, so that the rejected requests are logged,
+	// the tenant is resolved for the requests which got through,
+	// the rate is limited once the callers and their tenants are known, so that every one of them gets its own budget,
+	// the consistency token is read and returned around the handlers,
+	// the panics are recovered last, so that their responses are logged and counted as well
+	var httpMiddlewares []api.MiddlewareFunc
+	httpMiddlewares = append(httpMiddlewares,
+		middleware.Recoverer,
+		ports.NewConsistencyMiddleware(),
+	)
+	if rateLimiter != nil {
+		httpMiddlewares = append(httpMiddlewares, ports.NewRateLimitMiddleware(rateLimiter))
+	}
+	httpMiddlewares = append(httpMiddlewares, ports.NewTenantMiddleware(cfg.Tenant.Required))
+	if authenticator != nil {
+		httpMiddlewares = append(httpMiddlewares, ports.NewAuthMiddleware(authenticator))
+	}
+	httpMiddlewares = append(httpMiddlewares,
+		ports.NewLoggingMiddleware(logger),
+		ports.NewClientIdentityMiddleware(),
+		middleware.RequestID,
+		ports.NewMetricsMiddleware(registry),
+		ports.NewTracingMiddleware(),
+	)
+
+	// the interceptors are chained the other way around, the first one is the outermost.
+	// The spans are started by the stats handler (see grpcServerOptions) before any of them.
+	metrics := ports_grpc.NewMetrics(registry)
+	accessLog := ports_grpc.NewLogging(logger)
+	clientIdentity := ports_grpc.NewClientIdentity()
+	recovery := ports_grpc.NewRecovery()
+	deadline := ports_grpc.NewDeadline(cfg.GRPC.DefaultTimeout)
+	validation := ports_grpc.NewValidation()
+
+	grpcUnary := []grpc.UnaryServerInterceptor{
+		metrics.UnaryInterceptor(),
+		clientIdentity.UnaryInterceptor(),
+		accessLog.UnaryInterceptor(),
+		recovery.UnaryInterceptor(),
+	}
+	grpcStream := []grpc.StreamServerInterceptor{
+		metrics.StreamInterceptor(),
+		clientIdentity.StreamInterceptor(),
+		accessLog.StreamInterceptor(),
+		recovery.StreamInterceptor(),
+	}
+	if authenticator != nil {
+		authorization := ports_grpc.NewAuth(authenticator)
+		grpcUnary = append(grpcUnary, authorization.UnaryInterceptor())
+		grpcStream = append(grpcStream, authorization.StreamInterceptor())
+	}
+	tenant := ports_grpc.NewTenant(cfg.Tenant.Required)
+	grpcUnary = append(grpcUnary, tenant.UnaryInterceptor())
+	grpcStream = append(grpcStream, tenant.StreamInterceptor())
+	if rateLimiter != nil {
+		rateLimit := ports_grpc.NewRateLimit(rateLimiter)
+		grpcUnary = append(grpcUnary, rateLimit.UnaryInterceptor())
+		grpcStream = append(grpcStream, rateLimit.StreamInterceptor())
+	}
+	consistency := ports_grpc.NewConsistency()
+	grpcUnary = append(grpcUnary, consistency.UnaryInterceptor())
+	grpcStream = append(grpcStream, consistency.StreamInterceptor())
+
+	return middlewares{
+		http:       httpMiddlewares,
+		grpcUnary:  append(grpcUnary, deadline.UnaryInterceptor(), validation.UnaryInterceptor()),
+		grpcStream: append(grpcStream, validation.StreamInterceptor()),
+		// the gateway calls are authorized by the HTTP routes
+		gateway: validation.UnaryInterceptor(),
+	}
+}
+
+func (m middlewares) grpcServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(m.grpcUnary...),
+		grpc.ChainStreamInterceptor(m.grpcStream...),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"connectrpc.com/vanguard/vanguardgrpc"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// multiplexer serves the gRPC server on the HTTP port, the requests are dispatched on their content type:
+//   - application/grpc over HTTP/2 is passed to the gRPC server, if native is set
+//   - gRPC-Web (application/grpc-web*) and Connect (application/connect+*, or the unary calls sent with
+//     the Connect-Protocol-Version header) are translated to gRPC, if web is set
+//   - everything else goes to the HTTP handler
+//
+// The gRPC calls go through the gRPC server, so they get the same interceptors regardless of the protocol.
+type multiplexer struct {
+	http       http.Handler
+	grpcServer *grpc.Server
+	native     bool
+	// web is nil when gRPC-Web and Connect are not served
+	web http.Handler
+
+	mu      sync.Mutex
+	stopped bool
+	pending int
+	idle    chan struct{}
+}
+
+func newMultiplexer(httpHandler http.Handler, grpcServer *grpc.Server, native, web bool) (*multiplexer, error) {
+	m := &multiplexer{
+		http:       httpHandler,
+		grpcServer: grpcServer,
+		native:     native,
+		idle:       make(chan struct{}),
+	}
+
+	if web {
+		transcoder, err := vanguardgrpc.NewTranscoder(grpcServer)
+		if err != nil {
+			return nil, err
+		}
+		m.web = transcoder
+	}
+
+	return m, nil
+}
+
+// serveMultiplexed replaces the handler of the server with the multiplexer. HTTP/2 is served over cleartext (h2c)
+// as well, the gRPC clients need it - with TLS it's negotiated with ALPN.
+func serveMultiplexed(server *http.Server, m *multiplexer) error {
+	h2 := &http2.Server{}
+	// registers the graceful shutdown of the HTTP/2 connections on server.Shutdown, h2c ones included
+	if err := http2.ConfigureServer(server, h2); err != nil {
+		return err
+	}
+	server.Handler = h2c.NewHandler(m, h2)
+
+	return nil
+}
+
+func (m *multiplexer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case m.native && r.ProtoMajor == 2 && isGRPC(contentType):
+		m.serveGRPC(w, r, m.grpcServer)
+	case m.web != nil && (isGRPCWeb(contentType) || isConnect(r, contentType)):
+		m.serveGRPC(w, r, m.web)
+	default:
+		m.http.ServeHTTP(w, r)
+	}
+}
+
+func isGRPC(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc") && !isGRPCWeb(contentType)
+}
+
+func isGRPCWeb(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+func isConnect(r *http.Request, contentType string) bool {
+	return strings.HasPrefix(contentType, "application/connect+") || r.Header.Get("Connect-Protocol-Version") != ""
+}
+
+// serveGRPC counts the pending calls, the gRPC server can't be stopped gracefully while it serves them
+// (see Shutdown). The calls made after the shutdown started are answered with 503, which the clients
+// see as UNAVAILABLE.
+func (m *multiplexer) serveGRPC(w http.ResponseWriter, r *http.Request, handler http.Handler) {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	m.pending++
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.pending--
+		if m.stopped && m.pending == 0 {
+			close(m.idle)
+		}
+		m.mu.Unlock()
+	}()
+
+	handler.ServeHTTP(w, r)
+}
+
+// Shutdown waits for the pending calls served over HTTP and stops the gRPC server gracefully, grpc.Server.GracefulStop
+// doesn't support those calls. It should be called after the HTTP server is shut down, so that no new calls arrive.
+func (m *multiplexer) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	if m.pending == 0 {
+		close(m.idle)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-m.idle:
+		return stopGRPCServer(ctx, m.grpcServer)
+	case <-ctx.Done():
+		m.grpcServer.Stop()
+		return fmt.Errorf("waiting for the pending calls timed out: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"users-app/adapters"
+	users_app "users-app/gen/grpc"
+	ports_grpc "users-app/ports/grpc"
+	"users-app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+func newMultiplexedServer(t *testing.T, native, web bool) (*httptest.Server, *multiplexer) {
+	repo := adapters.NewMemoryRepository()
+	commands := service.NewUserCommandService(repo)
+	_, err := commands.AddUser(context.Background(), service.AddUserCommand{FirstName: "John", Email: "john@example.com"})
+	require.NoError(t, err)
+
+	grpcServer := newGRPCServer(
+		parityMiddlewares(), nil, service.NewUserQueryService(repo), commands, nil, ports_grpc.NewHealthServer(nil),
+	)
+	httpHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "http")
+	})
+	mux, err := newMultiplexer(httpHandler, grpcServer, native, web)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(nil)
+	require.NoError(t, serveMultiplexed(server.Config, mux))
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server, mux
+}
+
+func TestMultiplexer_grpc(t *testing.T) {
+	server, _ := newMultiplexedServer(t, true, false)
+
+	conn, err := grpc.NewClient(server.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	resp, err := users_app.NewUsersClient(conn).GetUsers(context.Background(), &users_app.GetUsersRequest{})
+
+	require.NoError(t, err)
+	require.Len(t, resp.GetUsers(), 1)
+	assert.Equal(t, "John", resp.GetUsers()[0].GetFirstName())
+}
+
+// grpcWebFrame prefixes the message the way gRPC-Web does: a flags byte and the big-endian length
+func grpcWebFrame(t *testing.T, msg proto.Message) []byte {
+	payload, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	frame := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+func TestMultiplexer(t *testing.T) {
+	getUsers := "/" + users_app.Users_ServiceDesc.ServiceName + "/GetUsers"
+
+	tests := []struct {
+		name        string
+		native, web bool
+		method      string
+		path        string
+		header      http.Header
+		body        []byte
+
+		wantStatus   int
+		wantBody     string
+		wantContains []string
+	}{
+		{
+			name:       "http",
+			native:     true,
+			web:        true,
+			method:     http.MethodGet,
+			path:       "/v1/users",
+			wantStatus: http.StatusOK,
+			wantBody:   "http",
+		},
+		{
+			name:         "connect",
+			web:          true,
+			method:       http.MethodPost,
+			path:         getUsers,
+			header:       http.Header{"Content-Type": {"application/json"}, "Connect-Protocol-Version": {"1"}},
+			body:         []byte(`{}`),
+			wantStatus:   http.StatusOK,
+			wantContains: []string{`"firstName":"John"`},
+		},
+		{
+			name:         "grpc_web",
+			web:          true,
+			method:       http.MethodPost,
+			path:         getUsers,
+			header:       http.Header{"Content-Type": {"application/grpc-web+proto"}},
+			body:         grpcWebFrame(t, &users_app.GetUsersRequest{}),
+			wantStatus:   http.StatusOK,
+			wantContains: []string{"John", "grpc-status: 0"},
+		},
+		{
+			name:       "web_disabled",
+			native:     true,
+			method:     http.MethodPost,
+			path:       getUsers,
+			header:     http.Header{"Content-Type": {"application/json"}, "Connect-Protocol-Version": {"1"}},
+			body:       []byte(`{}`),
+			wantStatus: http.StatusOK,
+			wantBody:   "http",
+		},
+		{
+			name:       "grpc_needs_http2",
+			native:     true,
+			method:     http.MethodPost,
+			path:       getUsers,
+			header:     http.Header{"Content-Type": {"application/grpc"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "http",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newMultiplexedServer(t, tt.native, tt.web)
+
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, bytes.NewReader(tt.body))
+			require.NoError(t, err)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+			for _, want := range tt.wantContains {
+				assert.Contains(t, strings.ToLower(string(body)), strings.ToLower(want))
+			}
+		})
+	}
+}
+
+func TestMultiplexer_Shutdown(t *testing.T) {
+	server, mux := newMultiplexedServer(t, true, true)
+
+	require.NoError(t, mux.Shutdown(context.Background()))
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/users.Users/GetUsers", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "calls made after the shutdown are refused")
+
+	resp, err = server.Client().Get(server.URL + "/v1/users")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "HTTP requests are not affected")
+}
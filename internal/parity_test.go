@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"users-app/adapters"
+	"users-app/config"
+	"users-app/domain"
+	"users-app/gen/api"
+	users_app "users-app/gen/grpc"
+	ports_grpc "users-app/ports/grpc"
+	"users-app/service"
+
+	"github.com/oapi-codegen/runtime/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The parity tests run the same scenarios through the HTTP and the gRPC servers, wired the same way as in main,
+// so that the transports don't drift apart: both have to return the same users and the equivalent statuses.
+
+// userView is the part of the user returned by both transports, the generated id and timestamps are left out
+type userView struct {
+	FirstName string
+	LastName  string
+	Nickname  string
+	Email     string
+	Country   string
+}
+
+// outcome is the result of a call, the HTTP transport sets the status, the gRPC transport sets the code
+type outcome struct {
+	status int
+	code   codes.Code
+	users  []userView
+}
+
+type transport interface {
+	createUser(ctx context.Context, user userView, password string) outcome
+	listUsers(ctx context.Context, filter userView, limit, offset int32) outcome
+	// modifyUser changes the fields present in the map
+	modifyUser(ctx context.Context, id string, fields map[string]string) outcome
+	deleteUser(ctx context.Context, id string) outcome
+}
+
+var (
+	alice = domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "Alice", LastName: "Smith", Nickname: "al", Email: "alice@example.com", Country: "UK"}
+	bob   = domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "Bob", LastName: "Jones", Nickname: "bj", Email: "bob@example.com", Country: "PL"}
+	carol = domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "Carol", LastName: "Smith", Nickname: "cs", Email: "carol@example.com", Country: "UK"}
+)
+
+func view(user domain.User) userView {
+	return userView{user.FirstName, user.LastName, user.Nickname, user.Email, user.Country}
+}
+
+func TestTransportParity(t *testing.T) {
+	dave := userView{"Dave", "Brown", "db", "dave@example.com", "DE"}
+	modified := func(user domain.User, modify func(*userView)) []userView {
+		ret := view(user)
+		modify(&ret)
+		return []userView{ret}
+	}
+
+	tests := []struct {
+		name string
+		// repositoryErr makes every repository call fail
+		repositoryErr error
+		call          func(ctx context.Context, tr transport) outcome
+
+		want outcome
+	}{
+		{
+			name: "create_user",
+			call: func(ctx context.Context, tr transport) outcome { return tr.createUser(ctx, dave, "secret") },
+			want: outcome{status: http.StatusCreated, users: []userView{dave}},
+		},
+		{
+			name: "create_user_without_required_fields",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.createUser(ctx, userView{Email: "dave@example.com"}, "secret")
+			},
+			want: outcome{status: http.StatusBadRequest, code: codes.InvalidArgument},
+		},
+		{
+			name: "create_user_with_taken_email",
+			call: func(ctx context.Context, tr transport) outcome {
+				taken := dave
+				taken.Email = alice.Email
+				return tr.createUser(ctx, taken, "secret")
+			},
+			want: outcome{status: http.StatusConflict, code: codes.AlreadyExists},
+		},
+		{
+			name: "list_users",
+			call: func(ctx context.Context, tr transport) outcome { return tr.listUsers(ctx, userView{}, 0, 0) },
+			want: outcome{status: http.StatusOK, users: []userView{view(alice), view(bob), view(carol)}},
+		},
+		{
+			name: "list_users_with_filters",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.listUsers(ctx, userView{LastName: "Smith", Country: "UK"}, 0, 0)
+			},
+			want: outcome{status: http.StatusOK, users: []userView{view(alice), view(carol)}},
+		},
+		{
+			name: "list_users_with_pagination",
+			call: func(ctx context.Context, tr transport) outcome { return tr.listUsers(ctx, userView{}, 1, 1) },
+			want: outcome{status: http.StatusOK, users: []userView{view(bob)}},
+		},
+		{
+			name: "list_users_with_negative_offset",
+			call: func(ctx context.Context, tr transport) outcome { return tr.listUsers(ctx, userView{}, 1, -1) },
+			want: outcome{status: http.StatusBadRequest, code: codes.InvalidArgument},
+		},
+		{
+			name: "modify_user",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.modifyUser(ctx, bob.ID.String(), map[string]string{"first_name": "Robert", "country": "UK"})
+			},
+			want: outcome{status: http.StatusOK, users: modified(bob, func(u *userView) { u.FirstName, u.Country = "Robert", "UK" })},
+		},
+		{
+			name: "modify_user_clears_field",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.modifyUser(ctx, bob.ID.String(), map[string]string{"nickname": ""})
+			},
+			want: outcome{status: http.StatusOK, users: modified(bob, func(u *userView) { u.Nickname = "" })},
+		},
+		{
+			name: "modify_user_clearing_email",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.modifyUser(ctx, bob.ID.String(), map[string]string{"email": ""})
+			},
+			want: outcome{status: http.StatusBadRequest, code: codes.InvalidArgument},
+		},
+		{
+			name: "modify_user_which_does_not_exist",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.modifyUser(ctx, domain.NewUserID().String(), map[string]string{"first_name": "Robert"})
+			},
+			want: outcome{status: http.StatusNotFound, code: codes.NotFound},
+		},
+		{
+			name: "modify_user_with_invalid_id",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.modifyUser(ctx, "1", map[string]string{"first_name": "Robert"})
+			},
+			want: outcome{status: http.StatusBadRequest, code: codes.InvalidArgument},
+		},
+		{
+			name: "delete_user",
+			call: func(ctx context.Context, tr transport) outcome { return tr.deleteUser(ctx, bob.ID.String()) },
+			want: outcome{status: http.StatusNoContent},
+		},
+		{
+			name: "delete_user_which_does_not_exist",
+			call: func(ctx context.Context, tr transport) outcome {
+				return tr.deleteUser(ctx, domain.NewUserID().String())
+			},
+			want: outcome{status: http.StatusNoContent},
+		},
+		{
+			name: "delete_user_with_invalid_id",
+			call: func(ctx context.Context, tr transport) outcome { return tr.deleteUser(ctx, "1") },
+			want: outcome{status: http.StatusBadRequest, code: codes.InvalidArgument},
+		},
+		{
+			name:          "repository_timeout",
+			repositoryErr: fmt.Errorf("query failed: %w", context.DeadlineExceeded),
+			call:          func(ctx context.Context, tr transport) outcome { return tr.listUsers(ctx, userView{}, 0, 0) },
+			want:          outcome{status: http.StatusGatewayTimeout, code: codes.DeadlineExceeded},
+		},
+		{
+			name:          "repository_failure",
+			repositoryErr: fmt.Errorf("connection refused"),
+			call:          func(ctx context.Context, tr transport) outcome { return tr.deleteUser(ctx, bob.ID.String()) },
+			want:          outcome{status: http.StatusInternalServerError, code: codes.Internal},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every transport gets its own services, so that the calls of one don't affect the other
+			stored := map[string][]domain.User{}
+			for name, newTransport := range map[string]func(*testing.T, domain.Repository) transport{
+				"http": newHTTPTransport,
+				"grpc": newGRPCTransport,
+			} {
+				repo := adapters.NewMemoryRepository(alice, bob, carol)
+				var users domain.Repository = repo
+				if tt.repositoryErr != nil {
+					users = failingRepository{tt.repositoryErr}
+				}
+
+				got := tt.call(context.Background(), newTransport(t, users))
+
+				want := tt.want
+				if name == "http" {
+					want.code = codes.OK
+				} else {
+					want.status = 0
+				}
+				assert.Equal(t, want, got, name)
+
+				stored[name], _ = repo.Users(context.Background(), domain.Filter{}, domain.Pagination{})
+			}
+			assert.Equal(t, views(stored["http"]), views(stored["grpc"]), "both transports leave the same users")
+		})
+	}
+}
+
+func views(users []domain.User) []userView {
+	ret := make([]userView, len(users))
+	for i, user := range users {
+		ret[i] = view(user)
+	}
+
+	return ret
+}
+
+// failingRepository returns the error from every call
+type failingRepository struct {
+	err error
+}
+
+func (r failingRepository) AddUser(context.Context, domain.User) error { return r.err }
+func (r failingRepository) ModifyUser(context.Context, domain.UserID, domain.Fields) (domain.User, error) {
+	return domain.User{}, r.err
+}
+func (r failingRepository) RemoveUser(context.Context, domain.UserID) error { return r.err }
+func (r failingRepository) Users(context.Context, domain.Filter, domain.Pagination) ([]domain.User, error) {
+	return nil, r.err
+}
+func (r failingRepository) CountryCounts(context.Context) ([]domain.CountryCount, error) {
+	return nil, r.err
+}
+
+func parityMiddlewares() middlewares {
+	return newMiddlewares(config.Default(), zap.NewNop(), prometheus.NewRegistry(), nil, nil)
+}
+
+type httpTransport struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+func newHTTPTransport(t *testing.T, repo domain.Repository) transport {
+	server, err := newHTTPServer(
+		config.Default(), parityMiddlewares(), prometheus.NewRegistry(),
+		service.NewUserQueryService(repo), service.NewUserCommandService(repo), nil, nil,
+		adapters.NewEventStream(1, 1),
+	)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.Handler)
+	t.Cleanup(ts.Close)
+
+	return httpTransport{t, ts}
+}
+
+func (h httpTransport) do(ctx context.Context, method, path string, body interface{}) (*http.Response, []byte) {
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(h.t, json.NewEncoder(&payload).Encode(body))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.server.URL+path, &payload)
+	require.NoError(h.t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.server.Client().Do(req)
+	require.NoError(h.t, err)
+	defer resp.Body.Close()
+
+	var ret bytes.Buffer
+	_, err = ret.ReadFrom(resp.Body)
+	require.NoError(h.t, err)
+
+	return resp, ret.Bytes()
+}
+
+// userOutcome decodes the user from the successful responses
+func (h httpTransport) userOutcome(resp *http.Response, body []byte) outcome {
+	if resp.StatusCode >= http.StatusBadRequest {
+		return outcome{status: resp.StatusCode}
+	}
+
+	var user api.User
+	require.NoError(h.t, json.Unmarshal(body, &user))
+	return outcome{status: resp.StatusCode, users: []userView{fromAPI(user)}}
+}
+
+func (h httpTransport) createUser(ctx context.Context, user userView, password string) outcome {
+	return h.userOutcome(h.do(ctx, http.MethodPost, "/users", api.PostUser{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Email:     types.Email(user.Email),
+		Country:   user.Country,
+		Password:  password,
+	}))
+}
+
+func (h httpTransport) listUsers(ctx context.Context, filter userView, limit, offset int32) outcome {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"first_name": filter.FirstName,
+		"last_name":  filter.LastName,
+		"nickname":   filter.Nickname,
+		"email":      filter.Email,
+		"country":    filter.Country,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if limit != 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+	if offset != 0 {
+		query.Set("offset", fmt.Sprint(offset))
+	}
+
+	resp, body := h.do(ctx, http.MethodGet, "/users?"+query.Encode(), nil)
+	if resp.StatusCode != http.StatusOK {
+		return outcome{status: resp.StatusCode}
+	}
+
+	var users api.Users
+	require.NoError(h.t, json.Unmarshal(body, &users))
+	ret := outcome{status: resp.StatusCode, users: []userView{}}
+	for _, user := range users.Users {
+		ret.users = append(ret.users, fromAPI(user))
+	}
+
+	return ret
+}
+
+func (h httpTransport) modifyUser(ctx context.Context, id string, fields map[string]string) outcome {
+	return h.userOutcome(h.do(ctx, http.MethodPatch, "/users/"+id, fields))
+}
+
+func (h httpTransport) deleteUser(ctx context.Context, id string) outcome {
+	resp, _ := h.do(ctx, http.MethodDelete, "/users/"+id, nil)
+	return outcome{status: resp.StatusCode}
+}
+
+func fromAPI(user api.User) userView {
+	return userView{user.FirstName, user.LastName, user.Nickname, string(user.Email), user.Country}
+}
+
+type grpcTransport struct {
+	client users_app.UsersClient
+}
+
+func newGRPCTransport(t *testing.T, repo domain.Repository) transport {
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(
+		parityMiddlewares(), nil, service.NewUserQueryService(repo), service.NewUserCommandService(repo), nil,
+		ports_grpc.NewHealthServer(nil),
+	)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return grpcTransport{users_app.NewUsersClient(conn)}
+}
+
+func userOutcome(user *users_app.User, err error) outcome {
+	if err != nil {
+		return outcome{code: status.Code(err)}
+	}
+
+	return outcome{users: []userView{fromGRPC(user)}}
+}
+
+func (g grpcTransport) createUser(ctx context.Context, user userView, password string) outcome {
+	return userOutcome(g.client.CreateUser(ctx, &users_app.CreateUserRequest{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Email:     user.Email,
+		Country:   user.Country,
+		Password:  password,
+	}))
+}
+
+func (g grpcTransport) listUsers(ctx context.Context, filter userView, limit, offset int32) outcome {
+	resp, err := g.client.GetUsers(ctx, &users_app.GetUsersRequest{
+		Filter: &users_app.Filter{
+			FirstName: filter.FirstName,
+			LastName:  filter.LastName,
+			Nickname:  filter.Nickname,
+			Email:     filter.Email,
+			Country:   filter.Country,
+		},
+		Pagination: &users_app.Pagination{Limit: limit, Offset: offset},
+	})
+	if err != nil {
+		return outcome{code: status.Code(err)}
+	}
+
+	ret := outcome{users: []userView{}}
+	for _, user := range resp.GetUsers() {
+		ret.users = append(ret.users, fromGRPC(user))
+	}
+
+	return ret
+}
+
+func (g grpcTransport) modifyUser(ctx context.Context, id string, fields map[string]string) outcome {
+	req := &users_app.ModifyUserRequest{Id: id}
+	for field, value := range fields {
+		wrapped := wrapperspb.String(value)
+		switch field {
+		case "first_name":
+			req.FirstName = wrapped
+		case "last_name":
+			req.LastName = wrapped
+		case "nickname":
+			req.Nickname = wrapped
+		case "email":
+			req.Email = wrapped
+		case "country":
+			req.Country = wrapped
+		}
+	}
+
+	return userOutcome(g.client.ModifyUser(ctx, req))
+}
+
+func (g grpcTransport) deleteUser(ctx context.Context, id string) outcome {
+	_, err := g.client.DeleteUser(ctx, &users_app.DeleteUserRequest{Id: id})
+	return outcome{code: status.Code(err)}
+}
+
+func fromGRPC(user *users_app.User) userView {
+	return userView{user.GetFirstName(), user.GetLastName(), user.GetNickname(), user.GetEmail(), user.GetCountry()}
+}
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"users-app/auth"
+	users_app "users-app/gen/grpc"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeyMetadataKey carries the API key, the JWTs are sent in the authorization metadata
+const APIKeyMetadataKey = "x-api-key"
+
+// publicMethods are served without the credentials, so that the probes don't need them
+var publicMethods = map[string]bool{
+	users_app.Users_HealthCheck_FullMethodName: true,
+	healthpb.Health_Check_FullMethodName:       true,
+	healthpb.Health_Watch_FullMethodName:       true,
+}
+
+// methodScopes are the scopes required by the methods, the same as by the HTTP routes calling them
+var methodScopes = map[string]auth.Scope{
+	users_app.Users_GetUsers_FullMethodName:         auth.ScopeRead,
+	users_app.Users_GetCountryCounts_FullMethodName: auth.ScopeRead,
+	users_app.Users_CreateUser_FullMethodName:       auth.ScopeWrite,
+	users_app.Users_ModifyUser_FullMethodName:       auth.ScopeWrite,
+	users_app.Users_DeleteUser_FullMethodName:       auth.ScopeAdmin,
+}
+
+// Auth authenticates the callers with a JWT (authorization: Bearer) or an API key (x-api-key) and checks the scope
+// required by the method, the caller is put into the context. The calls without valid credentials fail with
+// Unauthenticated, the ones without the scope with PermissionDenied, as do the calls of the methods missing
+// in methodScopes.
+type Auth struct {
+	authenticator *auth.Authenticator
+}
+
+func NewAuth(authenticator *auth.Authenticator) Auth {
+	return Auth{authenticator}
+}
+
+func (a Auth) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (a Auth) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, contextServerStream{ss, ctx})
+	}
+}
+
+func (a Auth) authorize(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	principal, err := a.authenticator.Authenticate(credentialsFromMetadata(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Info("authentication failed", zap.Error(err))
+		if errors.Is(err, auth.ErrNoCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "missing credentials")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	ctx = logging.With(auth.WithPrincipal(ctx, principal), zap.String(logging.PrincipalKey, principal.Subject))
+
+	scope, ok := methodScopes[method]
+	if !ok {
+		logging.FromContext(ctx).Error("no scope configured for the method")
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	if !principal.HasScope(scope) {
+		logging.FromContext(ctx).Info("missing scope", zap.String("scope", string(scope)))
+		return nil, status.Error(codes.PermissionDenied, "missing scope "+string(scope))
+	}
+
+	return ctx, nil
+}
+
+func credentialsFromMetadata(ctx context.Context) auth.Credentials {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	token, _ := strings.CutPrefix(first("authorization"), "Bearer ")
+	return auth.Credentials{BearerToken: token, APIKey: first(APIKeyMetadataKey)}
+}
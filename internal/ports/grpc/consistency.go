@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"users-app/domain"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ConsistencyTokenMetadataKey carries the consistency token, returned in the header after the commands and sent back
+// with the queries which need to see their writes
+const ConsistencyTokenMetadataKey = "x-consistency-token"
+
+// Consistency puts the consistency token of the call into its context, and returns the token of the writes made
+// by the unary calls in the header
+type Consistency struct{}
+
+func NewConsistency() Consistency {
+	return Consistency{}
+}
+
+func (Consistency) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, tracker := domain.TrackWrites(withConsistencyToken(ctx))
+		resp, err := handler(ctx, req)
+		if token := tracker.Token(); token != "" {
+			// it fails only when the header was already sent by the handler
+			_ = grpc.SetHeader(ctx, metadata.Pairs(ConsistencyTokenMetadataKey, string(token)))
+		}
+
+		return resp, err
+	}
+}
+
+// StreamInterceptor only reads the token, the streams don't write
+func (Consistency) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, contextServerStream{ss, withConsistencyToken(ss.Context())})
+	}
+}
+
+func withConsistencyToken(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(ConsistencyTokenMetadataKey); len(values) > 0 && values[0] != "" {
+		return domain.WithConsistencyToken(ctx, domain.ConsistencyToken(values[0]))
+	}
+
+	return ctx
+}
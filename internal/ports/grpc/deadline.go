@@ -0,0 +1,33 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Deadline sets the deadline of the unary calls made without one, so that a call can't run forever.
+// The deadline set by the client is kept, even if it's longer.
+// Streams are not limited, as they are meant to be long-lived (e.g. grpc.health.v1.Health/Watch).
+type Deadline struct {
+	timeout time.Duration
+}
+
+// NewDeadline creates the interceptor setting the timeout as the deadline, 0 disables it
+func NewDeadline(timeout time.Duration) Deadline {
+	return Deadline{timeout}
+}
+
+func (d Deadline) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := ctx.Deadline(); ok || d.timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+	users_app "users-app/gen/grpc"
+	"users-app/service"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthServer implements the standard gRPC health protocol (grpc.health.v1.Health).
+// The serving status of the whole server ("") and of the users service follows the health of the dependencies,
+// it gets updated by Run.
+type HealthServer struct {
+	*health.Server
+	healthService service.HealthService
+}
+
+func NewHealthServer(healthService service.HealthService) HealthServer {
+	return HealthServer{
+		Server:        health.NewServer(),
+		healthService: healthService,
+	}
+}
+
+// Run checks the dependencies every interval and updates the serving status, until the context is done
+func (h HealthServer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.update(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h HealthServer) update(ctx context.Context) {
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if !h.healthService.Check(ctx).Healthy() {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	// the watchers are notified only when the status changes
+	h.SetServingStatus("", servingStatus)
+	h.SetServingStatus(users_app.Users_ServiceDesc.ServiceName, servingStatus)
+}
+
+// unhealthyMessage lists the dependencies which are down, e.g. "unhealthy dependencies: redis", the errors are not revealed
+func unhealthyMessage(report service.HealthReport) string {
+	var failures []string
+	for name, dependency := range report.Dependencies {
+		if dependency.Status != service.HealthStatusUp {
+			failures = append(failures, name)
+		}
+	}
+	sort.Strings(failures)
+
+	return "unhealthy dependencies: " + strings.Join(failures, ", ")
+}
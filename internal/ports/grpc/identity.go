@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"context"
+	"users-app/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientIdentity puts the identity from the verified client certificate into the context, the calls without
+// the certificate are passed unchanged. It needs to be chained before Logging, which logs the subject of the identity.
+type ClientIdentity struct{}
+
+func NewClientIdentity() ClientIdentity {
+	return ClientIdentity{}
+}
+
+func (ClientIdentity) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withClientIdentity(ctx), req)
+	}
+}
+
+func (ClientIdentity) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, contextServerStream{ss, withClientIdentity(ss.Context())})
+	}
+}
+
+func withClientIdentity(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	// the calls multiplexed on the HTTP port get the connection state of the HTTP request
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	identity, ok := auth.VerifiedClientIdentity(&tlsInfo.State)
+	if !ok {
+		return ctx
+	}
+
+	return auth.WithClientIdentity(ctx, identity)
+}
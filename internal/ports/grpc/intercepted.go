@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"context"
+	users_app "users-app/gen/grpc"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// InterceptedUsersServer calls the wrapped server through the interceptor, the way grpc.Server does.
+// The REST gateway calls the server in-process, bypassing grpc.Server, so that's how it gets the requests
+// validated the same way as the gRPC clients do.
+type InterceptedUsersServer struct {
+	users_app.UnimplementedUsersServer
+	wrapped     users_app.UsersServer
+	interceptor grpc.UnaryServerInterceptor
+}
+
+func NewInterceptedUsersServer(wrapped users_app.UsersServer, interceptor grpc.UnaryServerInterceptor) InterceptedUsersServer {
+	return InterceptedUsersServer{wrapped: wrapped, interceptor: interceptor}
+}
+
+func (s InterceptedUsersServer) HealthCheck(ctx context.Context, in *empty.Empty) (*users_app.HealthCheckResponse, error) {
+	return intercept(ctx, s, users_app.Users_HealthCheck_FullMethodName, in, s.wrapped.HealthCheck)
+}
+
+func (s InterceptedUsersServer) GetUsers(ctx context.Context, in *users_app.GetUsersRequest) (*users_app.GetUsersResponse, error) {
+	return intercept(ctx, s, users_app.Users_GetUsers_FullMethodName, in, s.wrapped.GetUsers)
+}
+
+func (s InterceptedUsersServer) GetCountryCounts(ctx context.Context, in *empty.Empty) (*users_app.GetCountryCountsResponse, error) {
+	return intercept(ctx, s, users_app.Users_GetCountryCounts_FullMethodName, in, s.wrapped.GetCountryCounts)
+}
+
+func (s InterceptedUsersServer) CreateUser(ctx context.Context, in *users_app.CreateUserRequest) (*users_app.User, error) {
+	return intercept(ctx, s, users_app.Users_CreateUser_FullMethodName, in, s.wrapped.CreateUser)
+}
+
+func (s InterceptedUsersServer) ModifyUser(ctx context.Context, in *users_app.ModifyUserRequest) (*users_app.User, error) {
+	return intercept(ctx, s, users_app.Users_ModifyUser_FullMethodName, in, s.wrapped.ModifyUser)
+}
+
+func (s InterceptedUsersServer) DeleteUser(ctx context.Context, in *users_app.DeleteUserRequest) (*empty.Empty, error) {
+	return intercept(ctx, s, users_app.Users_DeleteUser_FullMethodName, in, s.wrapped.DeleteUser)
+}
+
+func intercept[Req, Resp any](
+	ctx context.Context, s InterceptedUsersServer, method string, in Req, handler func(context.Context, Req) (Resp, error),
+) (Resp, error) {
+	info := &grpc.UnaryServerInfo{Server: s.wrapped, FullMethod: method}
+	resp, err := s.interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return handler(ctx, req.(Req))
+	})
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+
+	return resp.(Resp), nil
+}
@@ -0,0 +1,338 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"users-app/auth"
+	"users-app/domain"
+	users_app "users-app/gen/grpc"
+	"users-app/logging"
+	"users-app/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var unaryInfo = &grpc.UnaryServerInfo{FullMethod: "/users.Users/GetUsers"}
+
+func TestRecovery_UnaryInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := logging.WithLogger(context.Background(), zap.New(core))
+
+	_, err := NewRecovery().UnaryInterceptor()(ctx, nil, unaryInfo, func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "boom", logs.All()[0].ContextMap()["panic"])
+}
+
+func TestClientIdentity_UnaryInterceptor(t *testing.T) {
+	clientCert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, DNSNames: []string{"billing.internal"}}
+	tests := []struct {
+		name         string
+		authInfo     credentials.AuthInfo
+		wantIdentity bool
+	}{
+		{
+			name:         "verified_certificate",
+			authInfo:     credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{clientCert}}}},
+			wantIdentity: true,
+		},
+		{
+			name:     "no_client_certificate",
+			authInfo: credentials.TLSInfo{State: tls.ConnectionState{}},
+		},
+		{
+			name: "plaintext",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			accessLog := NewLogging(zap.New(core)).UnaryInterceptor()
+			ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tt.authInfo})
+
+			_, err := NewClientIdentity().UnaryInterceptor()(ctx, nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				identity, ok := auth.ClientIdentityFromContext(ctx)
+				assert.Equal(t, tt.wantIdentity, ok)
+				if tt.wantIdentity {
+					assert.Equal(t, auth.ClientIdentity{
+						Subject: "CN=billing", CommonName: "billing", DNSNames: []string{"billing.internal"}, URIs: []string{},
+					}, identity)
+				}
+				return accessLog(ctx, req, unaryInfo, func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+			})
+
+			require.NoError(t, err)
+			require.Equal(t, 1, logs.Len())
+			_, logged := logs.All()[0].ContextMap()[logging.ClientKey]
+			assert.Equal(t, tt.wantIdentity, logged, "the access log has the client")
+		})
+	}
+}
+
+func TestAuth_UnaryInterceptor(t *testing.T) {
+	apiKeys := "- name: reader\n  sha256: " + auth.HashAPIKey("reader-key") + "\n  scopes: [users:read]\n"
+	path := filepath.Join(t.TempDir(), "api_keys.yml")
+	require.NoError(t, os.WriteFile(path, []byte(apiKeys), 0o600))
+	authenticator, err := auth.NewAuthenticator(auth.Config{APIKeysFile: path})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		method   string
+		metadata metadata.MD
+
+		wantCode      codes.Code
+		wantPrincipal string
+	}{
+		{name: "public_method", method: users_app.Users_HealthCheck_FullMethodName, wantCode: codes.OK},
+		{name: "no_credentials", method: users_app.Users_GetUsers_FullMethodName, wantCode: codes.Unauthenticated},
+		{
+			name:     "invalid_api_key",
+			method:   users_app.Users_GetUsers_FullMethodName,
+			metadata: metadata.Pairs(APIKeyMetadataKey, "other-key"),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:          "scope_granted",
+			method:        users_app.Users_GetUsers_FullMethodName,
+			metadata:      metadata.Pairs(APIKeyMetadataKey, "reader-key"),
+			wantCode:      codes.OK,
+			wantPrincipal: "reader",
+		},
+		{
+			name:     "scope_missing",
+			method:   users_app.Users_DeleteUser_FullMethodName,
+			metadata: metadata.Pairs(APIKeyMetadataKey, "reader-key"),
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "method_without_scope",
+			method:   "/users.Users/Unknown",
+			metadata: metadata.Pairs(APIKeyMetadataKey, "reader-key"),
+			wantCode: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.metadata)
+			var principal string
+
+			_, err := NewAuth(authenticator).UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, _ interface{}) (interface{}, error) {
+					if got, ok := auth.PrincipalFromContext(ctx); ok {
+						principal = got.Subject
+					}
+					return nil, nil
+				})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantPrincipal, principal)
+		})
+	}
+}
+
+func TestRateLimit_UnaryInterceptor(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryLimiter(), ratelimit.Policy{
+		Read:  ratelimit.Limit{Rate: 0.5, Burst: 1},
+		Write: ratelimit.Limit{Rate: 0.5, Burst: 1},
+	})
+	interceptor := NewRateLimit(limiter).UnaryInterceptor()
+	call := func(method, addr string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 1234}})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	require.NoError(t, call(users_app.Users_GetUsers_FullMethodName, "10.0.0.1"))
+	err := call(users_app.Users_GetUsers_FullMethodName, "10.0.0.1")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Len(t, status.Convert(err).Details(), 1)
+	retryDelay := status.Convert(err).Details()[0].(*errdetails.RetryInfo).GetRetryDelay().AsDuration()
+	assert.InDelta(t, 2, retryDelay.Seconds(), 0.1)
+
+	assert.NoError(t, call(users_app.Users_DeleteUser_FullMethodName, "10.0.0.1"), "the write budget is separate")
+	assert.NoError(t, call(users_app.Users_GetUsers_FullMethodName, "10.0.0.2"), "every caller has its own budget")
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, call(users_app.Users_HealthCheck_FullMethodName, "10.0.0.1"), "the health checks are not limited")
+	}
+}
+
+func TestTenant_UnaryInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		method   string
+		tenant   string
+
+		wantCode   codes.Code
+		wantTenant domain.TenantID
+	}{
+		{name: "tenant", method: users_app.Users_GetUsers_FullMethodName, tenant: "arcade", wantCode: codes.OK, wantTenant: "arcade"},
+		{name: "default_tenant", method: users_app.Users_GetUsers_FullMethodName, wantCode: codes.OK, wantTenant: domain.DefaultTenant},
+		{name: "invalid_tenant", method: users_app.Users_GetUsers_FullMethodName, tenant: "Arcade!", wantCode: codes.InvalidArgument},
+		{name: "missing_tenant", required: true, method: users_app.Users_GetUsers_FullMethodName, wantCode: codes.InvalidArgument},
+		{name: "public_method", required: true, method: users_app.Users_HealthCheck_FullMethodName, wantCode: codes.OK, wantTenant: domain.DefaultTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(TenantMetadataKey, tt.tenant))
+			}
+			var tenant domain.TenantID
+
+			_, err := NewTenant(tt.required).UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				tenant = domain.TenantFromContext(ctx)
+				return nil, nil
+			})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantTenant, tenant)
+		})
+	}
+}
+
+// serverTransportStreamStub keeps the header set by the handlers
+type serverTransportStreamStub struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *serverTransportStreamStub) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestConsistency_UnaryInterceptor(t *testing.T) {
+	stream := &serverTransportStreamStub{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ConsistencyTokenMetadataKey, "0/30"))
+	var readToken domain.ConsistencyToken
+
+	_, err := NewConsistency().UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: users_app.Users_ModifyUser_FullMethodName}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		readToken = domain.ConsistencyTokenFromContext(ctx)
+		tracker, ok := domain.WriteTrackerFromContext(ctx)
+		require.True(t, ok)
+		tracker.Record("0/40")
+		return nil, nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.ConsistencyToken("0/30"), readToken)
+	assert.Equal(t, []string{"0/40"}, stream.header.Get(ConsistencyTokenMetadataKey))
+}
+
+func TestDeadline_UnaryInterceptor(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		clientDeadline time.Duration
+
+		wantDeadline time.Duration
+	}{
+		{name: "default_deadline_is_set", timeout: time.Second, wantDeadline: time.Second},
+		{name: "client_deadline_is_kept", timeout: time.Second, clientDeadline: time.Minute, wantDeadline: time.Minute},
+		{name: "disabled", timeout: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.clientDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientDeadline)
+				defer cancel()
+			}
+
+			_, _ = NewDeadline(tt.timeout).UnaryInterceptor()(ctx, nil, unaryInfo, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				deadline, ok := ctx.Deadline()
+				assert.Equal(t, tt.wantDeadline > 0, ok)
+				if ok {
+					assert.WithinDuration(t, time.Now().Add(tt.wantDeadline), deadline, 100*time.Millisecond)
+				}
+				return nil, nil
+			})
+		})
+	}
+}
+
+func TestValidation_UnaryInterceptor(t *testing.T) {
+	userID := domain.NewUserID().String()
+	mask := func(paths ...string) *fieldmaskpb.FieldMask { return &fieldmaskpb.FieldMask{Paths: paths} }
+	validUser := &users_app.CreateUserRequest{
+		FirstName: "John", LastName: "Doe", Nickname: "jd", Email: "john@doe.com", Country: "UK", Password: "secret",
+	}
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		wantErr string
+	}{
+		{name: "valid_create", req: validUser},
+		{name: "create_without_fields", req: &users_app.CreateUserRequest{Email: "john@doe.com"}, wantErr: "first_name is required"},
+		{name: "negative_pagination", req: &users_app.GetUsersRequest{Pagination: &users_app.Pagination{Offset: -1}}, wantErr: "pagination.offset must not be negative"},
+		{name: "no_pagination", req: &users_app.GetUsersRequest{}},
+		{name: "invalid_id", req: &users_app.DeleteUserRequest{Id: "1"}, wantErr: "invalid user id"},
+		{
+			name: "valid_modify",
+			req:  &users_app.ModifyUserRequest{Id: userID, Nickname: wrapperspb.String(""), UpdateMask: mask("nickname")},
+		},
+		{
+			name:    "unknown_mask_path",
+			req:     &users_app.ModifyUserRequest{Id: userID, UpdateMask: mask("first_name", "password")},
+			wantErr: `update_mask: unknown field "password"`,
+		},
+		{
+			name:    "email_cleared_with_mask",
+			req:     &users_app.ModifyUserRequest{Id: userID, UpdateMask: mask("email")},
+			wantErr: "email is required",
+		},
+		{
+			name:    "email_cleared_without_mask",
+			req:     &users_app.ModifyUserRequest{Id: userID, Email: wrapperspb.String("")},
+			wantErr: "email is required",
+		},
+		{name: "other_requests_are_accepted", req: "request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			_, err := NewValidation().UnaryInterceptor()(context.Background(), tt.req, unaryInfo, func(context.Context, interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.True(t, called)
+				return
+			}
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Contains(t, status.Convert(err).Message(), tt.wantErr)
+			assert.False(t, called, "invalid requests don't reach the handler")
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"context"
+	"time"
+	"users-app/auth"
+	"users-app/logging"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadataKey is used to pass the request id between the services, it's returned in the response header
+const RequestIDMetadataKey = "x-request-id"
+
+// Logging puts the logger, enriched with the call details (and the client identity, see ClientIdentity),
+// into the context and logs every handled call.
+// The request id is taken from the x-request-id metadata, a new one is generated when it's missing.
+type Logging struct {
+	logger *zap.Logger
+}
+
+func NewLogging(logger *zap.Logger) Logging {
+	return Logging{logger}
+}
+
+func (l Logging) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = l.withLogger(ctx, info.FullMethod)
+
+		resp, err := handler(ctx, req)
+		logHandled(ctx, start, err)
+
+		return resp, err
+	}
+}
+
+func (l Logging) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := l.withLogger(ss.Context(), info.FullMethod)
+
+		err := handler(srv, contextServerStream{ss, ctx})
+		logHandled(ctx, start, err)
+
+		return err
+	}
+}
+
+func (l Logging) withLogger(ctx context.Context, method string) context.Context {
+	requestID := requestIDFromMetadata(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+
+	fields := []zap.Field{
+		zap.String(logging.RequestIDKey, requestID),
+		zap.String(logging.TransportKey, "grpc"),
+		zap.String(logging.MethodKey, method),
+	}
+	if identity, ok := auth.ClientIdentityFromContext(ctx); ok {
+		fields = append(fields, zap.String(logging.ClientKey, identity.Subject))
+	}
+
+	return logging.WithLogger(ctx, l.logger.With(fields...))
+}
+
+func requestIDFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(RequestIDMetadataKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+
+	return uuid.NewString()
+}
+
+func logHandled(ctx context.Context, start time.Time, err error) {
+	fields := []zap.Field{
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	logging.FromContext(ctx).Info("call handled", fields...)
+}
+
+// contextServerStream replaces the context of the stream
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -1,21 +1,23 @@
 package grpc
 
 import (
+	"context"
 	"users-app/domain"
 	users_app "users-app/gen/grpc"
 	"users-app/service"
 
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func parseFilter(in *users_app.Filter) domain.Filter {
-	return domain.NewFilter(in.GetFirstName(), in.GetLastName(), in.GetNickname(), in.GetEmail(), in.GetCountry())
+	return domain.NewFilter(in.GetFirstName(), in.GetLastName(), in.GetNickname(), in.GetEmail(), in.GetCountry()).
+		WithSearch(in.GetSearch())
 }
 
 func parsePagination(pagination *users_app.Pagination) domain.Pagination {
-	domain.NewPagination(int(pagination.GetLimit()), int(pagination.GetOffset()))
-
-	return domain.Pagination{}
+	return domain.NewPagination(int(pagination.GetLimit()), int(pagination.GetOffset()))
 }
 
 func toGRPCUserResponse(user domain.User) *users_app.User {
@@ -31,26 +33,79 @@ func toGRPCUserResponse(user domain.User) *users_app.User {
 	}
 }
 
-func modifyCommand(id domain.UserID, in *users_app.ModifyUserRequest) service.ModifyUserCommand {
-	ret := service.ModifyUserCommand{}
-	ret.ID = id
-	if in.FirstName != "" {
-		ret.FirstName = stringPTR(in.FirstName)
+// modifiableField binds an update mask path to the request field and the command field it sets
+type modifiableField struct {
+	value   func(*users_app.ModifyUserRequest) *wrapperspb.StringValue
+	command func(*service.ModifyUserCommand) **string
+}
+
+// modifiableFields are the fields which can be listed in the update mask of ModifyUserRequest
+var modifiableFields = map[string]modifiableField{
+	"first_name": {
+		value:   (*users_app.ModifyUserRequest).GetFirstName,
+		command: func(c *service.ModifyUserCommand) **string { return &c.FirstName },
+	},
+	"last_name": {
+		value:   (*users_app.ModifyUserRequest).GetLastName,
+		command: func(c *service.ModifyUserCommand) **string { return &c.LastName },
+	},
+	"nickname": {
+		value:   (*users_app.ModifyUserRequest).GetNickname,
+		command: func(c *service.ModifyUserCommand) **string { return &c.Nickname },
+	},
+	"email": {
+		value:   (*users_app.ModifyUserRequest).GetEmail,
+		command: func(c *service.ModifyUserCommand) **string { return &c.Email },
+	},
+	"country": {
+		value:   (*users_app.ModifyUserRequest).GetCountry,
+		command: func(c *service.ModifyUserCommand) **string { return &c.Country },
+	},
+}
+
+// updatePaths returns the paths of the update mask, without the mask the paths of the fields which are set
+func updatePaths(in *users_app.ModifyUserRequest) []string {
+	if in.GetUpdateMask() != nil {
+		return in.GetUpdateMask().GetPaths()
 	}
-	if in.LastName != "" {
-		ret.LastName = stringPTR(in.LastName)
+
+	var ret []string
+	for path, field := range modifiableFields {
+		if field.value(in) != nil {
+			ret = append(ret, path)
+		}
 	}
-	if in.Nickname != "" {
-		ret.Nickname = stringPTR(in.Nickname)
+
+	return ret
+}
+
+// modifyCommand changes the fields from the update mask, the fields in the mask which aren't set are cleared.
+// The paths are expected to be validated already, unknown paths are ignored.
+func modifyCommand(id domain.UserID, in *users_app.ModifyUserRequest) service.ModifyUserCommand {
+	ret := service.ModifyUserCommand{ID: id}
+	for _, path := range updatePaths(in) {
+		if field, ok := modifiableFields[path]; ok {
+			*field.command(&ret) = stringPTR(field.value(in).GetValue())
+		}
 	}
-	if in.Email != "" {
-		ret.Email = stringPTR(in.Email)
+
+	return ret
+}
+
+// idempotencyKeyMetadata is an alternative to the idempotency_key field, for clients setting it in an interceptor
+const idempotencyKeyMetadata = "idempotency-key"
+
+// idempotencyKey returns the idempotency key of the request, the field takes precedence over the metadata
+func idempotencyKey(ctx context.Context, in *users_app.CreateUserRequest) string {
+	if in.GetIdempotencyKey() != "" {
+		return in.GetIdempotencyKey()
 	}
-	if in.Country != "" {
-		ret.Country = stringPTR(in.Country)
+
+	if values := metadata.ValueFromIncomingContext(ctx, idempotencyKeyMetadata); len(values) > 0 {
+		return values[0]
 	}
 
-	return ret
+	return ""
 }
 
 func getUsersResponse(users []domain.User) *users_app.GetUsersResponse {
@@ -65,6 +120,15 @@ func getUsersResponse(users []domain.User) *users_app.GetUsersResponse {
 	return ret
 }
 
+func getCountryCountsResponse(counts []domain.CountryCount) *users_app.GetCountryCountsResponse {
+	ret := &users_app.GetCountryCountsResponse{Countries: make([]*users_app.CountryCount, len(counts))}
+	for i, count := range counts {
+		ret.Countries[i] = &users_app.CountryCount{Country: count.Country, Users: int32(count.Users)}
+	}
+
+	return ret
+}
+
 func stringPTR(s string) *string {
 	return &s
 }
@@ -0,0 +1,62 @@
+package grpc
+
+import (
+	"testing"
+	"users-app/domain"
+	users_app "users-app/gen/grpc"
+	"users-app/service"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestModifyCommand(t *testing.T) {
+	id := domain.NewUserID()
+	mask := func(paths ...string) *fieldmaskpb.FieldMask { return &fieldmaskpb.FieldMask{Paths: paths} }
+
+	tests := []struct {
+		name string
+		in   *users_app.ModifyUserRequest
+
+		expected service.ModifyUserCommand
+	}{
+		{
+			name:     "nothing_set",
+			in:       &users_app.ModifyUserRequest{},
+			expected: service.ModifyUserCommand{ID: id},
+		},
+		{
+			name: "without_mask_set_fields_are_changed",
+			in: &users_app.ModifyUserRequest{
+				FirstName: wrapperspb.String("Alex"),
+				Nickname:  wrapperspb.String(""),
+			},
+			expected: service.ModifyUserCommand{ID: id, FirstName: stringPTR("Alex"), Nickname: stringPTR("")},
+		},
+		{
+			name: "only_masked_fields_are_changed",
+			in: &users_app.ModifyUserRequest{
+				FirstName:  wrapperspb.String("Alex"),
+				Country:    wrapperspb.String("UK"),
+				UpdateMask: mask("country"),
+			},
+			expected: service.ModifyUserCommand{ID: id, Country: stringPTR("UK")},
+		},
+		{
+			name:     "masked_field_which_is_not_set_is_cleared",
+			in:       &users_app.ModifyUserRequest{UpdateMask: mask("last_name", "nickname")},
+			expected: service.ModifyUserCommand{ID: id, LastName: stringPTR(""), Nickname: stringPTR("")},
+		},
+		{
+			name:     "empty_mask_changes_nothing",
+			in:       &users_app.ModifyUserRequest{Email: wrapperspb.String("new@email.com"), UpdateMask: mask()},
+			expected: service.ModifyUserCommand{ID: id},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, modifyCommand(id, tt.in))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"users-app/auth"
+	"users-app/logging"
+	"users-app/ratelimit"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimit limits the rate of the calls of every caller, the calls over the limit fail with ResourceExhausted
+// carrying the RetryInfo detail. The methods reading the users take the tokens of the read budget, the others
+// of the write one. The health checks are not limited, and neither is anything when the limiter fails.
+// It needs to be chained after Auth, so that the callers are told apart by their credentials.
+type RateLimit struct {
+	limiter *ratelimit.RateLimiter
+}
+
+func NewRateLimit(limiter *ratelimit.RateLimiter) RateLimit {
+	return RateLimit{limiter}
+}
+
+func (l RateLimit) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (l RateLimit) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func (l RateLimit) allow(ctx context.Context, method string) error {
+	if publicMethods[method] {
+		return nil
+	}
+
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	result, err := l.limiter.Allow(ctx, method, methodScopes[method] != auth.ScopeRead, remoteAddr,
+		metadata.ValueFromIncomingContext(ctx, "x-forwarded-for"))
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to check the rate limit", zap.Error(err))
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return st.Err()
+}
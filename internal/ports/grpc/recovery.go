@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"context"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recovery turns the panics of the handlers into Internal errors, instead of letting them kill the process.
+// The panic is logged together with the stack trace.
+type Recovery struct{}
+
+func NewRecovery() Recovery {
+	return Recovery{}
+}
+
+func (Recovery) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, p)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func (Recovery) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), p)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, p interface{}) error {
+	logging.FromContext(ctx).Error("panic recovered", zap.Any("panic", p), zap.Stack("stack"))
+	return status.Error(codes.Internal, "internal server error")
+}
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"users-app/domain"
 	"users-app/gen/grpc"
 	"users-app/service"
@@ -15,57 +16,78 @@ type UsersServer struct {
 	users_app.UnimplementedUsersServer
 	queryService   service.UsersQueryService
 	commandService service.UsersCommandService
+	healthService  service.HealthService
 }
 
-func NewGRPCServer(queryService service.UsersQueryService, commandService service.UsersCommandService) *UsersServer {
+func NewGRPCServer(
+	queryService service.UsersQueryService,
+	commandService service.UsersCommandService,
+	healthService service.HealthService,
+) *UsersServer {
 	return &UsersServer{
 		queryService:   queryService,
 		commandService: commandService,
+		healthService:  healthService,
 	}
 }
 
+// HealthCheck is kept for the existing clients, grpc.health.v1.Health should be used instead
 func (s *UsersServer) HealthCheck(ctx context.Context, in *empty.Empty) (*users_app.HealthCheckResponse, error) {
+	report := s.healthService.Check(ctx)
+	if !report.Healthy() {
+		return nil, status.Error(codes.Unavailable, unhealthyMessage(report))
+	}
+
 	return &users_app.HealthCheckResponse{Status: "OK"}, nil
 }
 
 func (s *UsersServer) GetUsers(ctx context.Context, in *users_app.GetUsersRequest) (*users_app.GetUsersResponse, error) {
 	users, err := s.queryService.Users(ctx, parseFilter(in.GetFilter()), parsePagination(in.GetPagination()))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "internal server error")
+		return nil, unexpectedError(err)
 	}
 
 	return getUsersResponse(users), nil
 }
 
+func (s *UsersServer) GetCountryCounts(ctx context.Context, _ *empty.Empty) (*users_app.GetCountryCountsResponse, error) {
+	counts, err := s.queryService.CountryCounts(ctx)
+	if err != nil {
+		return nil, unexpectedError(err)
+	}
+
+	return getCountryCountsResponse(counts), nil
+}
+
 func (s *UsersServer) CreateUser(ctx context.Context, in *users_app.CreateUserRequest) (*users_app.User, error) {
 	user, err := s.commandService.AddUser(ctx, service.AddUserCommand{
-		FirstName: in.GetFirstName(),
-		LastName:  in.GetLastName(),
-		Nickname:  in.GetNickname(),
-		Password:  in.GetPassword(),
-		Email:     in.GetEmail(),
-		Country:   in.GetCountry(),
+		FirstName:      in.GetFirstName(),
+		LastName:       in.GetLastName(),
+		Nickname:       in.GetNickname(),
+		Password:       in.GetPassword(),
+		Email:          in.GetEmail(),
+		Country:        in.GetCountry(),
+		IdempotencyKey: idempotencyKey(ctx, in),
 	})
-
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "internal server error")
+		return nil, userError(err)
 	}
 
 	return toGRPCUserResponse(user), nil
 }
 
-func (s *UsersServer) ModifyUser(ctx context.Context, in *users_app.ModifyUserRequest) (*users_app.ModifyUserResponse, error) {
+func (s *UsersServer) ModifyUser(ctx context.Context, in *users_app.ModifyUserRequest) (*users_app.User, error) {
 	id, err := domain.ParseID(in.GetId())
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid user id")
 	}
 
-	err = s.commandService.ModifyUser(ctx, modifyCommand(id, in))
+	user, err := s.commandService.ModifyUser(ctx, modifyCommand(id, in))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "internal server error")
+		return nil, userError(err)
 	}
 
-	return &users_app.ModifyUserResponse{Status: "OK"}, nil
+	return toGRPCUserResponse(user), nil
 }
 
 func (s *UsersServer) DeleteUser(ctx context.Context, in *users_app.DeleteUserRequest) (*empty.Empty, error) {
@@ -74,10 +96,41 @@ func (s *UsersServer) DeleteUser(ctx context.Context, in *users_app.DeleteUserRe
 		return nil, status.Errorf(codes.InvalidArgument, "invalid user id")
 	}
 
-	err = s.commandService.DeleteUser(ctx, service.DeleteUserCommand{id})
+	err = s.commandService.DeleteUser(ctx, service.DeleteUserCommand{ID: id})
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "internal server error")
+		return nil, userError(err)
 	}
 
 	return &empty.Empty{}, nil
 }
+
+// userError translates the users domain errors to the status, the same way as the HTTP server does
+func userError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrEmailRequired), errors.Is(err, domain.ErrInvalidIdempotencyKey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrEmailExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrIdempotencyKeyMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrIdempotencyKeyInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	default:
+		return unexpectedError(err)
+	}
+}
+
+// unexpectedError translates the errors the clients can't do anything about to the status,
+// DeadlineExceeded when the request ran out of time (e.g. the database query timed out), Internal otherwise
+func unexpectedError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
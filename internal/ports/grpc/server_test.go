@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"users-app/adapters"
+	"users-app/domain"
+	users_app "users-app/gen/grpc"
+	"users-app/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestUsersServer_ModifyUser(t *testing.T) {
+	user := domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "John", Nickname: "jd", Email: "john@doe.com", Country: "UK"}
+	repo := adapters.NewMemoryRepository(user)
+	server := NewGRPCServer(service.NewUserQueryService(repo), service.NewUserCommandService(repo), nil)
+
+	modified, err := server.ModifyUser(context.Background(), &users_app.ModifyUserRequest{
+		Id:         user.ID.String(),
+		FirstName:  wrapperspb.String("Alex"),
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name", "nickname"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), modified.GetId())
+	assert.Equal(t, "Alex", modified.GetFirstName())
+	assert.Empty(t, modified.GetNickname(), "the nickname from the mask is cleared")
+	assert.Equal(t, "UK", modified.GetCountry(), "the fields out of the mask are kept")
+
+	_, err = server.ModifyUser(context.Background(), &users_app.ModifyUserRequest{Id: domain.NewUserID().String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUsersServer_CreateUser_invalid_idempotency_key(t *testing.T) {
+	repo := adapters.NewMemoryRepository()
+	commands := service.NewCommandIdempotencyWrapper(adapters.NewMemoryIdempotencyRepository(), service.NewUserCommandService(repo),
+		service.IdempotencyConfig{KeyTTL: time.Hour, ReservationTTL: time.Minute})
+	server := NewGRPCServer(service.NewUserQueryService(repo), commands, nil)
+
+	_, err := server.CreateUser(context.Background(), &users_app.CreateUserRequest{
+		Email:          "john@doe.com",
+		Password:       "secret",
+		IdempotencyKey: strings.Repeat("k", 256),
+	})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUsersServer_GetUsers_search(t *testing.T) {
+	repo := adapters.NewMemoryRepository(
+		domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "John", LastName: "Doe", Email: "john@doe.com", Country: "UK"},
+		domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "Jane", LastName: "Doe", Email: "jane@doe.com", Country: "PL"},
+	)
+	server := NewGRPCServer(service.NewUserQueryService(repo), service.NewUserCommandService(repo), nil)
+
+	resp, err := server.GetUsers(context.Background(), &users_app.GetUsersRequest{Filter: &users_app.Filter{Search: "doe jane"}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetUsers(), 1)
+	assert.Equal(t, "Jane", resp.GetUsers()[0].GetFirstName())
+
+	resp, err = server.GetUsers(context.Background(), &users_app.GetUsersRequest{Filter: &users_app.Filter{Email: "JOHN@doe.com"}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetUsers(), 1, "the email is matched case-insensitively")
+	assert.Equal(t, "John", resp.GetUsers()[0].GetFirstName())
+}
+
+func TestUsersServer_GetCountryCounts(t *testing.T) {
+	repo := adapters.NewMemoryRepository(
+		domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, Email: "john@doe.com", Country: "UK"},
+		domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, Email: "jane@doe.com", Country: "PL"},
+		domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, Email: "jim@doe.com", Country: "UK"},
+		domain.User{ID: domain.NewUserID(), TenantID: "arcade", Email: "john@doe.com", Country: "DE"},
+	)
+	server := NewGRPCServer(service.NewUserQueryService(repo), service.NewUserCommandService(repo), nil)
+
+	resp, err := server.GetCountryCounts(context.Background(), &emptypb.Empty{})
+
+	require.NoError(t, err)
+	assert.Equal(t, []*users_app.CountryCount{{Country: "PL", Users: 1}, {Country: "UK", Users: 2}}, resp.GetCountries())
+}
@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"context"
+	"users-app/domain"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantMetadataKey names the tenant the call is made for
+const TenantMetadataKey = "x-tenant-id"
+
+// Tenant puts the tenant named by the x-tenant-id metadata into the context. The calls without the metadata are made
+// for domain.DefaultTenant, unless it's required - they fail with InvalidArgument then, the same as the ones naming
+// an invalid tenant. The health checks don't need the tenant.
+type Tenant struct {
+	required bool
+}
+
+func NewTenant(required bool) Tenant {
+	return Tenant{required}
+}
+
+func (t Tenant) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := t.withTenant(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (t Tenant) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := t.withTenant(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, contextServerStream{ss, ctx})
+	}
+}
+
+func (t Tenant) withTenant(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	tenant := domain.DefaultTenant
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(TenantMetadataKey); len(values) > 0 {
+		var err error
+		if tenant, err = domain.ParseTenantID(values[0]); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid "+TenantMetadataKey)
+		}
+	} else if t.required {
+		return nil, status.Error(codes.InvalidArgument, "missing "+TenantMetadataKey)
+	}
+
+	return logging.With(domain.WithTenant(ctx, tenant), zap.String(logging.TenantKey, string(tenant))), nil
+}
@@ -0,0 +1,115 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"users-app/domain"
+	users_app "users-app/gen/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Validation rejects the invalid requests with InvalidArgument, before they reach the handlers.
+// The rules follow the HTTP API (see users.yml), so that both transports accept the same requests.
+type Validation struct{}
+
+func NewValidation() Validation {
+	return Validation{}
+}
+
+func (Validation) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validateRequest(req); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (Validation) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, validatingServerStream{ss})
+	}
+}
+
+// validatingServerStream validates every message received from the client
+type validatingServerStream struct {
+	grpc.ServerStream
+}
+
+func (s validatingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if err := validateRequest(m); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return nil
+}
+
+// validateRequest checks the requests of the users service, the other requests are accepted as they are
+func validateRequest(req interface{}) error {
+	switch req := req.(type) {
+	case *users_app.GetUsersRequest:
+		return errors.Join(
+			check(req.GetPagination().GetLimit() >= 0, "pagination.limit must not be negative"),
+			check(req.GetPagination().GetOffset() >= 0, "pagination.offset must not be negative"),
+		)
+	case *users_app.CreateUserRequest:
+		return errors.Join(
+			required("first_name", req.GetFirstName()),
+			required("last_name", req.GetLastName()),
+			required("nickname", req.GetNickname()),
+			required("email", req.GetEmail()),
+			required("country", req.GetCountry()),
+			required("password", req.GetPassword()),
+		)
+	case *users_app.ModifyUserRequest:
+		return errors.Join(validID(req.GetId()), validUpdateMask(req))
+	case *users_app.DeleteUserRequest:
+		return validID(req.GetId())
+	default:
+		return nil
+	}
+}
+
+func check(ok bool, message string) error {
+	if ok {
+		return nil
+	}
+
+	return errors.New(message)
+}
+
+func required(field, value string) error {
+	return check(value != "", fmt.Sprintf("%s is required", field))
+}
+
+// validUpdateMask accepts only the modifiable fields in the mask, the email is required so it can't be cleared
+func validUpdateMask(req *users_app.ModifyUserRequest) error {
+	var errs []error
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if _, ok := modifiableFields[path]; !ok {
+			errs = append(errs, fmt.Errorf("update_mask: unknown field %q", path))
+		}
+	}
+	if slices.Contains(updatePaths(req), "email") {
+		errs = append(errs, required("email", req.GetEmail().GetValue()))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validID(id string) error {
+	if _, err := domain.ParseID(id); err != nil {
+		return errors.New("invalid user id")
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"users-app/auth"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+// APIKeyHeader carries the API key, the JWTs are sent in the Authorization header
+const APIKeyHeader = "X-API-Key"
+
+// publicRoutes are served without the credentials, so that the probes don't need them
+var publicRoutes = map[string]bool{
+	"GET /health": true,
+	"GET /livez":  true,
+	"GET /readyz": true,
+}
+
+// routeScopes are the scopes required by the routes, keyed by the method and the route pattern.
+// The REST gateway is mounted under /v1, so its routes are told apart by the method only.
+var routeScopes = map[string]auth.Scope{
+	"GET /users":                           auth.ScopeRead,
+	"GET /users/events":                    auth.ScopeRead,
+	"POST /users":                          auth.ScopeWrite,
+	"PATCH /users/{userID}":                auth.ScopeWrite,
+	"DELETE /users/{userID}":               auth.ScopeAdmin,
+	"GET /v1/*":                            auth.ScopeRead,
+	"POST /v1/*":                           auth.ScopeWrite,
+	"PATCH /v1/*":                          auth.ScopeWrite,
+	"DELETE /v1/*":                         auth.ScopeAdmin,
+	"GET /webhooks":                        auth.ScopeAdmin,
+	"POST /webhooks":                       auth.ScopeAdmin,
+	"GET /webhooks/{webhookID}":            auth.ScopeAdmin,
+	"PATCH /webhooks/{webhookID}":          auth.ScopeAdmin,
+	"DELETE /webhooks/{webhookID}":         auth.ScopeAdmin,
+	"GET /webhooks/{webhookID}/deliveries": auth.ScopeAdmin,
+}
+
+// NewAuthMiddleware authenticates the callers with a JWT (Authorization: Bearer) or an API key (X-API-Key) and checks
+// the scope required by the route, the caller is put into the request context. The requests without valid credentials
+// are rejected with 401, the ones without the scope with 403. The routes missing in routeScopes are forbidden,
+// so that a new route isn't exposed before it's decided who can call it.
+func NewAuthMiddleware(authenticator *auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.Method + " " + routePattern(r)
+			if publicRoutes[route] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(credentials(r))
+			if err != nil {
+				logging.FromContext(r.Context()).Info("authentication failed", zap.Error(err))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="users"`)
+				respondError(w, r, http.StatusUnauthorized, unauthenticatedMessage(err))
+				return
+			}
+			ctx := logging.With(auth.WithPrincipal(r.Context(), principal), zap.String(logging.PrincipalKey, principal.Subject))
+
+			scope, ok := routeScopes[route]
+			if !ok {
+				logging.FromContext(ctx).Error("no scope configured for the route", zap.String("route", route))
+				respondError(w, r, http.StatusForbidden, "forbidden")
+				return
+			}
+			if !principal.HasScope(scope) {
+				logging.FromContext(ctx).Info("missing scope", zap.String("scope", string(scope)))
+				respondError(w, r, http.StatusForbidden, "missing scope "+string(scope))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func credentials(r *http.Request) auth.Credentials {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return auth.Credentials{BearerToken: token, APIKey: r.Header.Get(APIKeyHeader)}
+}
+
+// unauthenticatedMessage doesn't tell the caller why the credentials are invalid, the reason is only logged
+func unauthenticatedMessage(err error) string {
+	if errors.Is(err, auth.ErrNoCredentials) {
+		return "missing credentials"
+	}
+
+	return "invalid credentials"
+}
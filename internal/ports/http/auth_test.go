@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"users-app/auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthenticator(t *testing.T) *auth.Authenticator {
+	apiKeys := "- name: reader\n  sha256: " + auth.HashAPIKey("reader-key") + "\n  scopes: [users:read]\n" +
+		"- name: admin\n  sha256: " + auth.HashAPIKey("admin-key") + "\n  scopes: [users:admin]\n"
+	path := filepath.Join(t.TempDir(), "api_keys.yml")
+	require.NoError(t, os.WriteFile(path, []byte(apiKeys), 0o600))
+
+	authenticator, err := auth.NewAuthenticator(auth.Config{APIKeysFile: path})
+	require.NoError(t, err)
+	return authenticator
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		apiKey string
+
+		wantStatus    int
+		wantPrincipal string
+	}{
+		{name: "public_route", method: http.MethodGet, target: "/health", wantStatus: http.StatusOK},
+		{name: "no_credentials", method: http.MethodGet, target: "/users", wantStatus: http.StatusUnauthorized},
+		{name: "invalid_api_key", method: http.MethodGet, target: "/users", apiKey: "other-key", wantStatus: http.StatusUnauthorized},
+		{name: "scope_granted", method: http.MethodGet, target: "/users", apiKey: "reader-key", wantStatus: http.StatusOK, wantPrincipal: "reader"},
+		{name: "gateway_scope_granted", method: http.MethodGet, target: "/v1/users", apiKey: "reader-key", wantStatus: http.StatusOK, wantPrincipal: "reader"},
+		{name: "scope_missing", method: http.MethodDelete, target: "/users/1", apiKey: "reader-key", wantStatus: http.StatusForbidden},
+		{name: "gateway_scope_missing", method: http.MethodDelete, target: "/v1/users/1", apiKey: "reader-key", wantStatus: http.StatusForbidden},
+		{name: "admin_includes_other_scopes", method: http.MethodDelete, target: "/users/1", apiKey: "admin-key", wantStatus: http.StatusOK, wantPrincipal: "admin"},
+		{name: "route_without_scope", method: http.MethodGet, target: "/internal", apiKey: "admin-key", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var principal string
+			handler := NewAuthMiddleware(newTestAuthenticator(t))(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				if got, ok := auth.PrincipalFromContext(r.Context()); ok {
+					principal = got.Subject
+				}
+			}))
+			router := chi.NewRouter()
+			for _, pattern := range []string{"/health", "/users", "/users/{userID}", "/v1/*", "/internal"} {
+				router.Handle(pattern, handler)
+			}
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantPrincipal, principal)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
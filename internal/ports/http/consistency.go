@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+	"users-app/domain"
+)
+
+// ConsistencyTokenHeader carries the consistency token, returned after the commands and sent back with the queries
+// which need to see their writes
+const ConsistencyTokenHeader = "X-Consistency-Token"
+
+// NewConsistencyMiddleware puts the consistency token of the request into its context, and returns the token of
+// the writes made by the commands (the requests other than GET) in the response header
+func NewConsistencyMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if token := r.Header.Get(ConsistencyTokenHeader); token != "" {
+				ctx = domain.WithConsistencyToken(ctx, domain.ConsistencyToken(token))
+			}
+			// the queries, including the streamed ones, don't write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			ctx, tracker := domain.TrackWrites(ctx)
+			next.ServeHTTP(&consistencyResponseWriter{ResponseWriter: w, tracker: tracker}, r.WithContext(ctx))
+		})
+	}
+}
+
+// consistencyResponseWriter sets the token header before the response is written, the command is done by then
+type consistencyResponseWriter struct {
+	http.ResponseWriter
+	tracker     *domain.WriteTracker
+	wroteHeader bool
+}
+
+func (w *consistencyResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if token := w.tracker.Token(); token != "" {
+			w.Header().Set(ConsistencyTokenHeader, string(token))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *consistencyResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the wrapped writer
+func (w *consistencyResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		write  domain.ConsistencyToken
+
+		wantReadToken     domain.ConsistencyToken
+		wantResponseToken string
+	}{
+		{name: "query", method: http.MethodGet},
+		{name: "query_with_token", method: http.MethodGet, token: "0/30", wantReadToken: "0/30"},
+		{name: "command_writing", method: http.MethodPost, write: "0/40", wantResponseToken: "0/40"},
+		{name: "command_not_writing", method: http.MethodPatch, token: "0/30", wantReadToken: "0/30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readToken domain.ConsistencyToken
+			handler := NewConsistencyMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				readToken = domain.ConsistencyTokenFromContext(r.Context())
+				if tracker, ok := domain.WriteTrackerFromContext(r.Context()); ok && tt.write != "" {
+					tracker.Record(tt.write)
+				}
+				w.WriteHeader(http.StatusCreated)
+			}))
+			req := httptest.NewRequest(tt.method, "/users", nil)
+			if tt.token != "" {
+				req.Header.Set(ConsistencyTokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.Equal(t, tt.wantReadToken, readToken)
+			assert.Equal(t, tt.wantResponseToken, w.Header().Get(ConsistencyTokenHeader))
+		})
+	}
+}
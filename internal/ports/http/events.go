@@ -0,0 +1,167 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"users-app/domain"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+// EventsHandler streams user events to the clients as Server-Sent Events (text/event-stream).
+// It's meant for browser based clients, which cannot subscribe to Redis or use gRPC streams.
+//
+// Every event is sent with its id (<epoch>-<number>), so a client that got disconnected can resume the stream
+// by sending the Last-Event-ID header (browsers do it automatically when EventSource reconnects).
+// When the events following the id are not kept anymore, or the id is of another epoch (the stream of another
+// process), the stream starts with a reset event instead, the client should reload the users then.
+// Only the events of the tenant of the request are sent, the ids of the others are skipped.
+// In order to keep the connection alive through proxies, a heartbeat comment is sent periodically.
+//
+// Streams never become idle, so Close needs to be called when the server shuts down to end them.
+type EventsHandler struct {
+	subscriber domain.EventSubscriber
+	heartbeat  time.Duration
+	done       chan struct{}
+	closeOnce  *sync.Once
+}
+
+func NewEventsHandler(subscriber domain.EventSubscriber, heartbeat time.Duration) EventsHandler {
+	return EventsHandler{
+		subscriber: subscriber,
+		heartbeat:  heartbeat,
+		done:       make(chan struct{}),
+		closeOnce:  &sync.Once{},
+	}
+}
+
+// Close ends all the open streams, clients will reconnect (to another instance) and get reset
+func (h EventsHandler) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+// eventData is the payload sent in the data field of every event
+type eventData struct {
+	Type     domain.EventMsg        `json:"type"`
+	TenantID domain.TenantID        `json:"tenant_id"`
+	Payload  map[string]interface{} `json:"payload"`
+}
+
+func (h EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEvent, err := lastEventFromRequest(r)
+	if err != nil {
+		http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	sub := h.subscriber.Subscribe(lastEvent)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if reset := sub.Reset(); reset != nil {
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: reset\ndata: {}\n\n", eventID(*reset)); err != nil {
+			logging.FromContext(r.Context()).Warn("failed to write event", zap.Error(err))
+			return
+		}
+	}
+	tenant := domain.TenantFromContext(r.Context())
+	for _, event := range sub.Backlog() {
+		if event.TenantID != tenant {
+			continue
+		}
+		if err := writeEvent(w, event); err != nil {
+			logging.FromContext(r.Context()).Warn("failed to write event", zap.Error(err))
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-sub.Events():
+			if !ok {
+				// the client was too slow and got dropped by the stream,
+				// it should reconnect and resume from the last event it has received
+				return
+			}
+			if event.TenantID != tenant {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				logging.FromContext(r.Context()).Warn("failed to write event", zap.Error(err))
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event domain.SequencedEvent) error {
+	data, err := json.Marshal(eventData{Type: event.Msg, TenantID: event.TenantID, Payload: event.Payload()})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", eventID(domain.EventPosition{Epoch: event.Epoch, ID: event.ID}),
+		event.Msg, data)
+	return err
+}
+
+func eventID(position domain.EventPosition) string {
+	return position.Epoch + "-" + strconv.FormatUint(position.ID, 10)
+}
+
+// lastEventFromRequest reads the Last-Event-ID header. As the EventSource API does not allow setting headers
+// for the initial connection, the last_event_id query param is accepted as well. The ids without the epoch
+// (sent before the epochs were introduced) are of an unknown epoch.
+func lastEventFromRequest(r *http.Request) (*domain.EventPosition, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	var position domain.EventPosition
+	number := value
+	if i := strings.LastIndex(value, "-"); i >= 0 {
+		position.Epoch, number = value[:i], value[i+1:]
+	}
+	id, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	position.ID = id
+
+	return &position, nil
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastEventFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header string
+
+		want    *domain.EventPosition
+		wantErr bool
+	}{
+		{name: "no_last_event", target: "/users/events"},
+		{name: "header", target: "/users/events", header: "lz3k9x-42", want: &domain.EventPosition{Epoch: "lz3k9x", ID: 42}},
+		{name: "query_param", target: "/users/events?last_event_id=lz3k9x-7", want: &domain.EventPosition{Epoch: "lz3k9x", ID: 7}},
+		{name: "id_without_epoch", target: "/users/events", header: "5000", want: &domain.EventPosition{ID: 5000}},
+		{name: "invalid_id", target: "/users/events", header: "lz3k9x-x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Last-Event-ID", tt.header)
+			}
+
+			got, err := lastEventFromRequest(req)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	users_app "users-app/gen/grpc"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewGateway returns the REST API generated from users.proto (the /v1 routes, see users.swagger.json),
+// the requests are passed to the users server in-process
+func NewGateway(server users_app.UsersServer) (http.Handler, error) {
+	mux := runtime.NewServeMux(append(gatewayOptions(), runtime.WithErrorHandler(errorHandler))...)
+	if err := users_app.RegisterUsersHandlerServer(context.Background(), mux, server); err != nil {
+		return nil, err
+	}
+
+	return mux, nil
+}
+
+// NewLegacyUsersHandler serves the /users routes described in users.yml with the gateway, until the clients
+// move to /v1. The requests are translated to the /v1 ones (e.g. ?first_name= to ?filter.first_name=),
+// the responses keep the status codes and the errors of the hand-written handlers which used to serve them.
+func NewLegacyUsersHandler(server users_app.UsersServer) (http.Handler, error) {
+	mux := runtime.NewServeMux(append(gatewayOptions(),
+		runtime.WithErrorHandler(legacyErrorHandler),
+		runtime.WithForwardResponseOption(legacyStatus),
+	)...)
+	if err := users_app.RegisterUsersHandlerServer(context.Background(), mux, server); err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(&noContentWriter{ResponseWriter: w}, legacyRequest(r))
+	}), nil
+}
+
+func gatewayOptions() []runtime.ServeMuxOption {
+	return []runtime.ServeMuxOption{
+		// the fields are named the same way as in users.yml, e.g. first_name, and the empty ones are not omitted
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+	}
+}
+
+// headerMatcher passes the Idempotency-Key header in the metadata, as the gRPC clients do
+func headerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, "Idempotency-Key") {
+		return "idempotency-key", true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// legacyQueryParams maps the query params of GET /users to the fields of GetUsersRequest
+var legacyQueryParams = map[string]string{
+	"first_name": "filter.first_name",
+	"last_name":  "filter.last_name",
+	"nickname":   "filter.nickname",
+	"email":      "filter.email",
+	"country":    "filter.country",
+	"limit":      "pagination.limit",
+	"offset":     "pagination.offset",
+}
+
+func legacyRequest(r *http.Request) *http.Request {
+	ret := r.Clone(r.Context())
+	ret.URL.Path = "/v1" + r.URL.Path
+	ret.URL.RawPath = ""
+
+	query := ret.URL.Query()
+	for legacy, param := range legacyQueryParams {
+		if values, ok := query[legacy]; ok {
+			query[param] = values
+			delete(query, legacy)
+		}
+	}
+	ret.URL.RawQuery = query.Encode()
+
+	return ret
+}
+
+// legacyStatus sets the status codes of the successful responses the way users.yml describes them
+func legacyStatus(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	method, _ := runtime.RPCMethod(ctx)
+	switch method {
+	case users_app.Users_CreateUser_FullMethodName:
+		w.WriteHeader(http.StatusCreated)
+	case users_app.Users_DeleteUser_FullMethodName:
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return nil
+}
+
+// errorHandler responds with the gateway's error body, with the status codes of httpStatusFromCode
+func errorHandler(
+	ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error,
+) {
+	if st, ok := status.FromError(err); ok {
+		err = &runtime.HTTPStatusError{HTTPStatus: httpStatusFromCode(st.Code()), Err: err}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// legacyErrorHandler responds with api.Error, with the status codes of httpStatusFromCode
+func legacyErrorHandler(
+	_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error,
+) {
+	st := status.Convert(err)
+	respondError(w, r, httpStatusFromCode(st.Code()), st.Message())
+}
+
+// httpStatusFromCode is the gateway's mapping, except for the idempotency key reused with a different payload,
+// which results in 422 instead of 400
+func httpStatusFromCode(code codes.Code) int {
+	if code == codes.FailedPrecondition {
+		return http.StatusUnprocessableEntity
+	}
+
+	return runtime.HTTPStatusFromCode(code)
+}
+
+// noContentWriter drops the body of the 204 responses, the gateway writes the marshaled message regardless
+type noContentWriter struct {
+	http.ResponseWriter
+	noContent bool
+}
+
+func (w *noContentWriter) WriteHeader(code int) {
+	if code == http.StatusNoContent {
+		w.noContent = true
+		w.Header().Del("Content-Type")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *noContentWriter) Write(b []byte) (int, error) {
+	if w.noContent {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
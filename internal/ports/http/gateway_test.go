@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	users_app "users-app/gen/grpc"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// usersServerStub returns the configured error from every call, the received requests are kept
+type usersServerStub struct {
+	users_app.UnimplementedUsersServer
+	err      error
+	requests []proto.Message
+	metadata []metadata.MD
+}
+
+func (s *usersServerStub) received(ctx context.Context, in proto.Message) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.requests = append(s.requests, in)
+	s.metadata = append(s.metadata, md)
+	return s.err
+}
+
+func (s *usersServerStub) GetUsers(ctx context.Context, in *users_app.GetUsersRequest) (*users_app.GetUsersResponse, error) {
+	return &users_app.GetUsersResponse{Users: []*users_app.User{{FirstName: "John"}}}, s.received(ctx, in)
+}
+
+func (s *usersServerStub) GetCountryCounts(ctx context.Context, in *empty.Empty) (*users_app.GetCountryCountsResponse, error) {
+	return &users_app.GetCountryCountsResponse{Countries: []*users_app.CountryCount{{Country: "UK", Users: 2}}}, s.received(ctx, in)
+}
+
+func (s *usersServerStub) CreateUser(ctx context.Context, in *users_app.CreateUserRequest) (*users_app.User, error) {
+	return &users_app.User{FirstName: in.GetFirstName()}, s.received(ctx, in)
+}
+
+func (s *usersServerStub) DeleteUser(ctx context.Context, in *users_app.DeleteUserRequest) (*empty.Empty, error) {
+	return &empty.Empty{}, s.received(ctx, in)
+}
+
+func TestGateway(t *testing.T) {
+	tests := []struct {
+		name   string
+		legacy bool
+		method string
+		target string
+		body   string
+		err    error
+
+		wantStatus  int
+		wantBody    string
+		wantRequest proto.Message
+	}{
+		{
+			name:        "get_users",
+			method:      http.MethodGet,
+			target:      "/v1/users?filter.first_name=John&pagination.limit=5",
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"users":[{"id":"","first_name":"John","last_name":"","nickname":"","email":"","country":"","created_at":null,"updated_at":null}]}`,
+			wantRequest: &users_app.GetUsersRequest{Filter: &users_app.Filter{FirstName: "John"}, Pagination: &users_app.Pagination{Limit: 5}},
+		},
+		{
+			name:        "get_users_search",
+			method:      http.MethodGet,
+			target:      "/v1/users?filter.search=john%20doe",
+			wantStatus:  http.StatusOK,
+			wantRequest: &users_app.GetUsersRequest{Filter: &users_app.Filter{Search: "john doe"}},
+		},
+		{
+			name:        "get_country_counts",
+			method:      http.MethodGet,
+			target:      "/v1/countries",
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"countries":[{"country":"UK","users":2}]}`,
+			wantRequest: &empty.Empty{},
+		},
+		{
+			name:        "create_user",
+			method:      http.MethodPost,
+			target:      "/v1/users",
+			body:        `{"first_name": "John", "unknown": "ignored"}`,
+			wantStatus:  http.StatusOK,
+			wantRequest: &users_app.CreateUserRequest{FirstName: "John"},
+		},
+		{
+			name:       "error",
+			method:     http.MethodDelete,
+			target:     "/v1/users/1",
+			err:        status.Error(codes.NotFound, "user not found"),
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":5,"message":"user not found","details":[]}`,
+		},
+		{
+			name:       "idempotency_key_mismatch",
+			method:     http.MethodPost,
+			target:     "/v1/users",
+			body:       `{}`,
+			err:        status.Error(codes.FailedPrecondition, "idempotency key mismatch"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"code":9,"message":"idempotency key mismatch","details":[]}`,
+		},
+		{
+			name:        "legacy_get_users_query_params_are_translated",
+			legacy:      true,
+			method:      http.MethodGet,
+			target:      "/users?first_name=John&country=UK&limit=5&offset=10",
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"users":[{"id":"","first_name":"John","last_name":"","nickname":"","email":"","country":"","created_at":null,"updated_at":null}]}`,
+			wantRequest: &users_app.GetUsersRequest{Filter: &users_app.Filter{FirstName: "John", Country: "UK"}, Pagination: &users_app.Pagination{Limit: 5, Offset: 10}},
+		},
+		{
+			name:        "legacy_create_user_is_created",
+			legacy:      true,
+			method:      http.MethodPost,
+			target:      "/users",
+			body:        `{"first_name": "John"}`,
+			wantStatus:  http.StatusCreated,
+			wantRequest: &users_app.CreateUserRequest{FirstName: "John"},
+		},
+		{
+			name:        "legacy_delete_user_has_no_content",
+			legacy:      true,
+			method:      http.MethodDelete,
+			target:      "/users/1",
+			wantStatus:  http.StatusNoContent,
+			wantRequest: &users_app.DeleteUserRequest{Id: "1"},
+		},
+		{
+			name:       "legacy_error",
+			legacy:     true,
+			method:     http.MethodDelete,
+			target:     "/users/1",
+			err:        status.Error(codes.NotFound, "user not found"),
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":404,"message":"user not found"}`,
+		},
+		{
+			name:       "legacy_idempotency_key_mismatch",
+			legacy:     true,
+			method:     http.MethodPost,
+			target:     "/users",
+			body:       `{}`,
+			err:        status.Error(codes.FailedPrecondition, "idempotency key mismatch"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"code":422,"message":"idempotency key mismatch"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &usersServerStub{err: tt.err}
+			newHandler := NewGateway
+			if tt.legacy {
+				newHandler = NewLegacyUsersHandler
+			}
+			handler, err := newHandler(server)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+			}
+			if tt.wantRequest != nil {
+				require.Len(t, server.requests, 1)
+				assert.True(t, proto.Equal(tt.wantRequest, server.requests[0]), "got %v", server.requests[0])
+			}
+		})
+	}
+}
+
+func TestGateway_idempotency_key(t *testing.T) {
+	server := &usersServerStub{}
+	handler, err := NewLegacyUsersHandler(server)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Len(t, server.metadata, 1)
+	assert.Equal(t, []string{"key"}, server.metadata[0].Get("idempotency-key"))
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+	"users-app/gen/api"
+	"users-app/service"
+
+	"github.com/go-chi/render"
+)
+
+// GetHealth is kept for the existing clients, it works the same way as GetReadyz
+func (h Server) GetHealth(w http.ResponseWriter, r *http.Request) {
+	h.GetReadyz(w, r)
+}
+
+// GetLivez answers as long as the application is running, it doesn't check the dependencies,
+// so that the orchestrator doesn't restart the application when e.g. the database is down
+func (h Server) GetLivez(w http.ResponseWriter, r *http.Request) {
+	render.Respond(w, r, api.Health{Status: api.Up})
+}
+
+// GetReadyz checks the dependencies, the application should not receive traffic when any of them is down.
+// It's not authenticated, so only the status of every dependency is returned, the errors are logged.
+func (h Server) GetReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Check(r.Context())
+	if !report.Healthy() {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+
+	render.Respond(w, r, healthFromReport(report))
+}
+
+func healthFromReport(report service.HealthReport) api.Health {
+	dependencies := make(map[string]api.DependencyHealth, len(report.Dependencies))
+	for name, dependency := range report.Dependencies {
+		dependencies[name] = api.DependencyHealth{
+			Status:     api.HealthStatus(dependency.Status),
+			DurationMs: dependency.Duration.Milliseconds(),
+		}
+	}
+
+	return api.Health{
+		Status:       api.HealthStatus(report.Status),
+		Dependencies: &dependencies,
+	}
+}
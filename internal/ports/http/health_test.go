@@ -0,0 +1,33 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"users-app/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_GetReadyz(t *testing.T) {
+	health := service.NewHealthService(time.Second,
+		service.HealthCheck{Name: "postgres", Check: func(context.Context) error { return nil }},
+		service.HealthCheck{Name: "redis", Check: func(context.Context) error {
+			return errors.New("dial tcp 10.0.0.7:6379: connect: connection refused")
+		}},
+	)
+	server := NewHttpServer(nil, health, nil)
+	w := httptest.NewRecorder()
+
+	server.GetReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status": "down", "dependencies": {
+		"postgres": {"status": "up", "duration_ms": 0},
+		"redis": {"status": "down", "duration_ms": 0}
+	}}`, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "10.0.0.7", "the errors are only logged")
+}
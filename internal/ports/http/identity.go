@@ -0,0 +1,23 @@
+package http
+
+import (
+	"net/http"
+	"users-app/auth"
+)
+
+// NewClientIdentityMiddleware puts the identity from the verified client certificate into the request context,
+// the requests without the certificate are passed unchanged. It needs to wrap the logging middleware,
+// which logs the subject of the identity.
+func NewClientIdentityMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := auth.VerifiedClientIdentity(r.TLS)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClientIdentity(r.Context(), identity)))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"users-app/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIdentityMiddleware(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/billing")
+	clientCert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"Example"}}, URIs: []*url.URL{spiffeID}}
+	tests := []struct {
+		name         string
+		tls          *tls.ConnectionState
+		wantIdentity *auth.ClientIdentity
+	}{
+		{
+			name: "verified_certificate",
+			tls:  &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{clientCert}}},
+			wantIdentity: &auth.ClientIdentity{
+				Subject: "CN=billing,O=Example", CommonName: "billing", URIs: []string{"spiffe://example.org/billing"},
+			},
+		},
+		{
+			name: "no_client_certificate",
+			tls:  &tls.ConnectionState{},
+		},
+		{
+			name: "plaintext",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity *auth.ClientIdentity
+			handler := NewClientIdentityMiddleware()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				if got, ok := auth.ClientIdentityFromContext(r.Context()); ok {
+					identity = &got
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.TLS = tt.tls
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.wantIdentity, identity)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"time"
+	"users-app/auth"
+	"users-app/logging"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is used to pass the request id between the services, it's returned in every response
+const RequestIDHeader = "X-Request-Id"
+
+// NewLoggingMiddleware puts the logger, enriched with the request details (and the client identity,
+// see NewClientIdentityMiddleware), into the request context and logs every request when it's handled.
+// The request id is taken from middleware.RequestID, so it needs to be used after it.
+func NewLoggingMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := middleware.GetReqID(r.Context())
+			w.Header().Set(RequestIDHeader, requestID)
+
+			fields := []zap.Field{
+				zap.String(logging.RequestIDKey, requestID),
+				zap.String(logging.TransportKey, "http"),
+				zap.String(logging.MethodKey, r.Method+" "+routePattern(r)),
+			}
+			if identity, ok := auth.ClientIdentityFromContext(r.Context()); ok {
+				fields = append(fields, zap.String(logging.ClientKey, identity.Subject))
+			}
+			ctx := logging.WithLogger(r.Context(), logger.With(fields...))
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			logging.FromContext(ctx).Info("request handled",
+				zap.String("path", r.URL.Path),
+				zap.Int("status", status),
+				zap.Int("bytes", ww.BytesWritten()),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewMetricsMiddleware counts the requests and measures their duration, by method, route and status code.
+// The route is the pattern the request was matched with (e.g. /users/{userID}), so that the number of series is bounded.
+func NewMetricsMiddleware(registerer prometheus.Registerer) func(http.Handler) http.Handler {
+	total := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of handled HTTP requests, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of the HTTP requests, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	registerer.MustRegister(total, duration)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			route := routePattern(r)
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			total.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+			duration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"users-app/auth"
+	"users-app/logging"
+	"users-app/ratelimit"
+
+	"go.uber.org/zap"
+)
+
+// NewRateLimitMiddleware limits the rate of the requests of every caller, the requests over the limit are rejected
+// with 429 and the Retry-After header. The routes reading the users take the tokens of the read budget, the others
+// of the write one. The health checks are not limited, and neither is anything when the limiter fails.
+func NewRateLimitMiddleware(limiter *ratelimit.RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.Method + " " + routePattern(r)
+			if publicRoutes[route] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := limiter.Allow(r.Context(), route, routeScopes[route] != auth.ScopeRead, r.RemoteAddr,
+				r.Header.Values("X-Forwarded-For"))
+			if err != nil {
+				logging.FromContext(r.Context()).Error("failed to check the rate limit", zap.Error(err))
+			} else if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+				respondError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
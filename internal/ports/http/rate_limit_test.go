@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"users-app/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryLimiter(), ratelimit.Policy{
+		Read:  ratelimit.Limit{Rate: 0.5, Burst: 1},
+		Write: ratelimit.Limit{Rate: 0.5, Burst: 1},
+	})
+	router := chi.NewRouter()
+	handler := NewRateLimitMiddleware(limiter)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, pattern := range []string{"/health", "/users", "/users/{userID}"} {
+		router.Handle(pattern, handler)
+	}
+	serve := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/users", "10.0.0.1:1234").Code)
+	w := serve(http.MethodGet, "/users", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":429,"message":"rate limit exceeded"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/users/1", "10.0.0.1:1234").Code, "the write budget is separate")
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/users", "10.0.0.2:1234").Code, "every caller has its own budget")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/health", "10.0.0.1:1234").Code, "the health checks are not limited")
+	}
+}
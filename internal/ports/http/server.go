@@ -1,89 +1,61 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"users-app/domain"
 	"users-app/gen/api"
+	"users-app/logging"
 	"users-app/service"
 
-	"github.com/go-chi/render"
-	"github.com/labstack/gommon/log"
+	"go.uber.org/zap"
 )
 
 type Server struct {
-	queryService   service.UsersQueryService
-	commandService service.UsersCommandService
+	webhooksService service.WebhooksService
+	healthService   service.HealthService
+	// legacyUsers serves the /users routes, see NewLegacyUsersHandler
+	legacyUsers http.Handler
 }
 
-func (h Server) GetUsers(w http.ResponseWriter, r *http.Request, params api.GetUsersParams) {
-	users, err := h.queryService.Users(r.Context(), filterFromParams(params), paginationFromParams(params))
-	if err != nil {
-		log.Error(err)
-		render.Respond(w, r, api.Error{Code: http.StatusInternalServerError, Message: "internal server error"})
-		return
-	}
-
-	render.Respond(w, r, api.Users{
-		Users: usersListFromDomain(users),
-	})
-
-	return
+func NewHttpServer(
+	webhooks service.WebhooksService,
+	health service.HealthService,
+	legacyUsers http.Handler,
+) Server {
 
+	return Server{webhooks, health, legacyUsers}
 }
 
-func NewHttpServer(queries service.UsersQueryService, commands service.UsersCommandService) Server {
-
-	return Server{queries, commands}
+// GetUsers is deprecated, GET /v1/users should be used instead
+func (h Server) GetUsers(w http.ResponseWriter, r *http.Request, _ api.GetUsersParams) {
+	h.legacyUsers.ServeHTTP(w, r)
 }
 
-func (h Server) GetHealth(w http.ResponseWriter, r *http.Request) {
-	render.Respond(w, r, "ok")
-	return
+// PostUsers is deprecated, POST /v1/users should be used instead
+func (h Server) PostUsers(w http.ResponseWriter, r *http.Request, _ api.PostUsersParams) {
+	h.legacyUsers.ServeHTTP(w, r)
 }
 
-func (h Server) PostUsers(w http.ResponseWriter, r *http.Request) {
-	postUser := api.PostUser{}
-	err := render.Decode(r, &postUser)
-	if err != nil {
-		// todo - implement proper logging
-		log.Error(err)
-		render.Respond(w, r, api.Error{Code: http.StatusBadRequest, Message: "invalid request"})
-		return
-	}
-
-	user, err := h.commandService.AddUser(r.Context(), service.AddUserCommand{
-		FirstName: postUser.FirstName,
-		LastName:  postUser.LastName,
-		Nickname:  postUser.Nickname,
-		Password:  postUser.Password,
-		Email:     string(postUser.Email),
-		Country:   postUser.Country,
-	})
-	if err != nil {
-		log.Error(err)
-		// todo - implement proper error handling
-		render.Respond(w, r, api.Error{Code: http.StatusInternalServerError, Message: "internal server error"})
-		return
-	}
+// PatchUsersUserID is deprecated, PATCH /v1/users/{id} should be used instead
+func (h Server) PatchUsersUserID(w http.ResponseWriter, r *http.Request, _ string) {
+	h.legacyUsers.ServeHTTP(w, r)
+}
 
-	render.Respond(w, r, toUserResponse(user))
-	return
+// DeleteUsersUserID is deprecated, DELETE /v1/users/{id} should be used instead
+func (h Server) DeleteUsersUserID(w http.ResponseWriter, r *http.Request, _ string) {
+	h.legacyUsers.ServeHTTP(w, r)
 }
 
-func (h Server) DeleteUsersUserID(w http.ResponseWriter, r *http.Request, userID string) {
-	id, err := domain.ParseID(userID)
-	if err != nil {
-		log.Error(err)
-		render.Respond(w, r, api.Error{Code: http.StatusBadRequest, Message: "invalid user id"})
+// respondUnexpectedError responds with 504 when the request ran out of time (e.g. the database query timed out),
+// 500 otherwise
+func respondUnexpectedError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		logging.FromContext(r.Context()).Warn(message, zap.Error(err))
+		respondError(w, r, http.StatusGatewayTimeout, "request timed out")
 		return
 	}
 
-	h.commandService.DeleteUser(r.Context(), service.DeleteUserCommand{ID: id})
-	render.Respond(w, r, "ok")
-	return
-}
-
-func (h Server) PatchUsersUserID(w http.ResponseWriter, r *http.Request, userID string) {
-	render.Respond(w, r, "ok")
-	return
+	logging.FromContext(r.Context()).Error(message, zap.Error(err))
+	respondError(w, r, http.StatusInternalServerError, "internal server error")
 }
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"users-app/domain"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+// TenantHeader names the tenant the request is made for
+const TenantHeader = "X-Tenant-ID"
+
+// NewTenantMiddleware puts the tenant named by the X-Tenant-ID header into the request context. The requests without
+// the header are made for domain.DefaultTenant, unless the header is required - they are rejected with 400 then,
+// the same as the ones naming an invalid tenant. The health checks don't need the tenant.
+func NewTenantMiddleware(required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if publicRoutes[r.Method+" "+routePattern(r)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tenant := domain.DefaultTenant
+			if header := r.Header.Get(TenantHeader); header != "" {
+				var err error
+				if tenant, err = domain.ParseTenantID(header); err != nil {
+					respondError(w, r, http.StatusBadRequest, "invalid "+TenantHeader)
+					return
+				}
+			} else if required {
+				respondError(w, r, http.StatusBadRequest, "missing "+TenantHeader)
+				return
+			}
+
+			ctx := logging.With(domain.WithTenant(r.Context(), tenant), zap.String(logging.TenantKey, string(tenant)))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
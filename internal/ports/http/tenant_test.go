@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"users-app/domain"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		target   string
+		header   string
+
+		wantStatus int
+		wantTenant domain.TenantID
+	}{
+		{name: "tenant", target: "/users", header: "arcade", wantStatus: http.StatusOK, wantTenant: "arcade"},
+		{name: "default_tenant", target: "/users", wantStatus: http.StatusOK, wantTenant: domain.DefaultTenant},
+		{name: "invalid_tenant", target: "/users", header: "Arcade!", wantStatus: http.StatusBadRequest},
+		{name: "required_tenant", required: true, target: "/users", header: "arcade", wantStatus: http.StatusOK, wantTenant: "arcade"},
+		{name: "missing_tenant", required: true, target: "/users", wantStatus: http.StatusBadRequest},
+		{name: "public_route", required: true, target: "/health", wantStatus: http.StatusOK, wantTenant: domain.DefaultTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tenant domain.TenantID
+			handler := NewTenantMiddleware(tt.required)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				tenant = domain.TenantFromContext(r.Context())
+			}))
+			router := chi.NewRouter()
+			for _, pattern := range []string{"/health", "/users"} {
+				router.Handle(pattern, handler)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantTenant, tenant)
+		})
+	}
+}
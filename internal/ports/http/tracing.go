@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracingMiddleware starts a span for every request, continuing the trace of the caller (W3C traceparent header).
+// The spans are named after the matched route (e.g. GET /users/{userID}), so it needs to be used after routing.
+func NewTracingMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withRoute := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(routePattern(r)))
+			next.ServeHTTP(w, r)
+		})
+
+		return otelhttp.NewHandler(withRoute, "http",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + routePattern(r)
+			}),
+		)
+	}
+}
+
+func routePattern(r *http.Request) string {
+	if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+		return routeContext.RoutePattern()
+	}
+
+	return "unmatched"
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"users-app/domain"
+	"users-app/gen/api"
+	"users-app/logging"
+	"users-app/service"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+func (h Server) GetWebhooks(w http.ResponseWriter, r *http.Request, params api.GetWebhooksParams) {
+	subscriptions, err := h.webhooksService.Subscriptions(r.Context(), pagination(params.Limit, params.Offset))
+	if err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	ret := api.Webhooks{Webhooks: make([]api.Webhook, len(subscriptions))}
+	for i, s := range subscriptions {
+		ret.Webhooks[i] = toWebhookResponse(s)
+	}
+
+	render.Respond(w, r, ret)
+}
+
+func (h Server) PostWebhooks(w http.ResponseWriter, r *http.Request) {
+	postWebhook := api.PostWebhook{}
+	if err := render.Decode(r, &postWebhook); err != nil {
+		logging.FromContext(r.Context()).Info("invalid request", zap.Error(err))
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	command := service.AddWebhookCommand{URL: postWebhook.Url}
+	if postWebhook.EventTypes != nil {
+		command.EventTypes = eventTypesFromAPI(*postWebhook.EventTypes)
+	}
+
+	subscription, err := h.webhooksService.AddSubscription(r.Context(), command)
+	if err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	// the secret is returned only once - when the subscription gets created
+	ret := toWebhookResponse(subscription)
+	ret.Secret = &subscription.Secret
+
+	render.Status(r, http.StatusCreated)
+	render.Respond(w, r, ret)
+}
+
+func (h Server) GetWebhooksWebhookID(w http.ResponseWriter, r *http.Request, webhookID string) {
+	id, err := domain.ParseWebhookID(webhookID)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	subscription, err := h.webhooksService.Subscription(r.Context(), id)
+	if err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, toWebhookResponse(subscription))
+}
+
+func (h Server) PatchWebhooksWebhookID(w http.ResponseWriter, r *http.Request, webhookID string) {
+	id, err := domain.ParseWebhookID(webhookID)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	patchWebhook := api.PatchWebhook{}
+	if err := render.Decode(r, &patchWebhook); err != nil {
+		logging.FromContext(r.Context()).Info("invalid request", zap.Error(err))
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	command := service.ModifyWebhookCommand{ID: id, URL: patchWebhook.Url, Enabled: patchWebhook.Enabled}
+	if patchWebhook.EventTypes != nil {
+		eventTypes := eventTypesFromAPI(*patchWebhook.EventTypes)
+		command.EventTypes = &eventTypes
+	}
+
+	subscription, err := h.webhooksService.ModifySubscription(r.Context(), command)
+	if err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, toWebhookResponse(subscription))
+}
+
+func (h Server) DeleteWebhooksWebhookID(w http.ResponseWriter, r *http.Request, webhookID string) {
+	id, err := domain.ParseWebhookID(webhookID)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	if err := h.webhooksService.DeleteSubscription(r.Context(), id); err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h Server) GetWebhooksWebhookIDDeliveries(
+	w http.ResponseWriter, r *http.Request, webhookID string, params api.GetWebhooksWebhookIDDeliveriesParams,
+) {
+	id, err := domain.ParseWebhookID(webhookID)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	deliveries, err := h.webhooksService.Deliveries(r.Context(), id, pagination(params.Limit, params.Offset))
+	if err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	ret := api.WebhookDeliveries{Deliveries: make([]api.WebhookDelivery, len(deliveries))}
+	for i, d := range deliveries {
+		ret.Deliveries[i] = api.WebhookDelivery{
+			Id:         d.ID,
+			EventType:  api.EventType(d.EventType),
+			Attempt:    d.Attempt,
+			StatusCode: d.StatusCode,
+			Error:      d.Error,
+			Succeeded:  d.Succeeded,
+			DurationMs: d.Duration.Milliseconds(),
+			CreatedAt:  d.CreatedAt,
+		}
+	}
+
+	render.Respond(w, r, ret)
+}
+
+// respondWebhookError translates the webhook domain errors to http errors
+func respondWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		respondError(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidWebhookURL), errors.Is(err, domain.ErrPrivateWebhookURL), errors.Is(err, domain.ErrUnknownEventType):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		respondUnexpectedError(w, r, "webhooks request failed", err)
+	}
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	render.Status(r, code)
+	render.Respond(w, r, api.Error{Code: int32(code), Message: message})
+}
+
+func toWebhookResponse(s domain.WebhookSubscription) api.Webhook {
+	eventTypes := make([]api.EventType, len(s.EventTypes))
+	for i, t := range s.EventTypes {
+		eventTypes[i] = api.EventType(t)
+	}
+
+	return api.Webhook{
+		Id:                  s.ID,
+		Url:                 s.URL,
+		EventTypes:          eventTypes,
+		Enabled:             s.Enabled,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+	}
+}
+
+func eventTypesFromAPI(eventTypes []api.EventType) []domain.EventMsg {
+	ret := make([]domain.EventMsg, len(eventTypes))
+	for i, t := range eventTypes {
+		ret[i] = domain.EventMsg(t)
+	}
+
+	return ret
+}
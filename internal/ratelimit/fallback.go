@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+// Fallback uses the fallback limiter while the primary one fails, e.g. when Redis is unavailable. The primary one
+// is tried again after the retry interval, so that the requests are not slowed down by waiting for it every time.
+type Fallback struct {
+	primary       Limiter
+	fallback      Limiter
+	retryInterval time.Duration
+	now           func() time.Time
+
+	mu sync.Mutex
+	// failedAt is the time of the last failure of the primary limiter, zero when it works
+	failedAt time.Time
+}
+
+func NewFallback(primary, fallback Limiter, retryInterval time.Duration) *Fallback {
+	return &Fallback{primary: primary, fallback: fallback, retryInterval: retryInterval, now: time.Now}
+}
+
+func (f *Fallback) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	f.mu.Lock()
+	failing := !f.failedAt.IsZero()
+	retry := !failing || f.now().Sub(f.failedAt) >= f.retryInterval
+	f.mu.Unlock()
+	if !retry {
+		return f.fallback.Allow(ctx, key, limit)
+	}
+
+	result, err := f.primary.Allow(ctx, key, limit)
+
+	f.mu.Lock()
+	if err != nil {
+		f.failedAt = f.now()
+	} else {
+		f.failedAt = time.Time{}
+	}
+	f.mu.Unlock()
+
+	if err != nil {
+		if !failing {
+			logging.FromContext(ctx).Warn("rate limiter failed, the limits are kept in memory", zap.Error(err))
+		}
+		return f.fallback.Allow(ctx, key, limit)
+	}
+	if failing {
+		logging.FromContext(ctx).Info("rate limiter recovered")
+	}
+
+	return result, nil
+}
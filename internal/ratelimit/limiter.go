@@ -0,0 +1,121 @@
+// Package ratelimit limits the rate of the requests per caller with token buckets.
+//
+// Every caller has separate read and write budgets, the routes and methods can be given budgets of their own.
+// The buckets are kept in Redis, so that the limits are shared by all the instances, or in memory when Redis
+// is unavailable.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+	"users-app/auth"
+)
+
+// Limit is the budget of a token bucket: it holds up to Burst tokens and gets Rate tokens per second,
+// every request takes one token
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result tells if the request is allowed, RetryAfter is the time until the next token when it's not
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token from the bucket identified by the key, the bucket is created full
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Policy assigns the budgets to the requests
+type Policy struct {
+	Read  Limit
+	Write Limit
+	// Routes are the budgets of the HTTP routes ("GET /users") and gRPC methods ("/users.Users/GetUsers")
+	// replacing the read or write one, every route has its own bucket then
+	Routes map[string]Limit
+	// TrustedProxies are the addresses of the proxies in front of the application (e.g. the ingress), the address
+	// of the client of their requests is taken from X-Forwarded-For
+	TrustedProxies []netip.Prefix
+}
+
+// RateLimiter applies the policy to the callers
+type RateLimiter struct {
+	limiter Limiter
+	policy  Policy
+}
+
+func New(limiter Limiter, policy Policy) *RateLimiter {
+	return &RateLimiter{limiter: limiter, policy: policy}
+}
+
+// Allow takes a token from the caller's budget of the route. remoteAddr and forwardedFor (the values
+// of X-Forwarded-For) identify the callers that are neither authenticated nor presented a client certificate.
+func (r *RateLimiter) Allow(ctx context.Context, route string, write bool, remoteAddr string, forwardedFor []string) (Result, error) {
+	budget, limit := "read", r.policy.Read
+	if write {
+		budget, limit = "write", r.policy.Write
+	}
+	if routeLimit, ok := r.policy.Routes[route]; ok {
+		budget, limit = route, routeLimit
+	}
+
+	return r.limiter.Allow(ctx, budget+":"+callerKey(ctx, clientIP(remoteAddr, forwardedFor, r.policy.TrustedProxies)), limit)
+}
+
+// callerKey identifies the caller by the authenticated principal, the client certificate or the IP address,
+// whichever is known first. The tenant is not a part of the key, as it's named by the caller, so the caller has
+// the same budget in all the tenants.
+func callerKey(ctx context.Context, clientIP string) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return "principal:" + principal.Subject
+	}
+	if identity, ok := auth.ClientIdentityFromContext(ctx); ok && identity.CommonName != "" {
+		return "client:" + identity.CommonName
+	}
+
+	return "ip:" + clientIP
+}
+
+// clientIP returns the address of the client: the remote address, or the address the trusted proxies got
+// the request from. X-Forwarded-For is read from the right, as the proxies append the addresses, and the entries
+// left of the first untrusted address could be forged by the client.
+func clientIP(remoteAddr string, forwardedFor []string, trustedProxies []netip.Prefix) string {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+
+	var forwarded []string
+	for _, value := range forwardedFor {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0 && trusted(host, trustedProxies); i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		host = addr.String()
+	}
+
+	return host
+}
+
+func trusted(host string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
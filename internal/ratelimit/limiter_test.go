@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+	"users-app/auth"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestMemoryLimiter() (*MemoryLimiter, *clock) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	limiter := NewMemoryLimiter()
+	limiter.now = c.Now
+	return limiter, c
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter, c := newTestMemoryLimiter()
+	limit := Limit{Rate: 2, Burst: 3}
+	allow := func(key string) Result {
+		result, err := limiter.Allow(context.Background(), key, limit)
+		require.NoError(t, err)
+		return result
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, allow("a").Allowed, "the burst is allowed at once")
+	}
+	result := allow("a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.True(t, allow("b").Allowed, "every key has its own bucket")
+
+	c.now = c.now.Add(500 * time.Millisecond)
+	assert.True(t, allow("a").Allowed, "a token is added every 1/rate")
+	assert.False(t, allow("a").Allowed)
+
+	c.now = c.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, allow("a").Allowed, "the bucket doesn't get more than the burst")
+	}
+	assert.False(t, allow("a").Allowed)
+	assert.Len(t, limiter.buckets, 1, "the refilled buckets are removed")
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter, _ := newTestMemoryLimiter()
+	rateLimiter := New(limiter, Policy{
+		Read:           Limit{Rate: 1, Burst: 2},
+		Write:          Limit{Rate: 1, Burst: 1},
+		Routes:         map[string]Limit{"GET /users/events": {Rate: 1, Burst: 1}},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+	})
+	reader := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "reader"})
+	allowed := func(ctx context.Context, route string, write bool, remoteAddr string, forwardedFor ...string) bool {
+		result, err := rateLimiter.Allow(ctx, route, write, remoteAddr, forwardedFor)
+		require.NoError(t, err)
+		return result.Allowed
+	}
+
+	assert.True(t, allowed(reader, "GET /users", false, "10.0.0.1:1234"))
+	assert.True(t, allowed(reader, "GET /users", false, "10.0.0.2:1234"), "the principal is the caller, not the address")
+	assert.False(t, allowed(reader, "/users.Users/GetUsers", false, "10.0.0.1:1234"), "the read budget is shared by the transports")
+	assert.False(t, allowed(domain.WithTenant(reader, "acme"), "GET /users", false, "10.0.0.1:1234"),
+		"the read budget is shared by the tenants")
+	assert.True(t, allowed(reader, "POST /users", true, "10.0.0.1:1234"), "the write budget is separate")
+	assert.True(t, allowed(reader, "GET /users/events", false, "10.0.0.1:1234"), "the route has its own budget")
+	assert.False(t, allowed(reader, "GET /users/events", false, "10.0.0.1:1234"))
+
+	assert.True(t, allowed(context.Background(), "GET /users", false, "10.0.0.1:1234"), "anonymous callers have their own budgets")
+	assert.True(t, allowed(context.Background(), "GET /users", false, "10.0.0.1:5678"))
+	assert.False(t, allowed(context.Background(), "GET /users", false, "10.0.0.1:9012"), "the port is not a part of the caller")
+
+	assert.True(t, allowed(context.Background(), "GET /users", false, "10.1.0.1:1234", "203.0.113.7"))
+	assert.True(t, allowed(context.Background(), "GET /users", false, "10.1.0.2:1234", "203.0.113.7"))
+	assert.False(t, allowed(context.Background(), "GET /users", false, "10.1.0.1:1234", "203.0.113.7"),
+		"the clients behind the trusted proxy are told apart by X-Forwarded-For")
+	assert.True(t, allowed(context.Background(), "GET /users", false, "10.1.0.1:1234", "203.0.113.8"))
+}
+
+func TestCallerKey(t *testing.T) {
+	withIdentity := auth.WithClientIdentity(context.Background(), auth.ClientIdentity{CommonName: "billing"})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		clientIP string
+		want     string
+	}{
+		{name: "principal", ctx: auth.WithPrincipal(withIdentity, auth.Principal{Subject: "reporting"}), want: "principal:reporting"},
+		{name: "principal_of_tenant", ctx: auth.WithPrincipal(domain.WithTenant(withIdentity, "acme"), auth.Principal{Subject: "reporting"}), want: "principal:reporting"},
+		{name: "client_certificate", ctx: withIdentity, clientIP: "10.0.0.1", want: "client:billing"},
+		{name: "ip", ctx: domain.WithTenant(context.Background(), "acme"), clientIP: "::1", want: "ip:::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, callerKey(tt.ctx, tt.clientIP))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00::/8")}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "remote_address", remoteAddr: "[::1]:1234", want: "::1"},
+		{name: "address_without_port", remoteAddr: "10.0.0.1", want: "10.0.0.1"},
+		{name: "untrusted_proxy", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"203.0.113.7"}, want: "10.0.0.1"},
+		{name: "trusted_proxy", remoteAddr: "10.1.0.1:1234", forwardedFor: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "chain_of_trusted_proxies", remoteAddr: "10.1.0.1:1234", forwardedFor: []string{"203.0.113.7, 10.1.0.9", "fd00::2"}, want: "203.0.113.7"},
+		{name: "forged_entries", remoteAddr: "10.1.0.1:1234", forwardedFor: []string{"10.1.0.5, 198.51.100.1, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "invalid_entry", remoteAddr: "10.1.0.1:1234", forwardedFor: []string{"203.0.113.7, unknown"}, want: "10.1.0.1"},
+		{name: "all_trusted", remoteAddr: "10.1.0.1:1234", forwardedFor: []string{"10.1.0.2"}, want: "10.1.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, clientIP(tt.remoteAddr, tt.forwardedFor, trustedProxies))
+		})
+	}
+}
+
+// limiterStub returns the configured result and error, the calls are counted
+type limiterStub struct {
+	result Result
+	err    error
+	calls  int
+}
+
+func (l *limiterStub) Allow(context.Context, string, Limit) (Result, error) {
+	l.calls++
+	return l.result, l.err
+}
+
+func TestFallback(t *testing.T) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	primary := &limiterStub{err: errors.New("connection refused")}
+	fallback := &limiterStub{result: Result{RetryAfter: time.Second}}
+	limiter := NewFallback(primary, fallback, 5*time.Second)
+	limiter.now = c.Now
+
+	result, err := limiter.Allow(context.Background(), "key", Limit{Rate: 1, Burst: 1})
+	require.NoError(t, err)
+	assert.Equal(t, Result{RetryAfter: time.Second}, result, "the fallback decides when the primary limiter fails")
+
+	c.now = c.now.Add(time.Second)
+	_, err = limiter.Allow(context.Background(), "key", Limit{Rate: 1, Burst: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, primary.calls, "the failing limiter is not called before the retry interval")
+	assert.Equal(t, 2, fallback.calls)
+
+	c.now = c.now.Add(5 * time.Second)
+	primary.err, primary.result = nil, Result{Allowed: true}
+	result, err = limiter.Allow(context.Background(), "key", Limit{Rate: 1, Burst: 1})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "the primary limiter is used again once it works")
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 2, fallback.calls)
+}
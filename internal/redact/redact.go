@@ -0,0 +1,215 @@
+// Package redact keeps the passwords and personal data out of the logs and the published events.
+//
+// The sensitive fields are marked with the redact struct tag:
+//   - redact:"secret" - credentials (passwords, hashes, keys), they are masked in the logs and never published
+//   - redact:"pii" - personal data (e.g. email), it can be hashed in the logs, see Options.HashPII
+//
+// Fields named like credentials (see SecretKeys) are treated as secrets even without the tag.
+// Types which need custom handling can implement Redactable.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Mask replaces the secrets in the logs
+const Mask = "******"
+
+// SecretKeys are the names of the fields which are always treated as secrets, no matter if they are tagged
+var SecretKeys = map[string]bool{
+	"password":      true,
+	"password_hash": true,
+	"passwordhash":  true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"authorization": true,
+}
+
+// PIIKeys are the names of the fields which are always treated as personal data, no matter if they are tagged
+var PIIKeys = map[string]bool{
+	"email": true,
+}
+
+// Redactable is implemented by the types which hide their sensitive data on their own.
+// Redacted returns the value which is safe to be logged or published.
+type Redactable interface {
+	Redacted() interface{}
+}
+
+type Options struct {
+	// HashPII replaces the personal data with its hash, so that the entries about the same person can be still correlated
+	HashPII bool
+}
+
+// IsSecret reports whether the field name is the name of a credential
+func IsSecret(key string) bool {
+	return SecretKeys[strings.ToLower(key)]
+}
+
+// IsPII reports whether the field name is the name of the personal data
+func IsPII(key string) bool {
+	return PIIKeys[strings.ToLower(key)]
+}
+
+// Hash returns a short, stable hash of the value
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(value)))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Value returns a copy of the value which is safe to be logged: structs become maps (keyed by their JSON names),
+// secrets are masked and the personal data is hashed when configured
+func Value(v interface{}, options Options) interface{} {
+	return walk(reflect.ValueOf(v), options, mask)
+}
+
+// Payload returns a copy of the value which is safe to be published outside of the application:
+// structs become maps (keyed by their JSON names) and the secrets are removed completely.
+// It's meant for the event payloads, so that the credentials can never reach the consumers.
+func Payload(v interface{}) map[string]interface{} {
+	payload, ok := walk(reflect.ValueOf(v), Options{}, omit).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+
+	return payload
+}
+
+type mode int
+
+const (
+	mask mode = iota
+	omit
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	redactableType = reflect.TypeOf((*Redactable)(nil)).Elem()
+)
+
+func walk(v reflect.Value, options Options, mode mode) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.Type().Implements(redactableType) && (v.Kind() != reflect.Pointer || !v.IsNil()) {
+		return walk(reflect.ValueOf(v.Interface().(Redactable).Redacted()), options, mode)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walk(v.Elem(), options, mode)
+	case reflect.Struct:
+		// values like time or uuid know how to present themselves
+		if v.Type() == timeType || v.Type().Implements(marshalerType) {
+			return v.Interface()
+		}
+		return walkStruct(v, options, mode)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		ret := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if value, ok := redactField(key, "", iter.Value(), options, mode); ok {
+				ret[key] = value
+			}
+		}
+		return ret
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		// byte arrays (e.g. uuid) are presented as they are
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		ret := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			ret[i] = walk(v.Index(i), options, mode)
+		}
+		return ret
+	default:
+		return v.Interface()
+	}
+}
+
+func walkStruct(v reflect.Value, options Options, mode mode) map[string]interface{} {
+	ret := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		// embedded structs are flattened, the same way encoding/json does it
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			for key, value := range walkStruct(v.Field(i), options, mode) {
+				ret[key] = value
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		key, omitEmpty := jsonName(field)
+		if key == "-" {
+			continue
+		}
+		if omitEmpty && v.Field(i).IsZero() {
+			continue
+		}
+
+		if value, ok := redactField(key, field.Tag.Get("redact"), v.Field(i), options, mode); ok {
+			ret[key] = value
+		}
+	}
+
+	return ret
+}
+
+// redactField returns the redacted value of the field, false means that the field should be left out
+func redactField(key, tag string, v reflect.Value, options Options, mode mode) (interface{}, bool) {
+	switch {
+	case tag == "secret" || IsSecret(key):
+		if mode == omit {
+			return nil, false
+		}
+		if v.IsZero() {
+			return "", true
+		}
+		return Mask, true
+	case (tag == "pii" || IsPII(key)) && options.HashPII && v.Kind() == reflect.String && v.String() != "":
+		return Hash(v.String()), true
+	case (tag == "pii" || IsPII(key)) && options.HashPII && v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.String:
+		return Hash(v.Elem().String()), true
+	default:
+		return walk(v, options, mode), true
+	}
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := strings.Split(field.Tag.Get("json"), ",")
+	name := tag[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	omitEmpty := false
+	for _, option := range tag[1:] {
+		omitEmpty = omitEmpty || option == "omitempty"
+	}
+
+	return name, omitEmpty
+}
@@ -0,0 +1,172 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"-" redact:"secret"`
+	Key      string `json:"key" redact:"secret"`
+}
+
+type account struct {
+	credentials
+	ID          uuid.UUID         `json:"id"`
+	Email       *string           `json:"email,omitempty" redact:"pii"`
+	Token       string            `json:"token"`
+	Nickname    string            `json:"nickname,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Credentials []credentials     `json:"credentials"`
+	Metadata    map[string]string `json:"metadata"`
+	Hidden      string            `json:"-"`
+	internal    string
+}
+
+// apiKey hides itself, no matter how it's named
+type apiKey string
+
+func (apiKey) Redacted() interface{} { return "api key" }
+func (k apiKey) String() string      { return string(k) }
+
+func TestValue(t *testing.T) {
+	email := "John@Doe.com"
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	value := account{
+		credentials: credentials{Login: "john", Password: "p4ssw0rd", Key: "k3y"},
+		ID:          id,
+		Email:       &email,
+		Token:       "t0k3n",
+		CreatedAt:   createdAt,
+		Credentials: []credentials{{Login: "admin", Key: "4dm1n"}},
+		Metadata:    map[string]string{"secret": "s3cr3t", "country": "UK"},
+		Hidden:      "hidden",
+		internal:    "internal",
+	}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		options Options
+		want    interface{}
+	}{
+		{
+			name:  "secrets are masked",
+			value: value,
+			want: map[string]interface{}{
+				"login":       "john",
+				"key":         Mask,
+				"id":          id,
+				"email":       email,
+				"token":       Mask,
+				"created_at":  createdAt,
+				"credentials": []interface{}{map[string]interface{}{"login": "admin", "key": Mask}},
+				"metadata":    map[string]interface{}{"secret": Mask, "country": "UK"},
+			},
+		},
+		{
+			name:    "personal data is hashed",
+			value:   &value,
+			options: Options{HashPII: true},
+			want: map[string]interface{}{
+				"login":       "john",
+				"key":         Mask,
+				"id":          id,
+				"email":       Hash("john@doe.com"),
+				"token":       Mask,
+				"created_at":  createdAt,
+				"credentials": []interface{}{map[string]interface{}{"login": "admin", "key": Mask}},
+				"metadata":    map[string]interface{}{"secret": Mask, "country": "UK"},
+			},
+		},
+		{
+			name:  "empty secrets stay empty",
+			value: credentials{Login: "john"},
+			want:  map[string]interface{}{"login": "john", "key": ""},
+		},
+		{
+			name:  "redactable",
+			value: map[string]interface{}{"key": apiKey("k3y")},
+			want:  map[string]interface{}{"key": "api key"},
+		},
+		{
+			name:  "nil",
+			value: nil,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Value(tt.value, tt.options))
+		})
+	}
+}
+
+func TestPayload(t *testing.T) {
+	email := "john@doe.com"
+	payload := Payload(account{
+		credentials: credentials{Login: "john", Password: "p4ssw0rd", Key: "k3y"},
+		Email:       &email,
+		Token:       "t0k3n",
+		Credentials: []credentials{{Login: "admin", Key: "4dm1n"}},
+		Metadata:    map[string]string{"api_key": "4p1k3y"},
+	})
+
+	assert.Equal(t, "john", payload["login"])
+	assert.Equal(t, email, payload["email"], "personal data is published")
+	for _, key := range []string{"key", "token", "password", "Password"} {
+		assert.NotContains(t, payload, key)
+	}
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	for _, secret := range []string{"p4ssw0rd", "k3y", "t0k3n", "4dm1n", "4p1k3y", Mask} {
+		assert.NotContains(t, string(body), secret)
+	}
+}
+
+func TestPayload_not_a_struct(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{}, Payload("value"))
+}
+
+func TestNewCore(t *testing.T) {
+	observed, logs := observer.New(zap.DebugLevel)
+	logger := zap.New(NewCore(observed, Options{HashPII: true})).
+		With(zap.String("Authorization", "Bearer t0k3n"))
+
+	logger.Info("entry",
+		zap.String("password", "p4ssw0rd"),
+		zap.String("email", "john@doe.com"),
+		zap.String("country", "UK"),
+		zap.Any("credentials", credentials{Login: "john", Password: "p4ssw0rd", Key: "k3y"}),
+		zap.Stringer("key", apiKey("k3y")),
+	)
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, Mask, fields["Authorization"])
+	assert.Equal(t, Mask, fields["password"])
+	assert.Equal(t, Hash("john@doe.com"), fields["email"])
+	assert.Equal(t, "UK", fields["country"])
+	assert.Equal(t, map[string]interface{}{"login": "john", "key": Mask}, fields["credentials"])
+	assert.Equal(t, "api key", fields["key"])
+}
+
+func TestNewCore_level(t *testing.T) {
+	observed, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(NewCore(observed, Options{}))
+
+	logger.Debug("skipped", zap.String("password", "p4ssw0rd"))
+	assert.False(t, logger.Core().Enabled(zapcore.DebugLevel))
+	assert.Zero(t, logs.Len())
+}
@@ -0,0 +1,61 @@
+package redact
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// core masks the secrets (and optionally hashes the personal data) in all the fields, before they get encoded
+type core struct {
+	zapcore.Core
+	options Options
+}
+
+// NewCore wraps the core, so that the fields logged with it are redacted:
+//   - fields named like secrets (e.g. zap.String("password", ...)) are masked
+//   - fields named like personal data (e.g. zap.String("email", ...)) are hashed when configured
+//   - structs logged with zap.Any / zap.Reflect are redacted with Value
+func NewCore(wrapped zapcore.Core, options Options) zapcore.Core {
+	return core{wrapped, options}
+}
+
+func (c core) With(fields []zapcore.Field) zapcore.Core {
+	return core{c.Core.With(c.redact(fields)), c.options}
+}
+
+func (c core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.redact(fields))
+}
+
+func (c core) redact(fields []zapcore.Field) []zapcore.Field {
+	ret := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		ret[i] = c.redactField(field)
+	}
+
+	return ret
+}
+
+func (c core) redactField(field zapcore.Field) zapcore.Field {
+	switch {
+	case IsSecret(field.Key):
+		return zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: Mask}
+	case IsPII(field.Key) && c.options.HashPII && field.Type == zapcore.StringType:
+		return zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: Hash(field.String)}
+	case field.Type == zapcore.ReflectType:
+		return zapcore.Field{Key: field.Key, Type: zapcore.ReflectType, Interface: Value(field.Interface, c.options)}
+	case field.Type == zapcore.StringerType:
+		if redactable, ok := field.Interface.(Redactable); ok {
+			return zapcore.Field{Key: field.Key, Type: zapcore.ReflectType, Interface: Value(redactable, c.options)}
+		}
+	}
+
+	return field
+}
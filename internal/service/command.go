@@ -8,7 +8,7 @@ import (
 // UsersCommandService is used to add, modify and delete users
 type UsersCommandService interface {
 	AddUser(context.Context, AddUserCommand) (domain.User, error)
-	ModifyUser(context.Context, ModifyUserCommand) error
+	ModifyUser(context.Context, ModifyUserCommand) (domain.User, error)
 	DeleteUser(context.Context, DeleteUserCommand) error
 }
 
@@ -21,22 +21,30 @@ func NewUserCommandService(repo domain.Repository) UsersCommandService {
 }
 
 // AddUserCommand is used to add a new user
+// Password is never serialized, as commands are published to other services within events
 type AddUserCommand struct {
-	FirstName string
-	LastName  string
-	Nickname  string
-	Password  string
-	Email     string
-	Country   string
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+	Password  string `json:"-" redact:"secret"`
+	Email     string `json:"email" redact:"pii"`
+	Country   string `json:"country"`
+	// IdempotencyKey is optional, see CommandIdempotencyWrapper
+	IdempotencyKey string `json:"-"`
 }
 
+// AddUser adds the user to the tenant of the request, the email needs to be unique within the tenant
 func (u userCommandService) AddUser(ctx context.Context, toAdd AddUserCommand) (domain.User, error) {
-	user, err := domain.NewUser(toAdd.FirstName, toAdd.LastName, toAdd.Nickname, toAdd.Password, toAdd.Email, toAdd.Country)
+	user, err := domain.NewUser(
+		domain.TenantFromContext(ctx),
+		toAdd.FirstName, toAdd.LastName, toAdd.Nickname, toAdd.Password, toAdd.Email, toAdd.Country,
+	)
 	if err != nil {
 		return domain.User{}, err
 	}
 
-	users, err := u.userRepository.Users(domain.NewFilterEmail(toAdd.Email), domain.DefaultPagination)
+	// the check can't be served by a replica, which could be missing a user added a moment ago
+	users, err := u.userRepository.Users(domain.WithPrimaryReads(ctx), domain.NewFilterEmail(toAdd.Email), domain.DefaultPagination)
 	if err != nil {
 		return domain.User{}, err
 	}
@@ -45,7 +53,7 @@ func (u userCommandService) AddUser(ctx context.Context, toAdd AddUserCommand) (
 		return domain.User{}, domain.ErrEmailExists
 	}
 
-	err = u.userRepository.AddUser(user)
+	err = u.userRepository.AddUser(ctx, user)
 	if err != nil {
 		// todo - handle errors
 		return domain.User{}, err
@@ -58,12 +66,12 @@ func (u userCommandService) AddUser(ctx context.Context, toAdd AddUserCommand) (
 // I am assuming that password modifications should not be possible from this command,
 // since it's a security risk and it would probably require some additional checks
 type ModifyUserCommand struct {
-	ID        domain.UserID
-	FirstName *string
-	LastName  *string
-	Nickname  *string
-	Email     *string
-	Country   *string
+	ID        domain.UserID `json:"id"`
+	FirstName *string       `json:"first_name,omitempty"`
+	LastName  *string       `json:"last_name,omitempty"`
+	Nickname  *string       `json:"nickname,omitempty"`
+	Email     *string       `json:"email,omitempty" redact:"pii"`
+	Country   *string       `json:"country,omitempty"`
 }
 
 // fieldsToUpdate returns a map of fields to update
@@ -85,20 +93,16 @@ func (c ModifyUserCommand) fieldsToUpdate() domain.Fields {
 	return fields
 }
 
-func (u userCommandService) ModifyUser(ctx context.Context, toModify ModifyUserCommand) error {
-	err := u.userRepository.ModifyUser(toModify.ID, toModify.fieldsToUpdate())
-	if err != nil {
-		return err
-	}
-
-	return nil
+// ModifyUser returns the user after the modification
+func (u userCommandService) ModifyUser(ctx context.Context, toModify ModifyUserCommand) (domain.User, error) {
+	return u.userRepository.ModifyUser(ctx, toModify.ID, toModify.fieldsToUpdate())
 }
 
 // DeleteUserCommand is used to delete a user given the user exists
 type DeleteUserCommand struct {
-	ID domain.UserID
+	ID domain.UserID `json:"id"`
 }
 
 func (u userCommandService) DeleteUser(ctx context.Context, toDelete DeleteUserCommand) error {
-	return u.userRepository.RemoveUser(toDelete.ID)
+	return u.userRepository.RemoveUser(ctx, toDelete.ID)
 }
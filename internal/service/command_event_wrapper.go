@@ -2,23 +2,48 @@ package service
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"sync"
 	"users-app/domain"
+	"users-app/logging"
+
+	"go.uber.org/zap"
 )
 
 // CommandEventsWrapper is a wrapper around UsersCommandService that logs and publishes events based on commands.
 // It is used to decouple the application from the event publisher and logger.
 // As application uses CQRS patter, the Commands are the only way to change the state of the application.
-// Therefore, every command triggers an event - which symbolizes a change in the application's state.
-// The event is then published to the Redis channel and logged to the logs/event.log file.
+// Therefore, every successful command triggers an event - which symbolizes a change in the application's state.
+// The event is then published to the Redis channel and logged to the logs/event.log file. The failed commands
+// change nothing, so they trigger no events.
+//
+// Events are published asynchronously, Wait should be called before the application exits,
+// so that the pending events are not lost.
 type CommandEventsWrapper struct {
 	publisher   domain.Publisher
 	wrapped     UsersCommandService
 	eventLogger eventLogger
+	pending     *sync.WaitGroup
+}
+
+func NewCommandEventsWrapper(publisher domain.Publisher, wrapped UsersCommandService, logger eventLogger) CommandEventsWrapper {
+	return CommandEventsWrapper{publisher, wrapped, logger, &sync.WaitGroup{}}
 }
 
-func NewCommandEventsWrapper(publisher domain.Publisher, wrapped UsersCommandService, logger eventLogger) UsersCommandService {
-	return CommandEventsWrapper{publisher, wrapped, logger}
+// Wait blocks until all the pending events are published, or the context is done
+func (c CommandEventsWrapper) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("events still pending: %w", ctx.Err())
+	}
 }
 
 // eventLogger is a specialized logger for logging events and storing them in the logs/event.log file.
@@ -33,17 +58,23 @@ func (c AddUserCommand) EncodeEvent() (domain.Event, error) {
 }
 
 func (c CommandEventsWrapper) AddUser(ctx context.Context, command AddUserCommand) (domain.User, error) {
-	c.publishEvent(ctx, command)
-	return c.wrapped.AddUser(ctx, command)
+	user, err := c.wrapped.AddUser(ctx, command)
+	if err == nil {
+		c.publishEvent(ctx, command, user.ID)
+	}
+	return user, err
 }
 
 func (c ModifyUserCommand) EncodeEvent() (domain.Event, error) {
 	return domain.Event{Msg: domain.UserModified, Command: c}, nil
 }
 
-func (c CommandEventsWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) error {
-	c.publishEvent(ctx, command)
-	return c.wrapped.ModifyUser(ctx, command)
+func (c CommandEventsWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) (domain.User, error) {
+	user, err := c.wrapped.ModifyUser(ctx, command)
+	if err == nil {
+		c.publishEvent(ctx, command, command.ID)
+	}
+	return user, err
 }
 
 func (c DeleteUserCommand) EncodeEvent() (domain.Event, error) {
@@ -51,24 +82,35 @@ func (c DeleteUserCommand) EncodeEvent() (domain.Event, error) {
 }
 
 func (c CommandEventsWrapper) DeleteUser(ctx context.Context, command DeleteUserCommand) error {
-	c.publishEvent(ctx, command)
-	return c.wrapped.DeleteUser(ctx, command)
+	err := c.wrapped.DeleteUser(ctx, command)
+	if err == nil {
+		c.publishEvent(ctx, command, command.ID)
+	}
+	return err
 }
 
 // publishEvent is a helper function that publishes an event to the publisher without blocking the execution of the command.
 // It logs the event and publishes it asynchronously. Errors during publishing are logged but do not block the command execution.
 // This is a tradeoff, and future versions of the code may implement a separate goroutine, event buffering, and a retry mechanism.
-func (c CommandEventsWrapper) publishEvent(ctx context.Context, command domain.Command) {
+func (c CommandEventsWrapper) publishEvent(ctx context.Context, command domain.Command, id domain.UserID) {
 	event, err := command.EncodeEvent()
 	if err != nil {
-		log.Printf("error publishing event: %v", err)
+		logging.FromContext(ctx).Error("failed to encode event", zap.Error(err))
 	}
+	event.TenantID = domain.TenantFromContext(ctx)
+	event.UserID = id
 	c.eventLogger.LogEvent(event)
 
+	// the event is published after the request is finished, so the request cancellation must not cancel publishing
+	publishCtx := context.WithoutCancel(ctx)
+
+	c.pending.Add(1)
 	go func() {
-		err = c.publisher.PublishEvent(ctx, event)
+		defer c.pending.Done()
+
+		err := c.publisher.PublishEvent(publishCtx, event)
 		if err != nil {
-			log.Printf("error publishing event: %v", err)
+			logging.FromContext(publishCtx).Error("failed to publish event", zap.String("event", string(event.Msg)), zap.Error(err))
 		}
 	}()
 
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandEventsWrapper_publishes_after_the_command_succeeds(t *testing.T) {
+	ctx := context.Background()
+	recorder := &eventsRecorder{}
+	svc := NewCommandEventsWrapper(recorder, NewUserCommandService(repositoryStub{}), recorder)
+
+	user, err := svc.AddUser(ctx, AddUserCommand{FirstName: "John", Email: "john@doe.com", Password: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, svc.Wait(ctx))
+
+	require.Len(t, recorder.events, 2, "the event is logged and published")
+	assert.Contains(t, recorder.events[0], `"id":"`+user.ID.String()+`"`, "the event carries the id of the added user")
+}
+
+func TestCommandEventsWrapper_failed_commands_publish_nothing(t *testing.T) {
+	ctx := context.Background()
+	recorder := &eventsRecorder{}
+	svc := NewCommandEventsWrapper(recorder, NewUserCommandService(repositoryStub{err: errors.New("connection refused")}), recorder)
+
+	_, err := svc.AddUser(ctx, AddUserCommand{FirstName: "John", Email: "john@doe.com", Password: "secret"})
+	require.Error(t, err)
+	_, err = svc.ModifyUser(ctx, ModifyUserCommand{ID: domain.NewUserID()})
+	require.Error(t, err)
+	require.Error(t, svc.DeleteUser(ctx, DeleteUserCommand{ID: domain.NewUserID()}))
+	require.NoError(t, svc.Wait(ctx))
+
+	assert.Empty(t, recorder.events)
+}
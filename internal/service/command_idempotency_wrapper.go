@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+	"users-app/domain"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+const addUserOperation = "add-user"
+
+// CommandIdempotencyWrapper is a wrapper around UsersCommandService which makes AddUser idempotent
+// for the commands carrying an idempotency key.
+//
+// Clients retrying a request after e.g. a timeout get the result of the original request, instead of creating
+// a duplicate (or getting a confusing "email already exists" error). The wrapped service is not called again,
+// so no duplicate events are published - that's why this wrapper needs to be the outermost one.
+// Reusing the key with a different payload results in domain.ErrIdempotencyKeyMismatch, the malformed keys
+// (see domain.ValidateIdempotencyKey) in domain.ErrInvalidIdempotencyKey.
+// The keys are scoped by the tenant, the tenants can't see the results of each other.
+//
+// The key is reserved for ReservationTTL while the command is executed, the retries get
+// domain.ErrIdempotencyKeyInProgress until then. A reservation left behind by a request which never finished
+// (e.g. the process crashed) is taken over by the next retry once it has ended, so it mustn't be shorter than
+// the execution of the command.
+type CommandIdempotencyWrapper struct {
+	wrapped UsersCommandService
+	repo    domain.IdempotencyRepository
+	config  IdempotencyConfig
+}
+
+type IdempotencyConfig struct {
+	// KeyTTL is the time of remembering the keys
+	KeyTTL time.Duration
+	// ReservationTTL is the time of reserving the keys of the commands in progress
+	ReservationTTL time.Duration
+}
+
+// NewCommandIdempotencyWrapper creates the wrapper, the idempotency keys are remembered for config.KeyTTL
+func NewCommandIdempotencyWrapper(repo domain.IdempotencyRepository, wrapped UsersCommandService, config IdempotencyConfig) UsersCommandService {
+	return CommandIdempotencyWrapper{wrapped, repo, config}
+}
+
+// fingerprint identifies the payload of the command, it doesn't include the idempotency key itself
+func (c AddUserCommand) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q|%q|%q|%q|%q|%q", c.FirstName, c.LastName, c.Nickname, c.Password, c.Email, c.Country)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c CommandIdempotencyWrapper) AddUser(ctx context.Context, command AddUserCommand) (domain.User, error) {
+	if command.IdempotencyKey == "" {
+		return c.wrapped.AddUser(ctx, command)
+	}
+	if err := domain.ValidateIdempotencyKey(command.IdempotencyKey); err != nil {
+		return domain.User{}, err
+	}
+
+	// the records keep the timestamps with the precision of microseconds, the reservation is found by CreatedAt
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	record := domain.IdempotencyRecord{
+		Operation:     addUserOperation + ":" + string(domain.TenantFromContext(ctx)),
+		Key:           command.IdempotencyKey,
+		Fingerprint:   command.fingerprint(),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(c.config.KeyTTL),
+		ReservedUntil: now.Add(c.config.ReservationTTL),
+	}
+	existing, reserved, err := c.repo.Reserve(ctx, record)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if !reserved {
+		switch {
+		case !existing.Matches(command.fingerprint()):
+			return domain.User{}, domain.ErrIdempotencyKeyMismatch
+		case !existing.Completed:
+			return domain.User{}, domain.ErrIdempotencyKeyInProgress
+		default:
+			return existing.User, nil
+		}
+	}
+
+	// the reservation is settled even when the request is canceled, so that the retries don't wait for it to end
+	settleCtx := context.WithoutCancel(ctx)
+	user, err := c.wrapped.AddUser(ctx, command)
+	if err != nil {
+		// only successful results are remembered, so the client can retry a failed request with the same key
+		if releaseErr := c.repo.Release(settleCtx, record); releaseErr != nil {
+			logging.FromContext(ctx).Error("failed to release idempotency key", zap.Error(releaseErr))
+		}
+		return domain.User{}, err
+	}
+
+	if err := c.repo.Complete(settleCtx, record, user); err != nil {
+		// the user is already created, so the error is not returned to the client
+		logging.FromContext(ctx).Error("failed to store idempotent request result", logging.UserID(user.ID), zap.Error(err))
+	}
+
+	return user, nil
+}
+
+func (c CommandIdempotencyWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) (domain.User, error) {
+	return c.wrapped.ModifyUser(ctx, command)
+}
+
+func (c CommandIdempotencyWrapper) DeleteUser(ctx context.Context, command DeleteUserCommand) error {
+	return c.wrapped.DeleteUser(ctx, command)
+}
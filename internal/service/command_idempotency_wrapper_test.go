@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type idempotencyRepositoryStub struct {
+	records map[string]domain.IdempotencyRecord
+}
+
+func (r *idempotencyRepositoryStub) Reserve(_ context.Context, record domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	if existing, ok := r.records[record.Key]; ok && !existing.Reservable(record.CreatedAt) {
+		return existing, false, nil
+	}
+
+	r.records[record.Key] = record
+	return record, true, nil
+}
+
+func (r *idempotencyRepositoryStub) Complete(_ context.Context, reserved domain.IdempotencyRecord, user domain.User) error {
+	record := r.records[reserved.Key]
+	record.Completed = true
+	record.User = user
+	r.records[reserved.Key] = record
+	return nil
+}
+
+func (r *idempotencyRepositoryStub) Release(_ context.Context, record domain.IdempotencyRecord) error {
+	delete(r.records, record.Key)
+	return nil
+}
+
+// addUserStub counts the calls and returns the configured results
+type addUserStub struct {
+	UsersCommandService
+	calls int
+	users []domain.User
+	errs  []error
+}
+
+func (s *addUserStub) AddUser(context.Context, AddUserCommand) (domain.User, error) {
+	s.calls++
+	return s.users[s.calls-1], s.errs[s.calls-1]
+}
+
+func TestCommandIdempotencyWrapper_AddUser(t *testing.T) {
+	user1 := domain.User{ID: domain.NewUserID(), Email: "john@doe.com"}
+	user2 := domain.User{ID: domain.NewUserID(), Email: "john@doe.com"}
+	errFailed := errors.New("failed")
+
+	command := AddUserCommand{Email: "john@doe.com", Password: "secret", IdempotencyKey: "key"}
+	otherPayload := command
+	otherPayload.Password = "other"
+	noKey := command
+	noKey.IdempotencyKey = ""
+	tooLongKey := command
+	tooLongKey.IdempotencyKey = strings.Repeat("k", 256)
+
+	tests := []struct {
+		name     string
+		commands []AddUserCommand
+
+		wrappedUsers []domain.User
+		wrappedErrs  []error
+
+		expectedUsers []domain.User
+		expectedErrs  []error
+		expectedCalls int
+	}{
+		{
+			name:          "repeated_request_returns_original_result",
+			commands:      []AddUserCommand{command, command},
+			wrappedUsers:  []domain.User{user1},
+			wrappedErrs:   []error{nil},
+			expectedUsers: []domain.User{user1, user1},
+			expectedErrs:  []error{nil, nil},
+			expectedCalls: 1,
+		},
+		{
+			name:          "key_reused_with_different_payload",
+			commands:      []AddUserCommand{command, otherPayload},
+			wrappedUsers:  []domain.User{user1},
+			wrappedErrs:   []error{nil},
+			expectedUsers: []domain.User{user1, {}},
+			expectedErrs:  []error{nil, domain.ErrIdempotencyKeyMismatch},
+			expectedCalls: 1,
+		},
+		{
+			name:          "failed_request_can_be_retried",
+			commands:      []AddUserCommand{command, command},
+			wrappedUsers:  []domain.User{{}, user1},
+			wrappedErrs:   []error{errFailed, nil},
+			expectedUsers: []domain.User{{}, user1},
+			expectedErrs:  []error{errFailed, nil},
+			expectedCalls: 2,
+		},
+		{
+			name:          "invalid_key",
+			commands:      []AddUserCommand{tooLongKey},
+			expectedUsers: []domain.User{{}},
+			expectedErrs:  []error{domain.ErrInvalidIdempotencyKey},
+			expectedCalls: 0,
+		},
+		{
+			name:          "requests_without_key_are_not_deduplicated",
+			commands:      []AddUserCommand{noKey, noKey},
+			wrappedUsers:  []domain.User{user1, user2},
+			wrappedErrs:   []error{nil, nil},
+			expectedUsers: []domain.User{user1, user2},
+			expectedErrs:  []error{nil, nil},
+			expectedCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := &addUserStub{users: tt.wrappedUsers, errs: tt.wrappedErrs}
+			repo := &idempotencyRepositoryStub{records: map[string]domain.IdempotencyRecord{}}
+			svc := NewCommandIdempotencyWrapper(repo, wrapped, testIdempotencyConfig)
+
+			for i, command := range tt.commands {
+				user, err := svc.AddUser(context.Background(), command)
+				assert.Equal(t, tt.expectedUsers[i], user)
+				assert.Equal(t, tt.expectedErrs[i], err)
+			}
+			assert.Equal(t, tt.expectedCalls, wrapped.calls)
+		})
+	}
+}
+
+var testIdempotencyConfig = IdempotencyConfig{KeyTTL: time.Hour, ReservationTTL: time.Minute}
+
+func TestCommandIdempotencyWrapper_AddUser_request_in_progress(t *testing.T) {
+	command := AddUserCommand{Email: "john@doe.com", IdempotencyKey: "key"}
+	user := domain.User{ID: domain.NewUserID()}
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		reservedUntil time.Time
+
+		expectedErr   error
+		expectedCalls int
+	}{
+		{name: "reserved", reservedUntil: now.Add(time.Minute), expectedErr: domain.ErrIdempotencyKeyInProgress},
+		{name: "reservation_ended", reservedUntil: now.Add(-time.Second), expectedCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &idempotencyRepositoryStub{records: map[string]domain.IdempotencyRecord{
+				"key": {Key: "key", Fingerprint: command.fingerprint(), ExpiresAt: now.Add(time.Hour), ReservedUntil: tt.reservedUntil},
+			}}
+			wrapped := &addUserStub{users: []domain.User{user}, errs: []error{nil}}
+
+			_, err := NewCommandIdempotencyWrapper(repo, wrapped, testIdempotencyConfig).AddUser(context.Background(), command)
+
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expectedCalls, wrapped.calls)
+		})
+	}
+}
+
+func TestCommandIdempotencyWrapper_AddUser_keys_are_scoped_by_tenant(t *testing.T) {
+	repo := &idempotencyRepositoryStub{records: map[string]domain.IdempotencyRecord{}}
+	wrapped := &addUserStub{users: []domain.User{{ID: domain.NewUserID()}}, errs: []error{nil}}
+	ctx := domain.WithTenant(context.Background(), "arcade")
+
+	_, err := NewCommandIdempotencyWrapper(repo, wrapped, testIdempotencyConfig).AddUser(ctx, AddUserCommand{IdempotencyKey: "key"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "add-user:arcade", repo.records["key"].Operation)
+}
@@ -2,62 +2,61 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"users-app/domain"
+	"users-app/logging"
 
 	"go.uber.org/zap"
 )
 
 // CommandLoggingWrapper is a wrapper for UsersCommandService
-// It logs the commands received and the errors returned
-// It's purpose is to decouple the application from the logger
+// It logs the commands received and the errors returned, with the logger from the context,
+// so that the entries can be correlated with the request that triggered the command
 type CommandLoggingWrapper struct {
 	wrapped UsersCommandService
-	logger  Logger
 }
 
-func NewCommandLoggingWrapper(logger Logger, wrapped UsersCommandService) UsersCommandService {
-	return CommandLoggingWrapper{wrapped, logger}
+func NewCommandLoggingWrapper(wrapped UsersCommandService) UsersCommandService {
+	return CommandLoggingWrapper{wrapped}
 }
 
 func (c CommandLoggingWrapper) AddUser(ctx context.Context, command AddUserCommand) (domain.User, error) {
-	c.logger.Info(fmt.Sprintf("AddUser command received: %v", command))
+	logger := logging.FromContext(ctx).With(zap.String("command", "AddUser"))
+	logger.Info("command received", zap.Any("payload", command))
+
 	u, err := c.wrapped.AddUser(ctx, command)
 	if err != nil {
-		c.logger.Error(fmt.Sprintf("AddUser command failed: %v", err))
+		logger.Error("command failed", zap.Error(err))
 		return domain.User{}, err
 	}
 
+	logger.Info("command executed", logging.UserID(u.ID))
 	return u, nil
 }
 
-func (c CommandLoggingWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) error {
-	c.logger.Info(fmt.Sprintf("ModifyUser command received: %v", command))
-	err := c.wrapped.ModifyUser(ctx, command)
+func (c CommandLoggingWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) (domain.User, error) {
+	logger := logging.FromContext(ctx).With(zap.String("command", "ModifyUser"), logging.UserID(command.ID))
+	logger.Info("command received", zap.Any("payload", command))
+
+	u, err := c.wrapped.ModifyUser(ctx, command)
 	if err != nil {
-		c.logger.Error(fmt.Sprintf("ModifyUser command failed: %v", err))
-		return err
+		logger.Error("command failed", zap.Error(err))
+		return domain.User{}, err
 	}
 
-	return nil
+	logger.Info("command executed")
+	return u, nil
 }
 
 func (c CommandLoggingWrapper) DeleteUser(ctx context.Context, command DeleteUserCommand) error {
-	c.logger.Info(fmt.Sprintf("DeleteUser command received: %v", command))
+	logger := logging.FromContext(ctx).With(zap.String("command", "DeleteUser"), logging.UserID(command.ID))
+	logger.Info("command received")
+
 	err := c.wrapped.DeleteUser(ctx, command)
 	if err != nil {
-		c.logger.Error(fmt.Sprintf("DeleteUser command failed: %v", err))
+		logger.Error("command failed", zap.Error(err))
 		return err
 	}
 
+	logger.Info("command executed")
 	return nil
-
-}
-
-type Logger interface {
-	Debug(msg string, fields ...zap.Field)
-	Info(msg string, fields ...zap.Field)
-	Warn(msg string, fields ...zap.Field)
-	Error(msg string, fields ...zap.Field)
-	Fatal(msg string, fields ...zap.Field)
 }
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"time"
+	"users-app/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CommandMetricsWrapper is a wrapper for UsersCommandService
+// It counts the executed commands and measures their duration, per command and result (success / failure)
+type CommandMetricsWrapper struct {
+	wrapped  UsersCommandService
+	total    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewCommandMetricsWrapper(registerer prometheus.Registerer, wrapped UsersCommandService) UsersCommandService {
+	total := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "users_commands_total",
+		Help: "Number of executed commands, by command and result.",
+	}, []string{"command", "result"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "users_command_duration_seconds",
+		Help:    "Duration of the commands execution, by command.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command"})
+	registerer.MustRegister(total, duration)
+
+	return CommandMetricsWrapper{wrapped, total, duration}
+}
+
+func (c CommandMetricsWrapper) AddUser(ctx context.Context, command AddUserCommand) (domain.User, error) {
+	defer c.observe("AddUser", time.Now())
+	u, err := c.wrapped.AddUser(ctx, command)
+	c.count("AddUser", err)
+
+	return u, err
+}
+
+func (c CommandMetricsWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) (domain.User, error) {
+	defer c.observe("ModifyUser", time.Now())
+	u, err := c.wrapped.ModifyUser(ctx, command)
+	c.count("ModifyUser", err)
+
+	return u, err
+}
+
+func (c CommandMetricsWrapper) DeleteUser(ctx context.Context, command DeleteUserCommand) error {
+	defer c.observe("DeleteUser", time.Now())
+	err := c.wrapped.DeleteUser(ctx, command)
+	c.count("DeleteUser", err)
+
+	return err
+}
+
+func (c CommandMetricsWrapper) observe(command string, start time.Time) {
+	c.duration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+}
+
+func (c CommandMetricsWrapper) count(command string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	c.total.WithLabelValues(command, result).Inc()
+}
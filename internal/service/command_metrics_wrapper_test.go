@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"users-app/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// commandServiceStub returns the configured error from every command
+type commandServiceStub struct {
+	err error
+}
+
+func (s commandServiceStub) AddUser(context.Context, AddUserCommand) (domain.User, error) {
+	return domain.User{}, s.err
+}
+
+func (s commandServiceStub) ModifyUser(context.Context, ModifyUserCommand) (domain.User, error) {
+	return domain.User{}, s.err
+}
+
+func (s commandServiceStub) DeleteUser(context.Context, DeleteUserCommand) error {
+	return s.err
+}
+
+func TestCommandMetricsWrapper(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	stub := &commandServiceStub{}
+	wrapper := NewCommandMetricsWrapper(registry, stub).(CommandMetricsWrapper)
+
+	_, _ = wrapper.AddUser(ctx, AddUserCommand{})
+	_, _ = wrapper.ModifyUser(ctx, ModifyUserCommand{})
+	stub.err = errors.New("failed")
+	_, _ = wrapper.AddUser(ctx, AddUserCommand{})
+	_ = wrapper.DeleteUser(ctx, DeleteUserCommand{})
+	_ = wrapper.DeleteUser(ctx, DeleteUserCommand{})
+
+	tests := []struct {
+		command string
+		result  string
+		want    float64
+	}{
+		{"AddUser", "success", 1},
+		{"AddUser", "failure", 1},
+		{"ModifyUser", "success", 1},
+		{"ModifyUser", "failure", 0},
+		{"DeleteUser", "success", 0},
+		{"DeleteUser", "failure", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command+"_"+tt.result, func(t *testing.T) {
+			assert.Equal(t, tt.want, testutil.ToFloat64(wrapper.total.WithLabelValues(tt.command, tt.result)))
+		})
+	}
+	assert.Equal(t, 3, testutil.CollectAndCount(registry, "users_command_duration_seconds"))
+}
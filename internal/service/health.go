@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	"users-app/logging"
+
+	"go.uber.org/zap"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "up"
+	HealthStatusDown HealthStatus = "down"
+)
+
+// HealthService checks if the dependencies of the application (database, message broker etc.) are reachable
+type HealthService interface {
+	Check(context.Context) HealthReport
+}
+
+// HealthCheck checks a single dependency, it should return an error when the dependency is not usable
+type HealthCheck struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+// DependencyHealth is the result of a single HealthCheck
+type DependencyHealth struct {
+	Status HealthStatus
+	// Error is logged by the check, it can reveal the internals (addresses, credentials in the DSN), so it's not
+	// meant to be returned to the clients
+	Error    string
+	Duration time.Duration
+}
+
+// HealthReport is the result of all the health checks, the application is up only when all the dependencies are up
+type HealthReport struct {
+	Status       HealthStatus
+	Dependencies map[string]DependencyHealth
+}
+
+func (r HealthReport) Healthy() bool {
+	return r.Status == HealthStatusUp
+}
+
+type healthService struct {
+	checks  []HealthCheck
+	timeout time.Duration
+}
+
+// NewHealthService creates a HealthService, which runs all the checks concurrently,
+// a check which takes longer than the timeout is reported as down
+func NewHealthService(timeout time.Duration, checks ...HealthCheck) HealthService {
+	return healthService{checks: checks, timeout: timeout}
+}
+
+func (h healthService) Check(ctx context.Context) HealthReport {
+	report := HealthReport{
+		Status:       HealthStatusUp,
+		Dependencies: make(map[string]DependencyHealth, len(h.checks)),
+	}
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			result := h.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[check.Name] = result
+			if result.Status != HealthStatusUp {
+				report.Status = HealthStatusDown
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (h healthService) run(ctx context.Context, check HealthCheck) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make(chan error, 1)
+	// the check is not trusted to respect the context, so the timeout is enforced here as well
+	go func() { errs <- check.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := DependencyHealth{Status: HealthStatusUp, Duration: time.Since(start)}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+		logging.FromContext(ctx).Warn("dependency is down", zap.String("dependency", check.Name), zap.Error(err))
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthService_Check(t *testing.T) {
+	up := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+	hanging := func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tests := []struct {
+		name         string
+		checks       []HealthCheck
+		wantStatus   HealthStatus
+		wantFailures map[string]string
+	}{
+		{
+			name:       "no_dependencies",
+			wantStatus: HealthStatusUp,
+		},
+		{
+			name:       "all_up",
+			checks:     []HealthCheck{{"postgres", up}, {"redis", up}},
+			wantStatus: HealthStatusUp,
+		},
+		{
+			name:         "one_down",
+			checks:       []HealthCheck{{"postgres", up}, {"redis", down}},
+			wantStatus:   HealthStatusDown,
+			wantFailures: map[string]string{"redis": "connection refused"},
+		},
+		{
+			name:         "timeout",
+			checks:       []HealthCheck{{"postgres", hanging}, {"redis", up}},
+			wantStatus:   HealthStatusDown,
+			wantFailures: map[string]string{"postgres": context.DeadlineExceeded.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewHealthService(50*time.Millisecond, tt.checks...).Check(context.Background())
+
+			assert.Equal(t, tt.wantStatus, report.Status)
+			assert.Equal(t, tt.wantStatus == HealthStatusUp, report.Healthy())
+			assert.Len(t, report.Dependencies, len(tt.checks))
+			for _, check := range tt.checks {
+				dependency := report.Dependencies[check.Name]
+				if failure, ok := tt.wantFailures[check.Name]; ok {
+					assert.Equal(t, HealthStatusDown, dependency.Status)
+					assert.Equal(t, failure, dependency.Error)
+				} else {
+					assert.Equal(t, HealthStatusUp, dependency.Status)
+					assert.Empty(t, dependency.Error)
+				}
+			}
+		})
+	}
+}
@@ -7,14 +7,23 @@ import (
 
 type UsersQueryService interface {
 	Users(context.Context, domain.Filter, domain.Pagination) ([]domain.User, error)
+	CountryCounts(context.Context) ([]domain.CountryCount, error)
 }
 
 type UserQueryService struct {
 	userRepository domain.Repository
+	// readModel serves the queries instead of the repository, nil when there's none
+	readModel domain.UsersReadModel
 }
 
 func NewUserQueryService(repo domain.Repository) *UserQueryService {
-	return &UserQueryService{repo}
+	return &UserQueryService{userRepository: repo}
+}
+
+// NewProjectedUserQueryService reads the users from the read model. The queries with a consistency token go to
+// the repository, they need to see a write the read model could have not caught up with yet.
+func NewProjectedUserQueryService(repo domain.Repository, readModel domain.UsersReadModel) *UserQueryService {
+	return &UserQueryService{userRepository: repo, readModel: readModel}
 }
 
 // Users returns a list of users, filtered and paginated
@@ -22,5 +31,16 @@ func NewUserQueryService(repo domain.Repository) *UserQueryService {
 // In case of no filter passed, it will return all users (paginated) from the repository
 func (u UserQueryService) Users(ctx context.Context, f domain.Filter, p domain.Pagination) ([]domain.User, error) {
 	// todo - error handling
-	return u.userRepository.Users(f, p)
+	if u.readModel != nil && domain.ConsistencyTokenFromContext(ctx) == "" {
+		return u.readModel.Users(ctx, f, p)
+	}
+	return u.userRepository.Users(ctx, f, p)
+}
+
+// CountryCounts returns the number of the users of every country, ordered by the country
+func (u UserQueryService) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	if u.readModel != nil && domain.ConsistencyTokenFromContext(ctx) == "" {
+		return u.readModel.CountryCounts(ctx)
+	}
+	return u.userRepository.CountryCounts(ctx)
 }
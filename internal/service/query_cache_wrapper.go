@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"users-app/domain"
+	"users-app/logging"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// UsersCache keeps the results of the queries, it's expected to be shared by all the instances of the application
+type UsersCache interface {
+	// Get returns the value of the key, ok is false when there is none
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type QueryCacheConfig struct {
+	// UserTTL is the time of keeping the lookups of a single user, ListTTL of the rest of the queries
+	UserTTL time.Duration
+	ListTTL time.Duration
+}
+
+const (
+	userQuery = "user"
+	listQuery = "list"
+)
+
+// QueryCacheWrapper is a wrapper for UsersQueryService which caches the results of the queries. There are two kinds
+// of the entries:
+//   - the lookups of a single user by the email (the filter has only the email, the offset is 0), which are
+//     invalidated by the events of the user, and of the commands taking the email,
+//   - the rest of the queries (lists), which are invalidated all together by every event of their tenant, as any
+//     change can move a user in or out of every one of them. Their keys include the generation of the tenant, a
+//     random token replaced by the events, so the old entries are just left to expire. The missing generation (e.g.
+//     evicted) is replaced by a new one as well, so that the entries of the old generations are never read again.
+//
+// The load of an entry can start before an event and finish after its invalidation. The lists loaded so are filled
+// under the old generation, which is no longer read. The single user lookups are filled only when the generation
+// read before the load is still the current one, and checked again after the fill, which is removed when an event
+// has come in between - the invalidation replaces the generation before it removes the entries.
+//
+// It's the domain.Publisher of the events emitted by CommandEventsWrapper, which emits them once the commands have
+// succeeded, so the entries filled after the invalidation already see the change. When the wrapped service reads
+// the projection, it's the publisher of the events of the projection instead, which emits them once it has applied
+// them - the command events would let the entries be filled from the projection before the change reaches it.
+// The concurrent misses of the same entry are loaded from the wrapped service once. The password hashes are not
+// cached. When the cache fails, the failure is logged and the queries go to the wrapped service. So do the queries
+// with a consistency token, which need to see a write that could have been made after their entry was filled.
+type QueryCacheWrapper struct {
+	wrapped  UsersQueryService
+	cache    UsersCache
+	config   QueryCacheConfig
+	loads    *singleflight.Group
+	requests *prometheus.CounterVec
+}
+
+func NewQueryCacheWrapper(registerer prometheus.Registerer, cache UsersCache, wrapped UsersQueryService, config QueryCacheConfig) QueryCacheWrapper {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "users_query_cache_requests_total",
+		Help: "Number of the queries looked up in the cache, by query (user, list) and result (hit, miss, error).",
+	}, []string{"query", "result"})
+	registerer.MustRegister(requests)
+
+	return QueryCacheWrapper{wrapped, cache, config, &singleflight.Group{}, requests}
+}
+
+func (q QueryCacheWrapper) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	if domain.ConsistencyTokenFromContext(ctx) != "" {
+		return q.wrapped.Users(ctx, filter, pagination)
+	}
+
+	query, key, generation, err := q.key(ctx, filter, pagination)
+	if err == nil {
+		var users []domain.User
+		users, err = q.get(ctx, key)
+		if err == nil && users != nil {
+			q.requests.WithLabelValues(query, "hit").Inc()
+			return users, nil
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to read the users cache", zap.Error(err))
+		q.requests.WithLabelValues(query, "error").Inc()
+		return q.wrapped.Users(ctx, filter, pagination)
+	}
+
+	q.requests.WithLabelValues(query, "miss").Inc()
+	return q.load(ctx, query, key, generation, filter, pagination)
+}
+
+// key returns the kind of the query, the key of its entry and the generation of the tenant
+func (q QueryCacheWrapper) key(ctx context.Context, filter domain.Filter, pagination domain.Pagination) (string, string, string, error) {
+	tenant := domain.TenantFromContext(ctx)
+	query := listQuery
+	if isUserQuery(filter, pagination) {
+		query = userQuery
+	}
+
+	generation, err := q.generation(ctx, tenant)
+	if err != nil {
+		return query, "", "", err
+	}
+	if query == userQuery {
+		return query, userKey(tenant, *filter.Email()), generation, nil
+	}
+
+	return query, listKey(tenant, generation, filter, pagination), generation, nil
+}
+
+// generation returns the current generation of the tenant, the missing one is replaced by a new one
+func (q QueryCacheWrapper) generation(ctx context.Context, tenant domain.TenantID) (string, error) {
+	generation, ok, err := q.cache.Get(ctx, generationKey(tenant))
+	if err != nil || ok {
+		return string(generation), err
+	}
+
+	return q.newGeneration(ctx, tenant)
+}
+
+// newGeneration replaces the generation of the tenant by a random one, it's not a counter, which would start again
+// from the same values once the key was evicted
+func (q QueryCacheWrapper) newGeneration(ctx context.Context, tenant domain.TenantID) (string, error) {
+	generation := uuid.NewString()
+	if err := q.cache.Set(ctx, generationKey(tenant), []byte(generation), 0); err != nil {
+		return "", err
+	}
+
+	return generation, nil
+}
+
+// isGeneration tells if the generation is still the current one of the tenant
+func (q QueryCacheWrapper) isGeneration(ctx context.Context, tenant domain.TenantID, generation string) (bool, error) {
+	current, ok, err := q.cache.Get(ctx, generationKey(tenant))
+	return ok && string(current) == generation, err
+}
+
+// CountryCounts is not cached, the counts change with every event of the tenant
+func (q QueryCacheWrapper) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	return q.wrapped.CountryCounts(ctx)
+}
+
+// get returns nil users when the entry is missing
+func (q QueryCacheWrapper) get(ctx context.Context, key string) ([]domain.User, error) {
+	value, ok, err := q.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	users := []domain.User{}
+	if err := json.Unmarshal(value, &users); err != nil {
+		return nil, fmt.Errorf("invalid entry %s: %w", key, err)
+	}
+
+	return users, nil
+}
+
+// load queries the wrapped service and fills the entry, the concurrent loads of the same entry are shared
+func (q QueryCacheWrapper) load(ctx context.Context, query, key, generation string, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	// the load is shared by the callers, so it mustn't be canceled together with the first one of them
+	loadCtx := context.WithoutCancel(ctx)
+	loaded := q.loads.DoChan(key, func() (interface{}, error) {
+		users, err := q.wrapped.Users(loadCtx, filter, pagination)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.set(loadCtx, query, key, generation, users); err != nil {
+			logging.FromContext(ctx).Warn("failed to fill the users cache", zap.Error(err))
+		}
+
+		return users, nil
+	})
+
+	select {
+	case result := <-loaded:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]domain.User), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// set fills the entry loaded in the generation, see QueryCacheWrapper for how the fills racing with the events are
+// left out
+func (q QueryCacheWrapper) set(ctx context.Context, query, key, generation string, users []domain.User) error {
+	cached := make([]domain.User, len(users))
+	for i, user := range users {
+		user.PasswordHash = ""
+		cached[i] = user
+	}
+	value, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to encode the entry: %w", err)
+	}
+
+	if query == listQuery {
+		return q.cache.Set(ctx, key, value, q.config.ListTTL)
+	}
+
+	tenant := domain.TenantFromContext(ctx)
+	if current, err := q.isGeneration(ctx, tenant, generation); err != nil || !current {
+		return err
+	}
+	if err := q.cache.Set(ctx, key, value, q.config.UserTTL); err != nil {
+		return err
+	}
+	// the index lets the events of the user, which carry only its id, find the entry
+	if len(users) == 1 {
+		if err := q.cache.Set(ctx, userIndexKey(tenant, users[0].ID), []byte(key), q.config.UserTTL); err != nil {
+			return err
+		}
+	}
+	if current, err := q.isGeneration(ctx, tenant, generation); err != nil || !current {
+		return errors.Join(err, q.cache.Delete(ctx, key))
+	}
+
+	return nil
+}
+
+// PublishEvent invalidates the entries changed by the event
+func (q QueryCacheWrapper) PublishEvent(ctx context.Context, event domain.Event) error {
+	keys, err := q.invalidatedKeys(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return q.invalidate(ctx, event.TenantID, keys)
+}
+
+// invalidatedKeys returns the keys of the single user lookups changed by the event
+func (q QueryCacheWrapper) invalidatedKeys(ctx context.Context, event domain.Event) ([]string, error) {
+	var keys []string
+	switch command := event.Command.(type) {
+	case AddUserCommand:
+		keys = append(keys, userKey(event.TenantID, command.Email))
+	case ModifyUserCommand:
+		if command.Email != nil {
+			keys = append(keys, userKey(event.TenantID, *command.Email))
+		}
+	}
+	// the events of the projection carry the user instead of the command
+	if event.User != nil {
+		keys = append(keys, userKey(event.TenantID, event.User.Email))
+	}
+	if event.UserID == (domain.UserID{}) {
+		return keys, nil
+	}
+
+	// the previous email of the user is found by the index
+	indexKey := userIndexKey(event.TenantID, event.UserID)
+	entryKey, ok, err := q.cache.Get(ctx, indexKey)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, indexKey)
+	if ok {
+		keys = append(keys, string(entryKey))
+	}
+
+	return keys, nil
+}
+
+// invalidate starts the new generation of the tenant and removes the entries
+func (q QueryCacheWrapper) invalidate(ctx context.Context, tenant domain.TenantID, keys []string) error {
+	// the generation is replaced first, so that the fills racing with the invalidation find out about it
+	if _, err := q.newGeneration(ctx, tenant); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return q.cache.Delete(ctx, keys...)
+}
+
+// isUserQuery tells if the query looks up a single user, the emails are unique within the tenant
+func isUserQuery(filter domain.Filter, pagination domain.Pagination) bool {
+	return filter.Email() != nil && filter.FirstName() == nil && filter.LastName() == nil &&
+		filter.Nickname() == nil && filter.Country() == nil && filter.Search() == nil && pagination.Offset == 0
+}
+
+// userKey hashes the email, so that it's not kept in the keys. The emails are lowercased, as they're looked up
+// case-insensitively.
+func userKey(tenant domain.TenantID, email string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(email)))
+	return string(tenant) + ":email:" + hex.EncodeToString(hash[:])
+}
+
+func userIndexKey(tenant domain.TenantID, id domain.UserID) string {
+	return string(tenant) + ":user:" + id.String()
+}
+
+func generationKey(tenant domain.TenantID) string {
+	return string(tenant) + ":generation"
+}
+
+func listKey(tenant domain.TenantID, generation string, filter domain.Filter, pagination domain.Pagination) string {
+	hash := sha256.New()
+	for _, field := range []*string{filter.FirstName(), filter.LastName(), filter.Nickname(), filter.Email(), filter.Country(), filter.Search()} {
+		if field == nil {
+			hash.Write([]byte("-;"))
+		} else {
+			fmt.Fprintf(hash, "%q;", *field)
+		}
+	}
+	fmt.Fprintf(hash, "%d;%d", pagination.Limit(), pagination.Offset)
+
+	return string(tenant) + ":list:" + generation + ":" + hex.EncodeToString(hash.Sum(nil))
+}
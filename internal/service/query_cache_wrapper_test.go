@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"users-app/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usersCacheStub keeps the entries in a map, the ttls are ignored
+type usersCacheStub struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	err     error
+}
+
+func newUsersCacheStub() *usersCacheStub {
+	return &usersCacheStub{entries: map[string][]byte{}}
+}
+
+func (c *usersCacheStub) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.entries[key]
+	return value, ok, c.err
+}
+
+func (c *usersCacheStub) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = value
+	return c.err
+}
+
+func (c *usersCacheStub) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return c.err
+}
+
+// queryServiceStub returns the users of the tenant of the request, the calls are counted
+type queryServiceStub struct {
+	users   []domain.User
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *queryServiceStub) Users(ctx context.Context, filter domain.Filter, _ domain.Pagination) ([]domain.User, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+
+	var users []domain.User
+	for _, user := range s.users {
+		if user.TenantID == domain.TenantFromContext(ctx) && (filter.Email() == nil || *filter.Email() == user.Email) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (s *queryServiceStub) CountryCounts(context.Context) ([]domain.CountryCount, error) {
+	s.calls.Add(1)
+	return nil, nil
+}
+
+func newTestQueryCacheWrapper(users ...domain.User) (QueryCacheWrapper, *queryServiceStub, *usersCacheStub) {
+	wrapped := &queryServiceStub{users: users}
+	cache := newUsersCacheStub()
+	wrapper := NewQueryCacheWrapper(prometheus.NewRegistry(), cache, wrapped, QueryCacheConfig{UserTTL: time.Minute, ListTTL: time.Minute})
+	return wrapper, wrapped, cache
+}
+
+var (
+	alice = domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "Alice", Email: "alice@example.com", PasswordHash: "hash"}
+	bob   = domain.User{ID: domain.NewUserID(), TenantID: domain.DefaultTenant, FirstName: "Bob", Email: "bob@example.com"}
+)
+
+func TestQueryCacheWrapper_Users(t *testing.T) {
+	wrapper, wrapped, _ := newTestQueryCacheWrapper(alice, bob)
+	ctx := context.Background()
+	query := func(ctx context.Context, filter domain.Filter) []domain.User {
+		users, err := wrapper.Users(ctx, filter, domain.DefaultPagination)
+		require.NoError(t, err)
+		return users
+	}
+
+	assert.Equal(t, []domain.User{alice}, query(ctx, domain.NewFilterEmail(alice.Email)))
+	cachedAlice := alice
+	cachedAlice.PasswordHash = ""
+	assert.Equal(t, []domain.User{cachedAlice}, query(ctx, domain.NewFilterEmail(alice.Email)), "the password hash is not cached")
+	assert.Len(t, query(ctx, domain.Filter{}), 2)
+	assert.Len(t, query(ctx, domain.Filter{}), 2)
+	assert.Empty(t, query(domain.WithTenant(ctx, "arcade"), domain.Filter{}), "every tenant has its own entries")
+	assert.Equal(t, int32(3), wrapped.calls.Load())
+	assert.Len(t, query(domain.WithConsistencyToken(ctx, "0/30"), domain.Filter{}), 2)
+	assert.Equal(t, int32(4), wrapped.calls.Load(), "the queries with a consistency token are not cached")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(wrapper.requests.WithLabelValues(userQuery, "hit")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(wrapper.requests.WithLabelValues(userQuery, "miss")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(wrapper.requests.WithLabelValues(listQuery, "hit")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(wrapper.requests.WithLabelValues(listQuery, "miss")))
+}
+
+func TestQueryCacheWrapper_PublishEvent(t *testing.T) {
+	newEmail := "alice@example.org"
+
+	tests := []struct {
+		name  string
+		event domain.Event
+
+		wantAliceLoaded bool
+		wantBobLoaded   bool
+		wantListLoaded  bool
+	}{
+		{
+			name:            "user_modified",
+			event:           domain.Event{Msg: domain.UserModified, Command: ModifyUserCommand{ID: alice.ID, Email: &newEmail}, TenantID: domain.DefaultTenant, UserID: alice.ID},
+			wantAliceLoaded: true,
+			wantListLoaded:  true,
+		},
+		{
+			name:            "user_deleted",
+			event:           domain.Event{Msg: domain.UserDeleted, Command: DeleteUserCommand{ID: alice.ID}, TenantID: domain.DefaultTenant, UserID: alice.ID},
+			wantAliceLoaded: true,
+			wantListLoaded:  true,
+		},
+		{
+			name:           "user_added_with_the_email",
+			event:          domain.Event{Msg: domain.UserAdded, Command: AddUserCommand{Email: bob.Email}, TenantID: domain.DefaultTenant},
+			wantBobLoaded:  true,
+			wantListLoaded: true,
+		},
+		{
+			name:           "user_added_with_the_email_in_another_case",
+			event:          domain.Event{Msg: domain.UserAdded, Command: AddUserCommand{Email: "Bob@Example.com"}, TenantID: domain.DefaultTenant},
+			wantBobLoaded:  true,
+			wantListLoaded: true,
+		},
+		{
+			name:            "projection_user_modified",
+			event:           domain.Event{Msg: domain.UserModified, TenantID: domain.DefaultTenant, UserID: alice.ID, User: &domain.User{Email: newEmail}},
+			wantAliceLoaded: true,
+			wantListLoaded:  true,
+		},
+		{
+			name:           "projection_user_added_with_the_email",
+			event:          domain.Event{Msg: domain.UserAdded, TenantID: domain.DefaultTenant, UserID: domain.NewUserID(), User: &domain.User{Email: bob.Email}},
+			wantBobLoaded:  true,
+			wantListLoaded: true,
+		},
+		{
+			name:  "other_tenant",
+			event: domain.Event{Msg: domain.UserDeleted, Command: DeleteUserCommand{ID: alice.ID}, TenantID: "arcade", UserID: alice.ID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapper, wrapped, _ := newTestQueryCacheWrapper(alice)
+			ctx := context.Background()
+			loaded := func(filter domain.Filter) bool {
+				calls := wrapped.calls.Load()
+				_, err := wrapper.Users(ctx, filter, domain.DefaultPagination)
+				require.NoError(t, err)
+				return wrapped.calls.Load() > calls
+			}
+			for _, filter := range []domain.Filter{domain.NewFilterEmail(alice.Email), domain.NewFilterEmail(bob.Email), {}} {
+				require.True(t, loaded(filter))
+			}
+
+			require.NoError(t, wrapper.PublishEvent(ctx, tt.event))
+
+			assert.Equal(t, tt.wantAliceLoaded, loaded(domain.NewFilterEmail(alice.Email)))
+			assert.Equal(t, tt.wantBobLoaded, loaded(domain.NewFilterEmail(bob.Email)))
+			assert.Equal(t, tt.wantListLoaded, loaded(domain.Filter{}))
+		})
+	}
+}
+
+func TestQueryCacheWrapper_Users_loads_concurrent_misses_once(t *testing.T) {
+	wrapper, wrapped, _ := newTestQueryCacheWrapper(alice)
+	wrapped.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users, err := wrapper.Users(context.Background(), domain.Filter{}, domain.DefaultPagination)
+			assert.NoError(t, err)
+			assert.Len(t, users, 1)
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(wrapper.requests.WithLabelValues(listQuery, "miss")) == 10
+	}, time.Second, time.Millisecond)
+	// the misses are counted right before the callers join the load
+	time.Sleep(10 * time.Millisecond)
+	close(wrapped.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), wrapped.calls.Load())
+}
+
+func TestQueryCacheWrapper_Users_load_racing_with_event(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter domain.Filter
+	}{
+		{name: "user", filter: domain.NewFilterEmail(alice.Email)},
+		{name: "list", filter: domain.Filter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapper, wrapped, _ := newTestQueryCacheWrapper(alice)
+			wrapped.release = make(chan struct{})
+			ctx := context.Background()
+
+			loaded := make(chan struct{})
+			go func() {
+				defer close(loaded)
+				_, err := wrapper.Users(ctx, tt.filter, domain.DefaultPagination)
+				assert.NoError(t, err)
+			}()
+			require.Eventually(t, func() bool { return wrapped.calls.Load() == 1 }, time.Second, time.Millisecond)
+			require.NoError(t, wrapper.PublishEvent(ctx, domain.Event{Msg: domain.UserDeleted, Command: DeleteUserCommand{ID: alice.ID}, TenantID: domain.DefaultTenant, UserID: alice.ID}))
+			close(wrapped.release)
+			<-loaded
+
+			_, err := wrapper.Users(ctx, tt.filter, domain.DefaultPagination)
+			require.NoError(t, err)
+			assert.Equal(t, int32(2), wrapped.calls.Load(), "the result loaded before the event is not filled")
+		})
+	}
+}
+
+func TestQueryCacheWrapper_Users_missing_generation(t *testing.T) {
+	wrapper, wrapped, cache := newTestQueryCacheWrapper(alice)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := wrapper.Users(ctx, domain.Filter{}, domain.DefaultPagination)
+		require.NoError(t, err)
+		require.NoError(t, cache.Delete(ctx, generationKey(domain.DefaultTenant)))
+	}
+
+	assert.Equal(t, int32(2), wrapped.calls.Load(), "the entries of the evicted generation are not read again")
+}
+
+func TestQueryCacheWrapper_Users_cache_failure(t *testing.T) {
+	wrapper, wrapped, cache := newTestQueryCacheWrapper(alice)
+	cache.err = errors.New("connection refused")
+
+	users, err := wrapper.Users(context.Background(), domain.Filter{}, domain.DefaultPagination)
+
+	require.NoError(t, err)
+	assert.Equal(t, []domain.User{alice}, users, "the query goes to the wrapped service")
+	assert.Equal(t, int32(1), wrapped.calls.Load())
+	assert.Equal(t, 1.0, testutil.ToFloat64(wrapper.requests.WithLabelValues(listQuery, "error")))
+	require.Error(t, wrapper.PublishEvent(context.Background(), domain.Event{Command: DeleteUserCommand{ID: alice.ID}}))
+}
+
+func TestIsUserQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     domain.Filter
+		pagination domain.Pagination
+
+		expected bool
+	}{
+		{name: "email", filter: domain.NewFilterEmail(alice.Email), pagination: domain.DefaultPagination, expected: true},
+		{name: "email_with_offset", filter: domain.NewFilterEmail(alice.Email), pagination: domain.NewPagination(10, 1)},
+		{name: "email_with_search", filter: domain.NewFilterEmail(alice.Email).WithSearch("alice"), pagination: domain.DefaultPagination},
+		{name: "search", filter: domain.Filter{}.WithSearch("alice"), pagination: domain.DefaultPagination},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isUserQuery(tt.filter, tt.pagination))
+		})
+	}
+}
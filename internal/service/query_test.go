@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserQueryService_Users(t *testing.T) {
+	repo := repositoryStub{users: []domain.User{alice}}
+	readModel := repositoryStub{users: []domain.User{bob}}
+
+	tests := []struct {
+		name string
+		svc  *UserQueryService
+		ctx  context.Context
+		want []domain.User
+	}{
+		{"repository", NewUserQueryService(repo), context.Background(), []domain.User{alice}},
+		{"read_model", NewProjectedUserQueryService(repo, readModel), context.Background(), []domain.User{bob}},
+		{
+			"consistency_token",
+			NewProjectedUserQueryService(repo, readModel),
+			domain.WithConsistencyToken(context.Background(), "0/30"),
+			[]domain.User{alice},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := tt.svc.Users(tt.ctx, domain.Filter{}, domain.DefaultPagination)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, users)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"users-app/domain"
+	"users-app/logging"
+	"users-app/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// eventsRecorder serializes the events the same way the publishers and the events log do
+type eventsRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *eventsRecorder) PublishEvent(_ context.Context, event domain.Event) error {
+	r.LogEvent(event)
+	return nil
+}
+
+func (r *eventsRecorder) LogEvent(event domain.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := json.Marshal(event.Payload())
+	r.events = append(r.events, string(body))
+}
+
+func TestCommands_never_leak_the_password(t *testing.T) {
+	const password = "p4ssw0rd-n0t-t0-b3-l0gg3d"
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := logging.WithLogger(context.Background(), zap.New(redact.NewCore(core, redact.Options{})))
+	recorder := &eventsRecorder{}
+	svc := NewCommandEventsWrapper(recorder, NewCommandLoggingWrapper(NewUserCommandService(repositoryStub{})), recorder)
+
+	user, err := svc.AddUser(ctx, AddUserCommand{FirstName: "John", Email: "john@doe.com", Password: password})
+	require.NoError(t, err)
+	require.NoError(t, svc.Wait(ctx))
+	assert.NotEmpty(t, user.PasswordHash)
+
+	require.Len(t, recorder.events, 2, "the event is logged and published")
+	for _, event := range recorder.events {
+		assert.Contains(t, event, "john@doe.com")
+		assert.NotContains(t, event, password)
+		assert.NotContains(t, event, user.PasswordHash)
+	}
+
+	require.NotZero(t, logs.Len())
+	for _, entry := range logs.All() {
+		fields, err := json.Marshal(entry.ContextMap())
+		require.NoError(t, err)
+		assert.NotContains(t, string(fields), password, entry.Message)
+		assert.NotContains(t, string(fields), user.PasswordHash, entry.Message)
+	}
+}
+
+func TestUser_logged_without_the_password_hash(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	user, err := domain.NewUser(domain.DefaultTenant, "John", "Doe", "john", "p4ssw0rd", "john@doe.com", "UK")
+	require.NoError(t, err)
+
+	zap.New(redact.NewCore(core, redact.Options{HashPII: true})).Info("user", zap.Any("user", user))
+
+	require.Equal(t, 1, logs.Len())
+	logged := logs.All()[0].ContextMap()["user"].(map[string]interface{})
+	assert.Equal(t, redact.Mask, logged["PasswordHash"])
+	assert.Equal(t, redact.Hash("john@doe.com"), logged["Email"])
+	assert.Equal(t, "John", logged["FirstName"])
+}
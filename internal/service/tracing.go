@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"users-app/domain"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is not kept in a variable, so that it always comes from the current global provider
+func tracer() trace.Tracer {
+	return otel.Tracer("users-app/service")
+}
+
+// recordError marks the span as failed when the error is not nil, the error is returned as it is
+func recordError(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// CommandTracingWrapper is a wrapper for UsersCommandService
+// It creates a span for every command, the span is the parent of the spans created deeper (repository, publishers)
+type CommandTracingWrapper struct {
+	wrapped UsersCommandService
+}
+
+func NewCommandTracingWrapper(wrapped UsersCommandService) UsersCommandService {
+	return CommandTracingWrapper{wrapped}
+}
+
+func (c CommandTracingWrapper) AddUser(ctx context.Context, command AddUserCommand) (domain.User, error) {
+	ctx, span := tracer().Start(ctx, "UsersCommandService.AddUser")
+	defer span.End()
+
+	user, err := c.wrapped.AddUser(ctx, command)
+	if err == nil {
+		span.SetAttributes(attribute.String("user.id", user.ID.String()))
+	}
+
+	return user, recordError(span, err)
+}
+
+func (c CommandTracingWrapper) ModifyUser(ctx context.Context, command ModifyUserCommand) (domain.User, error) {
+	ctx, span := tracer().Start(ctx, "UsersCommandService.ModifyUser", trace.WithAttributes(
+		attribute.String("user.id", command.ID.String()),
+	))
+	defer span.End()
+
+	user, err := c.wrapped.ModifyUser(ctx, command)
+	return user, recordError(span, err)
+}
+
+func (c CommandTracingWrapper) DeleteUser(ctx context.Context, command DeleteUserCommand) error {
+	ctx, span := tracer().Start(ctx, "UsersCommandService.DeleteUser", trace.WithAttributes(
+		attribute.String("user.id", command.ID.String()),
+	))
+	defer span.End()
+
+	return recordError(span, c.wrapped.DeleteUser(ctx, command))
+}
+
+// QueryTracingWrapper is a wrapper for UsersQueryService, it creates a span for every query
+type QueryTracingWrapper struct {
+	wrapped UsersQueryService
+}
+
+func NewQueryTracingWrapper(wrapped UsersQueryService) UsersQueryService {
+	return QueryTracingWrapper{wrapped}
+}
+
+func (q QueryTracingWrapper) Users(ctx context.Context, filter domain.Filter, pagination domain.Pagination) ([]domain.User, error) {
+	ctx, span := tracer().Start(ctx, "UsersQueryService.Users", trace.WithAttributes(
+		attribute.Int("pagination.limit", pagination.Limit()),
+		attribute.Int("pagination.offset", pagination.Offset),
+	))
+	defer span.End()
+
+	users, err := q.wrapped.Users(ctx, filter, pagination)
+	span.SetAttributes(attribute.Int("users.count", len(users)))
+
+	return users, recordError(span, err)
+}
+
+func (q QueryTracingWrapper) CountryCounts(ctx context.Context) ([]domain.CountryCount, error) {
+	ctx, span := tracer().Start(ctx, "UsersQueryService.CountryCounts")
+	defer span.End()
+
+	counts, err := q.wrapped.CountryCounts(ctx)
+	span.SetAttributes(attribute.Int("countries.count", len(counts)))
+
+	return counts, recordError(span, err)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"users-app/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// repositoryStub returns the configured users and error from every method
+type repositoryStub struct {
+	users []domain.User
+	err   error
+}
+
+func (r repositoryStub) AddUser(context.Context, domain.User) error { return r.err }
+func (r repositoryStub) ModifyUser(context.Context, domain.UserID, domain.Fields) (domain.User, error) {
+	return domain.User{}, r.err
+}
+func (r repositoryStub) RemoveUser(context.Context, domain.UserID) error { return r.err }
+func (r repositoryStub) Users(context.Context, domain.Filter, domain.Pagination) ([]domain.User, error) {
+	return r.users, r.err
+}
+func (r repositoryStub) CountryCounts(context.Context) ([]domain.CountryCount, error) {
+	return nil, r.err
+}
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func TestCommandTracingWrapper_AddUser(t *testing.T) {
+	recorder := recordSpans(t)
+	svc := NewCommandTracingWrapper(NewUserCommandService(repositoryStub{}))
+
+	user, err := svc.AddUser(context.Background(), AddUserCommand{Email: "john@doe.com", Password: "secret"})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "UsersCommandService.AddUser", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), userIDAttribute(user.ID))
+}
+
+func TestCommandTracingWrapper_records_errors(t *testing.T) {
+	recorder := recordSpans(t)
+	errFailed := errors.New("failed")
+	svc := NewCommandTracingWrapper(NewUserCommandService(repositoryStub{err: errFailed}))
+
+	err := svc.DeleteUser(context.Background(), DeleteUserCommand{ID: domain.NewUserID()})
+	assert.ErrorIs(t, err, errFailed)
+
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+		assert.Equal(t, "failed", span.Status().Description, span.Name())
+	}
+}
+
+func TestQueryTracingWrapper_Users(t *testing.T) {
+	recorder := recordSpans(t)
+	svc := NewQueryTracingWrapper(NewUserQueryService(repositoryStub{users: []domain.User{{}, {}}}))
+
+	users, err := svc.Users(context.Background(), domain.Filter{}, domain.DefaultPagination)
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "UsersQueryService.Users", spans[0].Name())
+}
+
+func userIDAttribute(id domain.UserID) attribute.KeyValue {
+	return attribute.String("user.id", id.String())
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"users-app/domain"
+)
+
+// WebhooksService is used to manage webhook subscriptions of the services,
+// which want to be notified about the events with HTTP callbacks instead of subscribing to Redis.
+// The subscriptions belong to the tenant of the request, see domain.TenantFromContext.
+type WebhooksService interface {
+	AddSubscription(context.Context, AddWebhookCommand) (domain.WebhookSubscription, error)
+	ModifySubscription(context.Context, ModifyWebhookCommand) (domain.WebhookSubscription, error)
+	DeleteSubscription(context.Context, domain.WebhookID) error
+	Subscription(context.Context, domain.WebhookID) (domain.WebhookSubscription, error)
+	Subscriptions(context.Context, domain.Pagination) ([]domain.WebhookSubscription, error)
+	Deliveries(context.Context, domain.WebhookID, domain.Pagination) ([]domain.WebhookDelivery, error)
+}
+
+type webhooksService struct {
+	repo domain.WebhookRepository
+}
+
+func NewWebhooksService(repo domain.WebhookRepository) WebhooksService {
+	return webhooksService{repo}
+}
+
+// AddWebhookCommand is used to register a new webhook subscription
+type AddWebhookCommand struct {
+	URL        string
+	EventTypes []domain.EventMsg
+}
+
+func (w webhooksService) AddSubscription(ctx context.Context, command AddWebhookCommand) (domain.WebhookSubscription, error) {
+	subscription, err := domain.NewWebhookSubscription(domain.TenantFromContext(ctx), command.URL, command.EventTypes)
+	if err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	if err := w.repo.AddSubscription(subscription); err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	return subscription, nil
+}
+
+// ModifyWebhookCommand is used to modify a webhook subscription, nil fields are left unchanged
+type ModifyWebhookCommand struct {
+	ID         domain.WebhookID
+	URL        *string
+	EventTypes *[]domain.EventMsg
+	Enabled    *bool
+}
+
+func (w webhooksService) ModifySubscription(ctx context.Context, command ModifyWebhookCommand) (domain.WebhookSubscription, error) {
+	subscription, err := w.repo.Subscription(domain.TenantFromContext(ctx), command.ID)
+	if err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	if err := subscription.Modify(command.URL, command.EventTypes, command.Enabled); err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	if err := w.repo.UpdateSubscription(subscription); err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	return subscription, nil
+}
+
+func (w webhooksService) DeleteSubscription(ctx context.Context, id domain.WebhookID) error {
+	tenant := domain.TenantFromContext(ctx)
+	if _, err := w.repo.Subscription(tenant, id); err != nil {
+		return err
+	}
+
+	return w.repo.RemoveSubscription(tenant, id)
+}
+
+func (w webhooksService) Subscription(ctx context.Context, id domain.WebhookID) (domain.WebhookSubscription, error) {
+	return w.repo.Subscription(domain.TenantFromContext(ctx), id)
+}
+
+func (w webhooksService) Subscriptions(ctx context.Context, p domain.Pagination) ([]domain.WebhookSubscription, error) {
+	return w.repo.Subscriptions(domain.TenantFromContext(ctx), p)
+}
+
+// Deliveries returns the deliveries log of a subscription, or domain.ErrWebhookNotFound if the subscription doesn't exist
+func (w webhooksService) Deliveries(ctx context.Context, id domain.WebhookID, p domain.Pagination) ([]domain.WebhookDelivery, error) {
+	tenant := domain.TenantFromContext(ctx)
+	if _, err := w.repo.Subscription(tenant, id); err != nil {
+		return nil, err
+	}
+
+	return w.repo.Deliveries(tenant, id, p)
+}
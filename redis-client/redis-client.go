@@ -24,7 +24,14 @@ func main() {
 		panic(err)
 	}
 
-	pubsub := client.Subscribe(context.Background(), os.Getenv("REDIS_EVENTS_CHANNEL"))
+	// the events of every tenant are published to their own channel, e.g. events:arcade
+	channel := os.Getenv("REDIS_EVENTS_CHANNEL")
+	var pubsub *redis.PubSub
+	if os.Getenv("REDIS_EVENTS_CHANNEL_PER_TENANT") == "true" {
+		pubsub = client.PSubscribe(context.Background(), channel+":*")
+	} else {
+		pubsub = client.Subscribe(context.Background(), channel)
+	}
 	defer pubsub.Close()
 
 	for {
@@ -32,6 +39,6 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		fmt.Printf("Received message: %s\n", msg.Payload)
+		fmt.Printf("Received message on %s: %s\n", msg.Channel, msg.Payload)
 	}
 }
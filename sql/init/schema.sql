@@ -1,11 +1,139 @@
 CREATE TABLE IF NOT EXISTS users (
     id UUID PRIMARY KEY,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
     first_name VARCHAR(100) NOT NULL,
     last_name VARCHAR(100) NOT NULL,
     nickname VARCHAR(100) NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
-    email VARCHAR(100) UNIQUE NOT NULL,
+    email VARCHAR(100) NOT NULL,
     country VARCHAR(100) NOT NULL,
     created_at TIMESTAMP NOT NULL,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+
+-- the tables created before the tenants were introduced get the users moved to the default tenant,
+-- the emails are unique within the tenant
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_tenant_id_email_idx ON users (tenant_id, email);
+-- the emails are looked up case-insensitively
+CREATE INDEX IF NOT EXISTS users_tenant_id_email_lower_idx ON users (tenant_id, lower(email));
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    url VARCHAR(2048) NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    secret VARCHAR(255) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_type VARCHAR(100) NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    succeeded BOOLEAN NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- the subscriptions created before the tenants were introduced, and their deliveries, are moved to the default tenant
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_tenant_id_created_at_idx ON webhook_subscriptions (tenant_id, created_at);
+-- the subscription_id one serves the cascading deletes
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_tenant_id_subscription_id_idx
+    ON webhook_deliveries (tenant_id, subscription_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    operation VARCHAR(100) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    response JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (operation, key)
+);
+
+-- a not completed key is reserved until reserved_until, afterwards a retry can take it over
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS reserved_until TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- user_events is the outbox of the changes of the users, written in their transactions, ordered by the transaction
+-- (tx_id) and then by id. The projections are built from it, see users_view.
+CREATE TABLE IF NOT EXISTS user_events (
+    id BIGSERIAL PRIMARY KEY,
+    tx_id XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    type VARCHAR(100) NOT NULL,
+    tenant_id VARCHAR(63) NOT NULL,
+    user_id UUID NOT NULL,
+    -- the user after the change, with the fields named after the columns, without the password hash,
+    -- empty for the deletions
+    user_data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the users added before the events were introduced get an event each, so that the projections can be rebuilt
+INSERT INTO user_events (type, tenant_id, user_id, user_data)
+SELECT 'user-added', u.tenant_id, u.id, to_jsonb(u) - 'password_hash'
+FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM user_events)
+ORDER BY u.created_at, u.id;
+
+CREATE INDEX IF NOT EXISTS user_events_tx_id_id_idx ON user_events (tx_id, id);
+-- the pruning of the events looks for the later events of the users
+CREATE INDEX IF NOT EXISTS user_events_user_id_tx_id_id_idx ON user_events (user_id, tx_id, id);
+
+-- users_view is the projection of the users read by the queries, it has an index for every filter
+CREATE TABLE IF NOT EXISTS users_view (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(63) NOT NULL,
+    first_name VARCHAR(100) NOT NULL,
+    last_name VARCHAR(100) NOT NULL,
+    nickname VARCHAR(100) NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_created_at_idx ON users_view (tenant_id, created_at, id);
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_email_idx ON users_view (tenant_id, email);
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_first_name_idx ON users_view (tenant_id, first_name);
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_last_name_idx ON users_view (tenant_id, last_name);
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_nickname_idx ON users_view (tenant_id, nickname);
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_country_idx ON users_view (tenant_id, country);
+
+-- the search columns are generated from the user, the lowercased email for the case-insensitive lookups and
+-- the words of the names and the email for the full-text search (the email and search filters of the queries)
+ALTER TABLE users_view ADD COLUMN IF NOT EXISTS email_lower VARCHAR(100) GENERATED ALWAYS AS (lower(email)) STORED;
+ALTER TABLE users_view ADD COLUMN IF NOT EXISTS search TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', first_name || ' ' || last_name || ' ' || nickname || ' ' || email)) STORED;
+
+CREATE INDEX IF NOT EXISTS users_view_tenant_id_email_lower_idx ON users_view (tenant_id, email_lower);
+CREATE INDEX IF NOT EXISTS users_view_search_idx ON users_view USING GIN (search);
+
+-- users_country_counts is the projection of the number of the users per country
+CREATE TABLE IF NOT EXISTS users_country_counts (
+    tenant_id VARCHAR(63) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    users BIGINT NOT NULL,
+    PRIMARY KEY (tenant_id, country)
+);
+
+-- projection_checkpoints keeps the position (tx_id and id) of the last event applied to every projection
+CREATE TABLE IF NOT EXISTS projection_checkpoints (
+    name VARCHAR(100) PRIMARY KEY,
+    last_tx_id XID8 NOT NULL DEFAULT '0',
+    last_event_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);